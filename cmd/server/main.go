@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"microsoft_connector/config"
 	"microsoft_connector/internal/handlers"
 	"microsoft_connector/internal/services"
+	"microsoft_connector/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -44,6 +49,7 @@ func main() {
 		port = "10000"
 	}
 	log.Printf("Starting NEO Bot on port %s", port)
+	log.Printf("☁️  Cloud %s: Graph %s, login %s, OpenID %s", cfg.Cloud.Name, cfg.Cloud.GraphHost, cfg.Cloud.LoginAuthority, cfg.Cloud.OpenIDMetadata)
 
 	renderURL := os.Getenv("RENDER_EXTERNAL_URL")
 	if renderURL != "" {
@@ -51,15 +57,134 @@ func main() {
 		startSelfPing(renderURL)
 	}
 
+	// ===== Stockage =====
+	// STORAGE_BACKEND=redis : état partagé entre plusieurs réplicas
+	storagePath := cfg.StoragePath
+	if cfg.StorageBackend == "redis" {
+		storagePath = cfg.RedisURL
+	}
+	store, err := storage.Open(cfg.StorageBackend, storagePath)
+	if err != nil {
+		log.Fatal("❌ Storage init failed:", err)
+	}
+	defer store.Close()
+	cluster := services.NewCluster(store, storage.OpenPubSub(store), cfg.InstanceID)
+	log.Printf("[Cluster] Réplica %s (stockage: %s)", cluster.InstanceID(), cfg.StorageBackend)
+
+	vaultKey, err := services.LoadVaultKey(cfg)
+	if err != nil {
+		log.Fatal("❌ Token vault key:", err)
+	}
+	tokenVault, err := services.NewTokenVault(store, vaultKey)
+	if err != nil {
+		log.Fatal("❌ Token vault init failed:", err)
+	}
+
 	// ===== Services =====
-	authService := services.NewAuthService(cfg)
-	graphService := services.NewGraphService(authService)
-	geminiService := services.NewGeminiService(cfg.GeminiAPIKey)
+	graphCredential, err := services.NewClientCredential(cfg, cfg.ClientID, cfg.ClientSecret)
+	if err != nil {
+		log.Fatal("❌ Graph credential:", err)
+	}
+	botCredential, err := services.NewClientCredential(cfg, cfg.MicrosoftAppID, cfg.MicrosoftAppPassword)
+	if err != nil {
+		log.Fatal("❌ Bot credential:", err)
+	}
+
+	graphCredentials := services.NewCredentialProvider("GRAPH", graphCredential, cfg.Cloud.LoginAuthority)
+	botCredentials := services.NewCredentialProvider("BOT", botCredential, cfg.Cloud.LoginAuthority)
+
+	// Vérification des permissions Graph à chaque nouveau jeton d'application
+	permissions := services.NewPermissionChecker(cfg.TenantID, cfg.Cloud.GraphScope())
+	graphCredentials.OnToken(permissions.ObserveToken)
+
+	authService := services.NewAuthService(cfg, tokenVault, graphCredentials, botCredentials)
+
+	// Jetons entrants du Bot Framework sur /api/messages : clés et émetteur
+	// viennent des métadonnées OpenID du cloud
+	var botAuth *services.BotAuthenticator
+	if cfg.MicrosoftAppID != "" {
+		botAuth = services.NewBotAuthenticator(cfg.Cloud.OpenIDMetadata, cfg.MicrosoftAppID)
+	} else {
+		log.Printf("⚠️ MICROSOFT_APP_ID absent : activités acceptées sans authentification (émulateur local uniquement)")
+	}
+	graphService := services.NewGraphService(authService, services.GraphOptions{
+		Host: cfg.Cloud.GraphHost,
+		PageLimits: services.PageOptions{
+			MaxItems: cfg.GraphMaxItems,
+			MaxPages: cfg.GraphMaxPages,
+		},
+		Timeout:          cfg.GraphTimeout,
+		MaxRetries:       cfg.GraphMaxRetries,
+		BreakerThreshold: cfg.GraphBreakerThreshold,
+		BreakerCooldown:  cfg.GraphBreakerCooldown,
+	})
+	// ===== Jobs (durables avec le backend file ou sqlite) =====
+	jobs := services.NewJobQueue(store, services.JobOptions{
+		Workers:      cfg.JobWorkers,
+		PollInterval: cfg.JobPollInterval,
+		MaxAttempts:  cfg.JobMaxAttempts,
+		Retention:    cfg.JobRetention,
+	})
+	if cfg.StorageBackend == "memory" {
+		log.Printf("⚠️ STORAGE_BACKEND=memory : les jobs planifiés seront perdus au redémarrage")
+	}
+	operations := services.NewOperationTracker()
+	operations.RegisterJobs(jobs, graphService)
+	conversations := services.NewConversationStore(store, services.ConversationOptions{
+		TTL:         cfg.ConversationTTL,
+		MaxMessages: cfg.ConversationMaxMsgs,
+		Retention:   cfg.ConversationRetention,
+		ToolChars:   cfg.ConversationToolChars,
+		Summarized:  cfg.HistoryTokenBudget > 0,
+	})
+	defer conversations.Stop()
+	profiles := services.NewProfileStore(store)
+	if err := profiles.SetDefaultTimeZone(cfg.DefaultTimeZone); err != nil {
+		log.Fatal("❌ DEFAULT_TIME_ZONE:", err)
+	}
+	reminders := services.NewReminderService(store, jobs, profiles)
+	idempotency := services.NewIdempotencyStore(store, services.IdempotencyOptions{
+		TTL:   cfg.IdempotencyTTL,
+		Lease: cfg.IdempotencyLease,
+	})
+	geminiService := services.NewGeminiService(cfg.GeminiAPIKey, conversations, services.NewDelegationPolicy(cfg.ToolDelegates), permissions, operations, profiles, idempotency, reminders, services.HistoryOptions{
+		TokenBudget: cfg.HistoryTokenBudget,
+		KeepRecent:  cfg.HistoryKeepRecent,
+		Counter:     cfg.HistoryTokenCounter,
+	})
+	geminiService.RegisterJobs(jobs)
 	audioBridgeService := services.NewAudioBridgeService(cfg.AudioBridgeURL)
 
+	userData := services.NewUserDataService(store, conversations, profiles, reminders, authService)
+
 	// ===== Handlers =====
-	botHandler := handlers.NewBotHandler(geminiService, graphService, audioBridgeService)
-	audioWSHandler := handlers.NewAudioWebSocketHandler(geminiService, graphService, audioBridgeService)
+	botHandler := handlers.NewBotHandler(geminiService, graphService, audioBridgeService, authService, botAuth, profiles, userData, idempotency, cfg.MicrosoftAppID, cfg.OAuthConnectionName, cfg.Cloud.BotTokenService)
+	botHandler.ConfigureDispatcher(handlers.DispatchOptions{
+		Workers:    cfg.DispatchWorkers,
+		QueueSize:  cfg.DispatchQueueSize,
+		MaxPending: cfg.DispatchMaxPending,
+	})
+	botHandler.RegisterJobs(jobs)
+
+	// Messages proactifs : publiés sur le bus, livrés par un seul réplica ; un job
+	// de secours les livre si aucun réplica ne l'a fait
+	proactive := services.NewProactiveRelay(cluster, jobs, botHandler)
+	if err := proactive.Start(); err != nil {
+		log.Fatal("❌ Proactive relay:", err)
+	}
+	defer proactive.Stop()
+	botHandler.SetNotifier(proactive)
+	operations.SetNotifier(proactive)
+	reminders.SetNotifier(proactive)
+	audioWSHandler := handlers.NewAudioWebSocketHandler(geminiService, graphService, audioBridgeService, profiles, cluster)
+	adminHandler := handlers.NewAdminHandler(cfg.AdminAPIKey, tokenVault, permissions, userData, jobs)
+
+	// Premier jeton Graph : déclenche la vérification des permissions
+	go func() {
+		if _, err := authService.GetAccessToken(); err != nil {
+			log.Printf("⚠️  Vérification des permissions Graph impossible: %v", err)
+		}
+	}()
 
 	// Check C# bridge
 	if audioBridgeService.IsHealthy() {
@@ -81,6 +206,8 @@ func main() {
 		c.JSON(200, gin.H{
 			"status":       "healthy",
 			"audio_bridge": audioBridgeService.IsHealthy(),
+			"graph":        graphService.Metrics(),
+			"permissions":  permissionSummary(permissions),
 		})
 	})
 
@@ -100,14 +227,71 @@ func main() {
 		c.JSON(200, gin.H{
 			"calls":          calls,
 			"activeSessions": audioWSHandler.GetActiveSessions(),
+			"callOwners":     audioWSHandler.GetCallOwners(),
+			"instance":       cluster.InstanceID(),
 		})
 	})
 
+	// Administration (X-Admin-Key)
+	admin := r.Group("/admin", adminHandler.RequireAdmin())
+	admin.DELETE("/tokens", adminHandler.PurgeTokens)
+	admin.GET("/permissions", adminHandler.GetPermissions)
+	admin.DELETE("/conversations", adminHandler.ResetConversation)
+	admin.GET("/conversations/export", adminHandler.ExportConversation)
+	admin.DELETE("/users/:userId", adminHandler.EraseUser)
+	admin.GET("/jobs", adminHandler.ListJobs)
+	admin.GET("/jobs/:id", adminHandler.GetJob)
+	admin.POST("/jobs/:id/retry", adminHandler.RetryJob)
+	admin.DELETE("/jobs/:id", adminHandler.DeleteJob)
+
 	addr := "0.0.0.0:" + port
 	log.Printf("NEO Bot ready → %s", addr)
 	log.Printf("WebSocket audio → wss://<host>/ws/audio/:callId")
 
-	if err := r.Run(addr); err != nil {
-		log.Fatal("❌ Server failed:", err)
+	jobs.Start()
+
+	server := &http.Server{Addr: addr, Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// Arrêt propre : SIGTERM (déploiement) ou SIGINT
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("❌ Server failed:", err)
+		}
+	case <-ctx.Done():
+		log.Printf("🛑 Arrêt demandé, fin des traitements en cours (max %s)...", cfg.ShutdownTimeout)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ Arrêt du serveur HTTP: %v", err)
+	}
+	if err := botHandler.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ Activités abandonnées à l'arrêt: %v", err)
+	}
+	if err := jobs.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ Jobs interrompus à l'arrêt (repris au redémarrage): %v", err)
+	}
+	log.Printf("👋 NEO arrêté")
+}
+
+// permissionSummary - Outils désactivés par tenant, sans le détail des rôles
+func permissionSummary(permissions *services.PermissionChecker) map[string][]string {
+	summary := make(map[string][]string)
+	for _, report := range permissions.Reports() {
+		disabled := make([]string, 0, len(report.Disabled))
+		for _, tool := range report.Disabled {
+			disabled = append(disabled, tool.Name)
+		}
+		summary[report.TenantID] = disabled
 	}
+	return summary
 }
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"log"
+	"strings"
+)
+
+// CloudProfile - Points de terminaison Microsoft d'un cloud (public, national ou bouchons de test)
+type CloudProfile struct {
+	Name            string
+	GraphHost       string // sans version, ex. https://graph.microsoft.com
+	LoginAuthority  string // hôte du point de jeton AAD
+	BotScope        string // scope des jetons du Bot Connector
+	BotTokenService string // service de jetons OAuth du Bot Framework
+	OpenIDMetadata  string // métadonnées OpenID des jetons émis par le Bot Framework
+}
+
+var cloudProfiles = map[string]CloudProfile{
+	"public": {
+		Name:            "public",
+		GraphHost:       "https://graph.microsoft.com",
+		LoginAuthority:  "https://login.microsoftonline.com",
+		BotScope:        "https://api.botframework.com/.default",
+		BotTokenService: "https://token.botframework.com",
+		OpenIDMetadata:  "https://login.botframework.com/v1/.well-known/openidconfiguration",
+	},
+	"usgov": {
+		Name:            "usgov",
+		GraphHost:       "https://graph.microsoft.us",
+		LoginAuthority:  "https://login.microsoftonline.us",
+		BotScope:        "https://api.botframework.us/.default",
+		BotTokenService: "https://tokengcch.botframework.azure.us",
+		OpenIDMetadata:  "https://login.botframework.azure.us/v1/.well-known/openidconfiguration",
+	},
+	"china": {
+		Name:            "china",
+		GraphHost:       "https://microsoftgraph.chinacloudapi.cn",
+		LoginAuthority:  "https://login.chinacloudapi.cn",
+		BotScope:        "https://api.botframework.azure.cn/.default",
+		BotTokenService: "https://token.botframework.azure.cn",
+		OpenIDMetadata:  "https://login.botframework.azure.cn/v1/.well-known/openidconfiguration",
+	},
+}
+
+// GraphScope - Scope .default des jetons Graph de ce cloud
+func (p CloudProfile) GraphScope() string {
+	return p.GraphHost + "/.default"
+}
+
+// loadCloud - CLOUD choisit le profil ; chaque point peut être surchargé
+// individuellement ("custom" part du profil public)
+func loadCloud() CloudProfile {
+	name := strings.ToLower(getEnv("CLOUD", "public"))
+
+	profile, ok := cloudProfiles[name]
+	if !ok {
+		if name != "custom" {
+			log.Printf("Warning: unknown CLOUD=%q, using public endpoints", name)
+		}
+		profile = cloudProfiles["public"]
+		profile.Name = name
+	}
+
+	profile.GraphHost = strings.TrimSuffix(getEnv("GRAPH_HOST", profile.GraphHost), "/")
+	profile.LoginAuthority = strings.TrimSuffix(getEnv("LOGIN_AUTHORITY", profile.LoginAuthority), "/")
+	profile.BotScope = getEnv("BOT_SCOPE", profile.BotScope)
+	profile.BotTokenService = strings.TrimSuffix(getEnv("BOT_TOKEN_SERVICE_URL", profile.BotTokenService), "/")
+	profile.OpenIDMetadata = getEnv("BOT_OPENID_METADATA", profile.OpenIDMetadata)
+	return profile
+}
@@ -3,17 +3,62 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	ClientID       string
-	ClientSecret   string
-	TenantID       string
-	Port           string
-	GeminiAPIKey   string
-	AudioBridgeURL string
+	ClientID              string
+	ClientSecret          string
+	AuthMode              string
+	ClientCertificatePath string
+	ClientKeyPath         string
+	FederatedTokenFile    string
+	TenantID              string
+	BotTokenTenant        string
+	AllowedTenants        string
+	DeniedTenants         string
+	Port                  string
+	GeminiAPIKey          string
+	AudioBridgeURL        string
+	ToolDelegates         string
+	MicrosoftAppID        string
+	MicrosoftAppPassword  string
+	OAuthConnectionName   string
+	StorageBackend        string
+	StoragePath           string
+	TokenVaultKey         string
+	TokenVaultKeyFile     string
+	AdminAPIKey           string
+	GraphMaxPages         int
+	GraphMaxItems         int
+	GraphTimeout          time.Duration
+	GraphMaxRetries       int
+	GraphBreakerThreshold int
+	GraphBreakerCooldown  time.Duration
+	Cloud                 CloudProfile
+	ConversationTTL       time.Duration
+	ConversationMaxMsgs   int
+	ConversationRetention time.Duration
+	ConversationToolChars int
+	HistoryTokenBudget    int
+	HistoryKeepRecent     int
+	HistoryTokenCounter   string
+	DispatchWorkers       int
+	DispatchQueueSize     int
+	DispatchMaxPending    int
+	ShutdownTimeout       time.Duration
+	IdempotencyTTL        time.Duration
+	IdempotencyLease      time.Duration
+	JobWorkers            int
+	JobPollInterval       time.Duration
+	JobMaxAttempts        int
+	JobRetention          time.Duration
+	RedisURL              string
+	InstanceID            string
+	DefaultTimeZone       string
 }
 
 func Load() *Config {
@@ -21,13 +66,58 @@ func Load() *Config {
 		log.Println("Warning: .env file not found")
 	}
 
+	tenantID := getEnv("TENANT_ID", "")
+
 	return &Config{
-		ClientID:       getEnv("CLIENT_ID", ""),
-		ClientSecret:   getEnv("CLIENT_SECRET", ""),
-		TenantID:       getEnv("TENANT_ID", ""),
-		Port:           getEnv("PORT", "10000"),
-		GeminiAPIKey:   getEnv("GEMINI_API_KEY", ""),
-		AudioBridgeURL: getEnv("AUDIO_BRIDGE_URL", "http://localhost:9441"),
+		ClientID:              getEnv("CLIENT_ID", ""),
+		ClientSecret:          getEnv("CLIENT_SECRET", ""),
+		AuthMode:              getEnv("AUTH_MODE", "secret"),
+		ClientCertificatePath: getEnv("CLIENT_CERTIFICATE_PATH", ""),
+		ClientKeyPath:         getEnv("CLIENT_KEY_PATH", ""),
+		FederatedTokenFile:    getEnv("FEDERATED_TOKEN_FILE", os.Getenv("AZURE_FEDERATED_TOKEN_FILE")),
+		TenantID:              tenantID,
+		BotTokenTenant:        getEnv("BOT_TOKEN_TENANT", tenantID),
+		AllowedTenants:        getEnv("ALLOWED_TENANTS", ""),
+		DeniedTenants:         getEnv("DENIED_TENANTS", ""),
+		Port:                  getEnv("PORT", "10000"),
+		GeminiAPIKey:          getEnv("GEMINI_API_KEY", ""),
+		AudioBridgeURL:        getEnv("AUDIO_BRIDGE_URL", "http://localhost:9441"),
+		ToolDelegates:         getEnv("TOOL_DELEGATES", ""),
+		MicrosoftAppID:        getEnv("MICROSOFT_APP_ID", ""),
+		MicrosoftAppPassword:  getEnv("MICROSOFT_APP_PASSWORD", ""),
+		OAuthConnectionName:   getEnv("OAUTH_CONNECTION_NAME", ""),
+		StorageBackend:        getEnv("STORAGE_BACKEND", "memory"),
+		StoragePath:           getEnv("STORAGE_PATH", "data"),
+		TokenVaultKey:         getEnv("TOKEN_VAULT_KEY", ""),
+		TokenVaultKeyFile:     getEnv("TOKEN_VAULT_KEY_FILE", ""),
+		AdminAPIKey:           getEnv("ADMIN_API_KEY", ""),
+		GraphMaxPages:         getEnvInt("GRAPH_MAX_PAGES", 10),
+		GraphMaxItems:         getEnvInt("GRAPH_MAX_ITEMS", 200),
+		GraphTimeout:          getEnvDuration("GRAPH_TIMEOUT", 30*time.Second),
+		GraphMaxRetries:       getEnvInt("GRAPH_MAX_RETRIES", 3),
+		GraphBreakerThreshold: getEnvInt("GRAPH_BREAKER_THRESHOLD", 5),
+		GraphBreakerCooldown:  getEnvDuration("GRAPH_BREAKER_COOLDOWN", 30*time.Second),
+		Cloud:                 loadCloud(),
+		ConversationTTL:       getEnvDuration("CONVERSATION_TTL", 24*time.Hour),
+		ConversationMaxMsgs:   getEnvInt("CONVERSATION_MAX_MESSAGES", 50),
+		ConversationRetention: getEnvDuration("CONVERSATION_RETENTION", 7*24*time.Hour),
+		ConversationToolChars: getEnvInt("CONVERSATION_TOOL_RESULT_CHARS", 2000),
+		HistoryTokenBudget:    getEnvInt("HISTORY_TOKEN_BUDGET", 12000),
+		HistoryKeepRecent:     getEnvInt("HISTORY_KEEP_RECENT", 6),
+		HistoryTokenCounter:   getEnv("HISTORY_TOKEN_COUNTER", "local"),
+		DispatchWorkers:       getEnvInt("DISPATCH_WORKERS", 16),
+		DispatchQueueSize:     getEnvInt("DISPATCH_QUEUE_SIZE", 5),
+		DispatchMaxPending:    getEnvInt("DISPATCH_MAX_PENDING", 200),
+		ShutdownTimeout:       getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		IdempotencyTTL:        getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		IdempotencyLease:      getEnvDuration("IDEMPOTENCY_LEASE", 5*time.Minute),
+		JobWorkers:            getEnvInt("JOB_WORKERS", 4),
+		JobPollInterval:       getEnvDuration("JOB_POLL_INTERVAL", time.Second),
+		JobMaxAttempts:        getEnvInt("JOB_MAX_ATTEMPTS", 5),
+		JobRetention:          getEnvDuration("JOB_RETENTION", 7*24*time.Hour),
+		RedisURL:              getEnv("REDIS_URL", "redis://localhost:6379/0"),
+		InstanceID:            getEnv("INSTANCE_ID", ""),
+		DefaultTimeZone:       getEnv("DEFAULT_TIME_ZONE", "Europe/Paris"),
 	}
 }
 
@@ -37,3 +127,30 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvDuration - Durée Go ("30s", "2m")
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
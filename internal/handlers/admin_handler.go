@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"microsoft_connector/internal/services"
+	"microsoft_connector/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	apiKey      string
+	tokenVault  *services.TokenVault
+	permissions *services.PermissionChecker
+	userData    *services.UserDataService
+	jobs        *services.JobQueue
+}
+
+func NewAdminHandler(apiKey string, tokenVault *services.TokenVault, permissions *services.PermissionChecker, userData *services.UserDataService, jobs *services.JobQueue) *AdminHandler {
+	return &AdminHandler{
+		apiKey:      apiKey,
+		tokenVault:  tokenVault,
+		permissions: permissions,
+		userData:    userData,
+		jobs:        jobs,
+	}
+}
+
+// RequireAdmin - Protège les routes /admin par la clé ADMIN_API_KEY (désactivées sans clé)
+func (h *AdminHandler) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.apiKey == "" {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		key := c.GetHeader("X-Admin-Key")
+		if subtle.ConstantTimeCompare([]byte(key), []byte(h.apiKey)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+// DELETE /admin/tokens?tenant=...&user=... - Purge des jetons délégués
+func (h *AdminHandler) PurgeTokens(c *gin.Context) {
+	tenantID := c.Query("tenant")
+	userID := c.Query("user")
+
+	if userID != "" {
+		if tenantID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tenant is required when user is set"})
+			return
+		}
+		if err := h.tokenVault.Revoke(tenantID, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[Admin] Jetons révoqués pour %s/%s", tenantID, userID)
+		c.JSON(http.StatusOK, gin.H{"purged": 1})
+		return
+	}
+
+	count, err := h.tokenVault.Purge(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] %d entrées purgées du coffre (tenant: %q)", count, tenantID)
+	c.JSON(http.StatusOK, gin.H{"purged": count})
+}
+
+// GET /admin/permissions - Rôles Graph accordés et outils désactivés, par tenant
+func (h *AdminHandler) GetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"tenants": h.permissions.Reports()})
+}
+
+// DELETE /admin/conversations?id=<tenant>/<conversation> - Réinitialise une conversation
+func (h *AdminHandler) ResetConversation(c *gin.Context) {
+	conversationID := c.Query("id")
+	if conversationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		return
+	}
+	if err := h.userData.Reset(conversationID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Conversation %s réinitialisée", conversationID)
+	c.JSON(http.StatusOK, gin.H{"reset": conversationID})
+}
+
+// GET /admin/conversations/export?id=...&format=markdown|json - Télécharge l'historique
+func (h *AdminHandler) ExportConversation(c *gin.Context) {
+	conversationID := c.Query("id")
+	if conversationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		return
+	}
+	export, err := h.userData.Export(conversationID, "", c.DefaultQuery("format", "markdown"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.FileName))
+	c.Data(http.StatusOK, export.ContentType, export.Data)
+}
+
+// DELETE /admin/users/:userId?tenant=... - Effacement RGPD des données d'un utilisateur
+func (h *AdminHandler) EraseUser(c *gin.Context) {
+	tenantID := c.Query("tenant")
+	if tenantID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant is required"})
+		return
+	}
+	report, err := h.userData.Erase(tenantID, c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Données effacées pour %s/%s", tenantID, report.UserID)
+	c.JSON(http.StatusOK, report)
+}
+
+// GET /admin/jobs?state=pending|running|succeeded|dead&type=... - Contenu de la file de jobs
+func (h *AdminHandler) ListJobs(c *gin.Context) {
+	jobs, err := h.jobs.List(c.Query("state"), c.Query("type"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs, "count": len(jobs)})
+}
+
+// GET /admin/jobs/:id - Détail d'un job
+func (h *AdminHandler) GetJob(c *gin.Context) {
+	job, err := h.jobs.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// POST /admin/jobs/:id/retry - Remet en file un job en dead-letter
+func (h *AdminHandler) RetryJob(c *gin.Context) {
+	job, err := h.jobs.Retry(c.Param("id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Job %s (%s) remis en file", job.ID, job.Type)
+	c.JSON(http.StatusOK, job)
+}
+
+// DELETE /admin/jobs/:id - Annule un job en attente ou supprime un job terminé
+func (h *AdminHandler) DeleteJob(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.jobs.Delete(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Job %s supprimé", id)
+	c.JSON(http.StatusOK, gin.H{"deleted": id})
+}
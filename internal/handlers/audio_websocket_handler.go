@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"sync"
+	"time"
 
 	"microsoft_connector/internal/services"
 
@@ -21,6 +22,9 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize: 32 * 1024,
 }
 
+// Intervalle de prolongation du bail de l'appel dans l'état partagé
+const callLeaseRefresh = 30 * time.Second
+
 type AudioSession struct {
 	callID        string
 	conn          *websocket.Conn // ✅ gorilla/websocket
@@ -33,7 +37,9 @@ type AudioWebSocketHandler struct {
 	geminiService      *services.GeminiService
 	graphService       *services.GraphService
 	audioBridgeService *services.AudioBridgeService
-	sessions           map[string]*AudioSession
+	profiles           *services.ProfileStore
+	cluster            *services.Cluster
+	sessions           map[string]*AudioSession // sessions de ce réplica
 	mu                 sync.RWMutex
 }
 
@@ -41,13 +47,22 @@ func NewAudioWebSocketHandler(
 	geminiService *services.GeminiService,
 	graphService *services.GraphService,
 	audioBridgeService *services.AudioBridgeService,
+	profiles *services.ProfileStore,
+	cluster *services.Cluster,
 ) *AudioWebSocketHandler {
-	return &AudioWebSocketHandler{
+	h := &AudioWebSocketHandler{
 		geminiService:      geminiService,
 		graphService:       graphService,
 		audioBridgeService: audioBridgeService,
+		profiles:           profiles,
+		cluster:            cluster,
 		sessions:           make(map[string]*AudioSession),
 	}
+	// Le C# s'est reconnecté à un autre réplica : la session locale est obsolète
+	if _, err := cluster.OnCallClaimed(h.onCallClaimed); err != nil {
+		log.Printf("[AudioWS] Abonnement aux reprises d'appels impossible: %v", err)
+	}
+	return h
 }
 
 func (h *AudioWebSocketHandler) HandleWebSocket(c *gin.Context) {
@@ -88,7 +103,7 @@ func (h *AudioWebSocketHandler) HandleWebSocket(c *gin.Context) {
 		callID:        callID,
 		conn:          conn,
 		geminiService: h.geminiService,
-		graphService:  h.graphService,
+		graphService:  h.callGraph(callID),
 		done:          make(chan struct{}),
 	}
 
@@ -96,16 +111,61 @@ func (h *AudioWebSocketHandler) HandleWebSocket(c *gin.Context) {
 	h.sessions[callID] = session
 	h.mu.Unlock()
 
+	if err := h.cluster.ClaimCall(callID); err != nil {
+		log.Printf("[AudioWS] Enregistrement de l'appel %s impossible: %v", callID, err)
+	}
+	go h.keepCallLease(session)
+
 	defer func() {
+		close(session.done)
+		// Une reconnexion sur ce même réplica a pu remplacer la session
 		h.mu.Lock()
-		delete(h.sessions, callID)
+		current := h.sessions[callID] == session
+		if current {
+			delete(h.sessions, callID)
+		}
 		h.mu.Unlock()
+		if current {
+			h.cluster.ReleaseCall(callID)
+		}
 		log.Printf("[AudioWS] Session fermée pour callID: %s", callID)
 	}()
 
 	h.handleAudioSession(session)
 }
 
+// keepCallLease - Tant que la session vit, ce réplica reste propriétaire de l'appel
+func (h *AudioWebSocketHandler) keepCallLease(session *AudioSession) {
+	ticker := time.NewTicker(callLeaseRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-session.done:
+			return
+		case <-ticker.C:
+			if !h.cluster.RefreshCall(session.callID) {
+				log.Printf("[AudioWS] Appel %s repris par un autre réplica", session.callID)
+				session.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (h *AudioWebSocketHandler) onCallClaimed(callID, owner string) {
+	if owner == h.cluster.InstanceID() {
+		return
+	}
+	h.mu.RLock()
+	session, ok := h.sessions[callID]
+	h.mu.RUnlock()
+	if ok {
+		log.Printf("[AudioWS] Appel %s repris par %s, fermeture de la session locale", callID, owner)
+		session.conn.Close()
+	}
+}
+
 func (h *AudioWebSocketHandler) handleAudioSession(session *AudioSession) {
 	log.Printf("[AudioWS] Session audio démarrée pour callID: %s", session.callID)
 
@@ -155,7 +215,27 @@ func (h *AudioWebSocketHandler) handleAudioSession(session *AudioSession) {
 
 func (h *AudioWebSocketHandler) processAudioWithGemini(session *AudioSession, pcmAudio []byte) ([]byte, error) {
 	audioB64 := base64.StdEncoding.EncodeToString(pcmAudio)
-	return h.geminiService.SendAudioMessage(audioB64, session.callID, h.graphService)
+	return h.geminiService.SendAudioMessage(audioB64, session.callID, session.graphService)
+}
+
+// callGraph - Client Graph du tenant de l'utilisateur qui a lancé l'appel ; sans
+// association connue, le tenant d'installation
+func (h *AudioWebSocketHandler) callGraph(callID string) *services.GraphService {
+	tenantID := h.profiles.CallTenant(callID)
+	if tenantID == "" {
+		log.Printf("[AudioWS] Tenant inconnu pour callID %s, tenant d'installation utilisé", callID)
+		return h.graphService
+	}
+	return h.graphService.ForTenant(tenantID)
+}
+
+// GetCallOwners - Appels actifs de tous les réplicas : callID -> réplica
+func (h *AudioWebSocketHandler) GetCallOwners() map[string]string {
+	owners, err := h.cluster.Calls()
+	if err != nil {
+		log.Printf("[AudioWS] Lecture des appels partagés impossible: %v", err)
+	}
+	return owners
 }
 
 func (h *AudioWebSocketHandler) GetActiveSessions() []string {
@@ -2,15 +2,13 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"net/url"
-	"os"
 	"strings"
-	"sync"
 	"time"
 
 	"microsoft_connector/internal/services"
@@ -22,21 +20,62 @@ type BotHandler struct {
 	geminiService      *services.GeminiService
 	graphService       *services.GraphService
 	audioBridgeService *services.AudioBridgeService
+	authService        *services.AuthService
+	botAuth            *services.BotAuthenticator
+	profiles           *services.ProfileStore
+	userData           *services.UserDataService
+	idempotency        *services.IdempotencyStore
+	connectionName     string
 	appID              string
-	appPassword        string
-	botToken           string
-	tokenExpiry        time.Time
-	tokenMu            sync.RWMutex
+	tokenServiceURL    string // service de jetons du Bot Framework (cartes OAuth)
+	dispatcher         *Dispatcher
+	jobs               *services.JobQueue
+	notifier           services.ProactiveNotifier
+	busyReplies        chan struct{} // réponses "occupé" envoyées simultanément
 }
 
-func NewBotHandler(gs *services.GeminiService, graphService *services.GraphService, audioBridgeService *services.AudioBridgeService) *BotHandler {
-	return &BotHandler{
+// Au-delà, les réponses "occupé" ne sont plus envoyées, seulement journalisées
+const maxBusyReplies = 8
+
+func NewBotHandler(gs *services.GeminiService, graphService *services.GraphService, audioBridgeService *services.AudioBridgeService, authService *services.AuthService, botAuth *services.BotAuthenticator, profiles *services.ProfileStore, userData *services.UserDataService, idempotency *services.IdempotencyStore, appID, connectionName, tokenServiceURL string) *BotHandler {
+	h := &BotHandler{
 		geminiService:      gs,
 		graphService:       graphService,
 		audioBridgeService: audioBridgeService,
-		appID:              os.Getenv("MICROSOFT_APP_ID"),
-		appPassword:        os.Getenv("MICROSOFT_APP_PASSWORD"),
-	}
+		authService:        authService,
+		botAuth:            botAuth,
+		profiles:           profiles,
+		userData:           userData,
+		idempotency:        idempotency,
+		connectionName:     connectionName,
+		appID:              appID,
+		tokenServiceURL:    tokenServiceURL,
+		busyReplies:        make(chan struct{}, maxBusyReplies),
+	}
+	h.dispatcher = NewDispatcher(DispatchOptions{}, h.processActivity)
+	h.notifier = h
+	return h
+}
+
+// ConfigureDispatcher - Limites de concurrence, à appeler avant de servir
+func (h *BotHandler) ConfigureDispatcher(opts DispatchOptions) {
+	h.dispatcher = NewDispatcher(opts, h.processActivity)
+}
+
+// SetNotifier - Les messages proactifs passent par le relais partagé entre réplicas
+func (h *BotHandler) SetNotifier(notifier services.ProactiveNotifier) {
+	h.notifier = notifier
+}
+
+// RegisterJobs - Travaux différés du bot (connexion à une réunion créée)
+func (h *BotHandler) RegisterJobs(jobs *services.JobQueue) {
+	h.jobs = jobs
+	jobs.Register(meetingJoinJob, h.runMeetingJoin)
+}
+
+// Shutdown - Termine les activités en file avant l'arrêt du serveur
+func (h *BotHandler) Shutdown(ctx context.Context) error {
+	return h.dispatcher.Shutdown(ctx)
 }
 
 // Activity du Bot Framework
@@ -52,6 +91,9 @@ type BotActivity struct {
 	Text         string           `json:"text,omitempty"`
 	ReplyToID    string           `json:"replyToId,omitempty"`
 	Attachments  []BotAttachment  `json:"attachments,omitempty"`
+	Name         string           `json:"name,omitempty"`
+	Value        json.RawMessage  `json:"value,omitempty"`
+	ChannelData  map[string]any   `json:"channelData,omitempty"`
 }
 
 type BotAccount struct {
@@ -75,6 +117,19 @@ type BotAttachment struct {
 func (h *BotHandler) HandleMessage(c *gin.Context) {
 	log.Printf("=== Bot Message Received ===")
 
+	// Le jeton du Bot Framework est vérifié avant toute lecture de l'activité :
+	// sans lui, from.aadObjectId et le tenant seraient falsifiables.
+	// botAuth nil : aucun App ID configuré (émulateur local uniquement)
+	var claims *services.BotClaims
+	if h.botAuth != nil {
+		var err error
+		if claims, err = h.botAuth.Authenticate(c.GetHeader("Authorization")); err != nil {
+			log.Printf("⚠️ Activity rejected: %v", err)
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+	}
+
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		log.Printf("Error reading body: %v", err)
@@ -88,15 +143,76 @@ func (h *BotHandler) HandleMessage(c *gin.Context) {
 		c.Status(http.StatusBadRequest)
 		return
 	}
+	if claims != nil {
+		if err := claims.CheckActivity(activity.ServiceURL, activity.ChannelID); err != nil {
+			log.Printf("⚠️ Activity rejected: %v", err)
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// Multi-tenant : l'app peut être installée dans plusieurs tenants clients
+	if tenantID := activityTenant(&activity); !h.authService.IsTenantAllowed(tenantID) {
+		log.Printf("Activity from tenant %s rejected", tenantID)
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	// Les invoke (SSO) attendent une réponse synchrone
+	if activity.Type == "invoke" {
+		status, response := h.handleInvoke(&activity)
+		c.JSON(status, response)
+		return
+	}
 
 	// Répondre 200 OK immédiatement
 	c.Status(http.StatusOK)
 
-	// Traiter en arrière-plan
-	go h.processActivity(&activity)
+	// Teams relivre les activités dont il n'a pas reçu l'accusé : on ne les traite qu'une fois
+	activityKey := activityIdempotencyKey(&activity)
+	if !h.idempotency.Claim(activityKey) {
+		log.Printf("Duplicate activity %s ignored", activity.ID)
+		return
+	}
+
+	// Traiter en arrière-plan, dans l'ordre de la conversation
+	key := ""
+	if activity.Conversation != nil {
+		key = conversationKey(&activity)
+	}
+	if !h.dispatcher.Dispatch(key, &activity) {
+		log.Printf("⚠️ Activity %s rejected: dispatcher busy or stopping", activity.ID)
+		h.idempotency.Release(activityKey)
+		h.replyBusy(&activity)
+	}
+}
+
+// activityIdempotencyKey - Canal, conversation et ID de l'activité ("" si incomplet)
+func activityIdempotencyKey(activity *BotActivity) string {
+	if activity.Conversation == nil {
+		return ""
+	}
+	return services.ActivityKey(activity.ChannelID, conversationKey(activity), activity.ID)
+}
+
+// replyBusy - Backpressure : prévient l'utilisateur sans créer de goroutines sans limite
+func (h *BotHandler) replyBusy(activity *BotActivity) {
+	if activity.Type != "message" || activity.Conversation == nil {
+		return
+	}
+	select {
+	case h.busyReplies <- struct{}{}:
+		go func() {
+			defer func() { <-h.busyReplies }()
+			h.sendReply(activity, "⏳ NEO est très sollicité en ce moment, renvoyez votre message dans un instant.")
+		}()
+	default:
+	}
 }
 
 func (h *BotHandler) processActivity(activity *BotActivity) {
+	defer h.idempotency.Complete(activityIdempotencyKey(activity))
+
 	switch activity.Type {
 	case "message":
 		h.handleMessageActivity(activity)
@@ -124,17 +240,39 @@ func (h *BotHandler) handleMessageActivity(activity *BotActivity) {
 		h.handleVoiceLeaveRequest(activity)
 		return
 	}
+	if isSignInCommand(cleanedText) {
+		h.sendSignInCard(activity)
+		return
+	}
+	if isSignOutCommand(cleanedText) {
+		h.handleSignOut(activity)
+		return
+	}
+	if isResetCommand(cleanedText) {
+		h.handleReset(activity)
+		return
+	}
+	if isExportCommand(cleanedText) {
+		h.handleExport(activity, cleanedText)
+		return
+	}
+	if isEraseCommand(cleanedText) {
+		h.handleEraseRequest(activity)
+		return
+	}
+	if isEraseConfirmCommand(cleanedText) {
+		h.handleErase(activity)
+		return
+	}
 
 	// ← MANQUAIT : traitement texte normal via Gemini
-	conversationID := activity.Conversation.ID
-	userID := ""
-	if activity.From != nil {
-		userID = activity.From.AadObjectId
-	}
+	conversationID := conversationKey(activity)
+	caller := callerFromActivity(activity)
 
-	context := h.buildSystemContext(userID)
+	context := h.buildSystemContext(caller.UserID, cleanedText)
 
-	response, err := h.geminiService.SendMessageWithContext(cleanedText, context, conversationID, h.graphService)
+	graphService := h.graphService.ForTenant(caller.TenantID)
+	response, err := h.geminiService.SendMessageWithContext(cleanedText, context, conversationID, caller, graphService)
 	if err != nil {
 		log.Printf("Error calling Gemini: %v", err)
 		h.sendReply(activity, "❌ Erreur lors du traitement de votre message.")
@@ -144,6 +282,52 @@ func (h *BotHandler) handleMessageActivity(activity *BotActivity) {
 	h.sendReply(activity, response)
 }
 
+// callerFromActivity - Identité de l'expéditeur, utilisée pour restreindre les outils
+func callerFromActivity(activity *BotActivity) *services.CallerIdentity {
+	caller := &services.CallerIdentity{}
+	if activity.From != nil {
+		caller.UserID = activity.From.AadObjectId
+		caller.Name = activity.From.Name
+	}
+	caller.TenantID = activityTenant(activity)
+	caller.Conversation = conversationReference(activity)
+	caller.ActivityKey = activityIdempotencyKey(activity)
+	return caller
+}
+
+func conversationReference(activity *BotActivity) *services.ConversationReference {
+	if activity.Conversation == nil {
+		return nil
+	}
+	ref := &services.ConversationReference{
+		ServiceURL:     activity.ServiceURL,
+		ChannelID:      activity.ChannelID,
+		ConversationID: activity.Conversation.ID,
+		TenantID:       activityTenant(activity),
+	}
+	if activity.Recipient != nil {
+		ref.BotID = activity.Recipient.ID
+		ref.BotName = activity.Recipient.Name
+	}
+	if activity.From != nil {
+		ref.UserID = activity.From.ID
+		ref.UserName = activity.From.Name
+	}
+	return ref
+}
+
+func activityTenant(activity *BotActivity) string {
+	if activity.Conversation == nil {
+		return ""
+	}
+	return activity.Conversation.TenantID
+}
+
+// conversationKey - Les IDs de conversation ne sont uniques qu'au sein d'un tenant
+func conversationKey(activity *BotActivity) string {
+	return activityTenant(activity) + "/" + activity.Conversation.ID
+}
+
 func isCreateAndJoinCommand(text string) bool {
 	lower := strings.ToLower(strings.TrimSpace(text))
 	return lower == "appel" ||
@@ -182,7 +366,7 @@ func (h *BotHandler) handleCreateAndJoinRequest(activity *BotActivity) {
 		"onlineMeetingProvider": "teamsForBusiness",
 	}
 
-	result, err := h.graphService.Post("/users/"+userID+"/events", meetingBody)
+	result, err := h.graphService.ForTenant(activityTenant(activity)).Post("/users/"+services.PathSegment(userID)+"/events", meetingBody)
 	if err != nil {
 		h.sendReply(activity, fmt.Sprintf("❌ Impossible de créer la réunion: %v", err))
 		return
@@ -201,21 +385,63 @@ func (h *BotHandler) handleCreateAndJoinRequest(activity *BotActivity) {
 
 	log.Printf("[AudioBridge] ✅ Réunion créée via /events. joinURL: %s", joinURL)
 
+	// Laisser à l'utilisateur le temps d'entrer avant NEO ; le job survit à un redémarrage
+	payload := meetingJoinPayload{JoinURL: joinURL, UserID: userID, TenantID: activityTenant(activity), Conversation: conversationReference(activity)}
+	opts := services.EnqueueOptions{RunAt: time.Now().Add(meetingJoinDelay), MaxAttempts: meetingJoinAttempts}
+	if _, err := h.jobs.Enqueue(meetingJoinJob, payload, opts); err != nil {
+		log.Printf("[AudioBridge] Planification de JoinCall impossible: %v", err)
+		h.sendReply(activity, fmt.Sprintf("✅ Réunion créée, mais NEO ne pourra pas la rejoindre automatiquement.\n\n[🎙️ Rejoindre l'appel](%s)", joinURL))
+		return
+	}
+
 	h.sendReply(activity, fmt.Sprintf(
 		"✅ Réunion créée ! Rejoins d'abord, NEO arrive dans 15 secondes.\n\n[🎙️ Rejoindre l'appel avec NEO](%s)", joinURL,
 	))
+}
 
-	time.Sleep(15 * time.Second)
+const (
+	meetingJoinJob      = "meeting.join"
+	meetingJoinDelay    = 15 * time.Second
+	meetingJoinAttempts = 3
+)
+
+type meetingJoinPayload struct {
+	JoinURL      string                          `json:"join_url"`
+	UserID       string                          `json:"user_id"`
+	TenantID     string                          `json:"tenant_id,omitempty"`
+	Conversation *services.ConversationReference `json:"conversation,omitempty"`
+}
+
+// runMeetingJoin - NEO rejoint la réunion créée par "crée une réunion" ; l'échec
+// n'est annoncé qu'à la dernière tentative
+func (h *BotHandler) runMeetingJoin(job *services.Job) error {
+	var payload meetingJoinPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
 
 	// ✅ DisplayName vide = bot rejoint comme application (pas lobby)
-	_, err = h.audioBridgeService.JoinCall(joinURL, "")
+	resp, err := h.audioBridgeService.JoinCall(payload.JoinURL, "")
 	if err != nil {
 		log.Printf("[AudioBridge] Erreur JoinCall: %v", err)
-		h.sendReply(activity, fmt.Sprintf("❌ NEO n'a pas pu rejoindre: %v", err))
-		return
+		if job.LastAttempt() {
+			h.notifyJob(payload.Conversation, fmt.Sprintf("❌ NEO n'a pas pu rejoindre: %v", err))
+		}
+		return err
 	}
+	h.profiles.BindCall(resp.CallID, payload.TenantID, payload.UserID)
 
-	h.sendReply(activity, "🎙️ NEO a rejoint la réunion !")
+	h.notifyJob(payload.Conversation, "🎙️ NEO a rejoint la réunion !")
+	return nil
+}
+
+func (h *BotHandler) notifyJob(ref *services.ConversationReference, text string) {
+	if ref == nil {
+		return
+	}
+	if err := h.notifier.Notify(ref, text); err != nil {
+		log.Printf("[Jobs] Notification impossible: %v", err)
+	}
 }
 
 func isJoinVoiceCommand(text string) bool {
@@ -248,6 +474,8 @@ func (h *BotHandler) handleVoiceJoinRequest(activity *BotActivity, text string)
 		h.sendReply(activity, fmt.Sprintf("❌ Impossible de rejoindre: %v", err))
 		return
 	}
+	caller := callerFromActivity(activity)
+	h.profiles.BindCall(resp.CallID, caller.TenantID, caller.UserID)
 
 	h.sendReply(activity, fmt.Sprintf("✅ J'ai rejoint la réunion ! Je vous écoute. (ID: %s)", resp.CallID))
 }
@@ -276,112 +504,78 @@ func extractMeetingURL(text string) string {
 }
 
 func (h *BotHandler) sendReply(activity *BotActivity, text string) {
-	token, err := h.getBotToken()
-	if err != nil {
-		log.Printf("Error getting bot token: %v", err)
-		return
-	}
+	h.sendActivity(activity, BotActivity{Type: "message", Text: text})
+}
 
-	replyActivity := BotActivity{
-		Type:         "message",
-		Text:         text,
-		From:         activity.Recipient,
-		Recipient:    activity.From,
-		Conversation: activity.Conversation,
-		ReplyToID:    activity.ID,
+// sendActivity - Envoie une activité (texte, cartes...) en réponse à activity
+func (h *BotHandler) sendActivity(activity *BotActivity, replyActivity BotActivity) {
+	replyActivity.From = activity.Recipient
+	replyActivity.Recipient = activity.From
+	replyActivity.Conversation = activity.Conversation
+	replyActivity.ReplyToID = activity.ID
+	if tenantID := activityTenant(activity); tenantID != "" {
+		replyActivity.ChannelData = map[string]any{"tenant": map[string]string{"id": tenantID}}
 	}
 
-	jsonBody, _ := json.Marshal(replyActivity)
-
 	replyURL := fmt.Sprintf("%sv3/conversations/%s/activities/%s",
 		activity.ServiceURL,
 		activity.Conversation.ID,
 		activity.ID,
 	)
 
-	req, _ := http.NewRequest("POST", replyURL, bytes.NewBuffer(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
+	if err := h.postActivity(replyURL, replyActivity); err != nil {
 		log.Printf("Error sending reply: %v", err)
-		return
 	}
-	defer resp.Body.Close()
-
-	log.Printf("Reply sent, status: %d", resp.StatusCode)
 }
 
-func (h *BotHandler) getBotToken() (string, error) {
-	h.tokenMu.RLock()
-	if h.botToken != "" && time.Now().Before(h.tokenExpiry) {
-		defer h.tokenMu.RUnlock()
-		return h.botToken, nil
+// Notify - Message proactif dans une conversation, hors d'un tour (services.ProactiveNotifier)
+func (h *BotHandler) Notify(ref *services.ConversationReference, text string) error {
+	activity := BotActivity{
+		Type:         "message",
+		Text:         text,
+		From:         &BotAccount{ID: ref.BotID, Name: ref.BotName},
+		Recipient:    &BotAccount{ID: ref.UserID, Name: ref.UserName},
+		Conversation: &BotConversation{ID: ref.ConversationID, TenantID: ref.TenantID},
 	}
-	h.tokenMu.RUnlock()
-
-	h.tokenMu.Lock()
-	defer h.tokenMu.Unlock()
-
-	data := url.Values{}
-	data.Set("grant_type", "client_credentials")
-	data.Set("client_id", h.appID)
-	data.Set("client_secret", h.appPassword)
-	data.Set("scope", "https://api.botframework.com/.default")
-
-	tenantID := os.Getenv("TENANT_ID")
-	tokenURL := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", tenantID)
-
-	log.Printf("=== TOKEN REQUEST ===")
-	log.Printf("URL: %s", tokenURL)
-
-	resp, err := http.Post(
-		tokenURL,
-		"application/x-www-form-urlencoded",
-		strings.NewReader(data.Encode()),
-	)
-	if err != nil {
-		return "", err
+	if ref.TenantID != "" {
+		activity.ChannelData = map[string]any{"tenant": map[string]string{"id": ref.TenantID}}
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	serviceURL := ref.ServiceURL
+	if !strings.HasSuffix(serviceURL, "/") {
+		serviceURL += "/"
 	}
+	return h.postActivity(fmt.Sprintf("%sv3/conversations/%s/activities", serviceURL, ref.ConversationID), activity)
+}
 
-	log.Printf("=== TOKEN RESPONSE Status: %d ===", resp.StatusCode)
-
-	if len(body) == 0 {
-		return "", fmt.Errorf("empty token response")
+func (h *BotHandler) postActivity(activityURL string, activity BotActivity) error {
+	token, err := h.getBotToken()
+	if err != nil {
+		return fmt.Errorf("failed to get bot token: %w", err)
 	}
 
-	var tokenResp struct {
-		AccessToken string `json:"access_token"`
-		ExpiresIn   int    `json:"expires_in"`
-		Error       string `json:"error"`
-		ErrorDesc   string `json:"error_description"`
-	}
+	jsonBody, _ := json.Marshal(activity)
 
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return "", fmt.Errorf("failed to parse token response: %w", err)
-	}
+	req, _ := http.NewRequest("POST", activityURL, bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
 
-	if tokenResp.Error != "" {
-		return "", fmt.Errorf("token error: %s - %s", tokenResp.Error, tokenResp.ErrorDesc)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
-	if tokenResp.AccessToken == "" {
-		return "", fmt.Errorf("no access token in response")
+	log.Printf("Reply sent, status: %d", resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("bot connector error %d", resp.StatusCode)
 	}
+	return nil
+}
 
-	h.botToken = tokenResp.AccessToken
-	h.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn-60) * time.Second)
-
-	log.Printf("Bot token obtained, expires in %d seconds", tokenResp.ExpiresIn)
-	return h.botToken, nil
+func (h *BotHandler) getBotToken() (string, error) {
+	return h.authService.GetBotToken()
 }
 
 func (h *BotHandler) cleanMention(text string) string {
@@ -391,10 +585,16 @@ func (h *BotHandler) cleanMention(text string) string {
 	return text
 }
 
-func (h *BotHandler) buildSystemContext(userID string) string {
-	return fmt.Sprintf(`Tu es NEO, un assistant IA Microsoft 365 intégré dans Teams.
+// buildSystemContext - Consignes de NEO, complétées par la mémoire de l'utilisateur
+func (h *BotHandler) buildSystemContext(userID, message string) string {
+	// Résolution des dates relatives ("demain à 9h") dans le fuseau de l'utilisateur
+	loc := h.profiles.Location(userID)
+	now := time.Now().In(loc)
+	return h.profiles.WithProfile(fmt.Sprintf(`Tu es NEO, un assistant IA Microsoft 365 intégré dans Teams.
 Tu aides les utilisateurs avec leurs emails, calendrier, réunions et tâches.
 Réponds toujours en français de manière concise et professionnelle.
 Utilise les outils disponibles pour accéder aux données Microsoft 365.
-ID utilisateur courant : %s`, userID)
+Les outils de messagerie et de calendrier agissent par défaut sur le compte de l'utilisateur courant.
+ID utilisateur courant : %s
+Date et heure actuelles de l'utilisateur : %s (%s)`, userID, now.Format("Monday 2006-01-02 15:04"), loc), userID, message)
 }
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+func isResetCommand(text string) bool {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	return lower == "reset" ||
+		lower == "/reset" ||
+		lower == "réinitialise" ||
+		lower == "nouvelle conversation" ||
+		lower == "oublie cette conversation"
+}
+
+func isExportCommand(text string) bool {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	return strings.HasPrefix(lower, "/export") ||
+		strings.HasPrefix(lower, "exporte la conversation") ||
+		strings.HasPrefix(lower, "export conversation")
+}
+
+func isEraseCommand(text string) bool {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	return lower == "/erase" ||
+		lower == "efface mes données" ||
+		lower == "supprime mes données" ||
+		lower == "erase my data"
+}
+
+func isEraseConfirmCommand(text string) bool {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	return lower == "/erase confirm" ||
+		lower == "confirme l'effacement" ||
+		lower == "confirm erase"
+}
+
+func (h *BotHandler) handleReset(activity *BotActivity) {
+	if err := h.userData.Reset(conversationKey(activity)); err != nil {
+		log.Printf("[Privacy] Erreur reset: %v", err)
+		h.sendReply(activity, "❌ Impossible de réinitialiser la conversation.")
+		return
+	}
+	h.sendReply(activity, "🧹 Conversation réinitialisée : NEO repart de zéro. Votre mémoire à long terme est conservée.")
+}
+
+// handleExport - "/export [json] [mail]" : Markdown par défaut, déposé dans OneDrive
+func (h *BotHandler) handleExport(activity *BotActivity, text string) {
+	lower := strings.ToLower(text)
+	format := "markdown"
+	if strings.Contains(lower, "json") {
+		format = "json"
+	}
+
+	caller := callerFromActivity(activity)
+	if caller.UserID == "" {
+		h.sendReply(activity, "❌ Impossible d'identifier l'utilisateur.")
+		return
+	}
+
+	export, err := h.userData.Export(conversationKey(activity), caller.UserID, format)
+	if err != nil {
+		h.sendReply(activity, fmt.Sprintf("❌ Export impossible: %v", err))
+		return
+	}
+
+	graphService := h.graphService.ForTenant(caller.TenantID)
+	if strings.Contains(lower, "mail") {
+		address, err := h.userData.SendByEmail(graphService, caller.UserID, export)
+		if err != nil {
+			log.Printf("[Privacy] Erreur export par email: %v", err)
+			h.sendReply(activity, fmt.Sprintf("❌ Envoi de l'export impossible: %v", err))
+			return
+		}
+		h.sendReply(activity, fmt.Sprintf("📧 Export envoyé à %s (%s).", address, export.FileName))
+		return
+	}
+
+	link, err := h.userData.SendToOneDrive(graphService, caller.UserID, export)
+	if err != nil {
+		log.Printf("[Privacy] Erreur export OneDrive: %v", err)
+		h.sendReply(activity, fmt.Sprintf("❌ Dépôt de l'export impossible: %v", err))
+		return
+	}
+	h.sendReply(activity, fmt.Sprintf("📁 Export déposé dans votre OneDrive : [%s](%s)", export.FileName, link))
+}
+
+func (h *BotHandler) handleEraseRequest(activity *BotActivity) {
+	caller := callerFromActivity(activity)
+	if caller.UserID == "" {
+		h.sendReply(activity, "❌ Impossible d'identifier l'utilisateur.")
+		return
+	}
+	if err := h.userData.RequestErase(caller.TenantID, caller.UserID, conversationKey(activity)); err != nil {
+		log.Printf("[Privacy] Erreur demande d'effacement: %v", err)
+		h.sendReply(activity, "❌ Impossible d'enregistrer la demande d'effacement.")
+		return
+	}
+	h.sendReply(activity, "⚠️ Cette commande efface définitivement vos conversations avec NEO, votre mémoire et vos jetons de connexion. Dans les conversations de groupe, seuls vos messages et les réponses de NEO à vos demandes sont retirés.\n\nRépondez **confirme l'effacement** dans les 5 minutes pour continuer.")
+}
+
+// handleErase - Effacement RGPD de tout ce que NEO conserve sur l'utilisateur,
+// seulement après une demande récente dans la même conversation
+func (h *BotHandler) handleErase(activity *BotActivity) {
+	caller := callerFromActivity(activity)
+	if caller.UserID == "" || !h.userData.ConfirmErase(caller.TenantID, caller.UserID, conversationKey(activity)) {
+		h.sendReply(activity, "Aucune demande d'effacement en attente. Envoyez d'abord **efface mes données**.")
+		return
+	}
+	report, err := h.userData.Erase(caller.TenantID, caller.UserID)
+	if err != nil {
+		log.Printf("[Privacy] Erreur effacement: %v", err)
+		h.sendReply(activity, "❌ L'effacement a échoué, réessayez ou contactez votre administrateur.")
+		return
+	}
+	h.signOutTokenService(activity)
+
+	h.sendReply(activity, fmt.Sprintf("🗑️ Vos données ont été effacées : %d conversation(s), vos messages dans %d conversation(s) de groupe, %d appel(s) vocal(aux), %d rappel(s), votre mémoire et vos jetons.", report.Conversations, report.Shared, report.Calls, report.Reminders))
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"microsoft_connector/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type tokenExchangeRequest struct {
+	ID             string `json:"id"`
+	ConnectionName string `json:"connectionName"`
+	Token          string `json:"token"`
+}
+
+type tokenExchangeInvokeResponse struct {
+	ID             string `json:"id"`
+	ConnectionName string `json:"connectionName"`
+	FailureDetail  string `json:"failureDetail,omitempty"`
+}
+
+type signInResource struct {
+	SignInLink            string `json:"signInLink"`
+	TokenExchangeResource *struct {
+		ID         string `json:"id"`
+		URI        string `json:"uri"`
+		ProviderID string `json:"providerId,omitempty"`
+	} `json:"tokenExchangeResource,omitempty"`
+}
+
+func isSignInCommand(text string) bool {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	return lower == "connexion" ||
+		lower == "se connecter" ||
+		lower == "sign in" ||
+		lower == "login"
+}
+
+func isSignOutCommand(text string) bool {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	return lower == "déconnexion" ||
+		lower == "se déconnecter" ||
+		lower == "sign out" ||
+		lower == "logout"
+}
+
+func (h *BotHandler) handleInvoke(activity *BotActivity) (int, any) {
+	log.Printf("=== Bot Invoke: %s ===", activity.Name)
+
+	switch activity.Name {
+	case "signin/tokenExchange":
+		return h.handleTokenExchange(activity)
+	case "signin/verifyState":
+		return h.handleVerifyState(activity)
+	default:
+		return http.StatusOK, gin.H{}
+	}
+}
+
+// handleTokenExchange - SSO Teams : le client envoie un jeton émis pour l'app du bot,
+// échangé ici contre un jeton Graph délégué (on-behalf-of)
+func (h *BotHandler) handleTokenExchange(activity *BotActivity) (int, any) {
+	var req tokenExchangeRequest
+	if err := json.Unmarshal(activity.Value, &req); err != nil {
+		return http.StatusBadRequest, gin.H{}
+	}
+
+	response := tokenExchangeInvokeResponse{ID: req.ID, ConnectionName: h.connectionName}
+
+	caller := callerFromActivity(activity)
+	if err := h.authService.ExchangeOnBehalfOf(caller.TenantID, caller.UserID, req.Token); err != nil {
+		log.Printf("[SSO] Échange on-behalf-of impossible pour %s: %v", caller.UserID, err)
+		// 412 : Teams retombe sur la carte OAuth classique
+		response.FailureDetail = err.Error()
+		return http.StatusPreconditionFailed, response
+	}
+
+	go h.sendReply(activity, "✅ Vous êtes connecté, NEO peut maintenant agir en votre nom.")
+	return http.StatusOK, response
+}
+
+// handleVerifyState - Retour de la carte OAuth : le code permet de récupérer le jeton
+// auprès du service de jetons, puis de l'échanger comme un jeton SSO
+func (h *BotHandler) handleVerifyState(activity *BotActivity) (int, any) {
+	var req struct {
+		State string `json:"state"`
+	}
+	json.Unmarshal(activity.Value, &req)
+
+	token, err := h.getTokenServiceToken(activity, req.State)
+	if err == nil {
+		caller := callerFromActivity(activity)
+		err = h.authService.ExchangeOnBehalfOf(caller.TenantID, caller.UserID, token)
+	}
+	if errors.Is(err, services.ErrTokenUserMismatch) {
+		log.Printf("[SSO] Connexion refusée: %v", err)
+		go h.sendReply(activity, "❌ Le compte utilisé pour la connexion n'est pas votre compte Teams, reconnectez-vous avec celui-ci.")
+		return http.StatusOK, gin.H{}
+	}
+	if err != nil {
+		log.Printf("[SSO] Vérification de la connexion échouée: %v", err)
+		go h.sendReply(activity, "❌ La connexion a échoué, réessayez en écrivant « connexion ».")
+		return http.StatusOK, gin.H{}
+	}
+
+	go h.sendReply(activity, "✅ Vous êtes connecté, NEO peut maintenant agir en votre nom.")
+	return http.StatusOK, gin.H{}
+}
+
+// sendSignInCard - Carte OAuth ; avec tokenExchangeResource Teams tente d'abord le SSO
+func (h *BotHandler) sendSignInCard(activity *BotActivity) {
+	if h.connectionName == "" {
+		h.sendReply(activity, "❌ La connexion utilisateur n'est pas configurée (OAUTH_CONNECTION_NAME).")
+		return
+	}
+
+	resource, err := h.getSignInResource(activity)
+	if err != nil {
+		log.Printf("[SSO] Erreur GetSignInResource: %v", err)
+		h.sendReply(activity, "❌ Impossible de préparer la connexion.")
+		return
+	}
+
+	card := map[string]any{
+		"text":           "Connectez-vous pour autoriser NEO à accéder à votre calendrier et à vos groupes.",
+		"connectionName": h.connectionName,
+		"buttons": []map[string]any{
+			{"type": "signin", "title": "Se connecter", "value": resource.SignInLink},
+		},
+	}
+	if resource.TokenExchangeResource != nil {
+		card["tokenExchangeResource"] = resource.TokenExchangeResource
+	}
+
+	h.sendActivity(activity, BotActivity{
+		Type: "message",
+		Attachments: []BotAttachment{{
+			ContentType: "application/vnd.microsoft.card.oauth",
+			Content:     card,
+		}},
+	})
+}
+
+func (h *BotHandler) handleSignOut(activity *BotActivity) {
+	caller := callerFromActivity(activity)
+	h.authService.SignOutUser(caller.TenantID, caller.UserID)
+	h.signOutTokenService(activity)
+
+	h.sendReply(activity, "👋 Vous êtes déconnecté.")
+}
+
+// signOutTokenService - Supprime aussi le jeton conservé par le service de jetons du Bot Framework
+func (h *BotHandler) signOutTokenService(activity *BotActivity) {
+	if h.connectionName == "" || activity.From == nil {
+		return
+	}
+	query := url.Values{}
+	query.Set("userId", activity.From.ID)
+	query.Set("connectionName", h.connectionName)
+	query.Set("channelId", activity.ChannelID)
+	if _, err := h.tokenServiceRequest("DELETE", "/api/usertoken/SignOut", query); err != nil {
+		log.Printf("[SSO] Erreur SignOut: %v", err)
+	}
+}
+
+func (h *BotHandler) getSignInResource(activity *BotActivity) (*signInResource, error) {
+	state, _ := json.Marshal(map[string]any{
+		"ConnectionName": h.connectionName,
+		"Conversation": map[string]any{
+			"activityId":   activity.ID,
+			"user":         activity.From,
+			"bot":          activity.Recipient,
+			"conversation": activity.Conversation,
+			"channelId":    activity.ChannelID,
+			"serviceUrl":   activity.ServiceURL,
+		},
+		"MsAppId": h.appID,
+	})
+
+	query := url.Values{}
+	query.Set("state", base64.StdEncoding.EncodeToString(state))
+
+	body, err := h.tokenServiceRequest("GET", "/api/botsignin/GetSignInResource", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var resource signInResource
+	if err := json.Unmarshal(body, &resource); err != nil {
+		return nil, fmt.Errorf("failed to parse sign-in resource: %w", err)
+	}
+	if resource.SignInLink == "" {
+		return nil, fmt.Errorf("no sign-in link in response")
+	}
+	return &resource, nil
+}
+
+func (h *BotHandler) getTokenServiceToken(activity *BotActivity, code string) (string, error) {
+	if activity.From == nil {
+		return "", fmt.Errorf("missing sender")
+	}
+
+	query := url.Values{}
+	query.Set("userId", activity.From.ID)
+	query.Set("connectionName", h.connectionName)
+	query.Set("channelId", activity.ChannelID)
+	if code != "" {
+		query.Set("code", code)
+	}
+
+	body, err := h.tokenServiceRequest("GET", "/api/usertoken/GetToken", query)
+	if err != nil {
+		return "", err
+	}
+
+	var tokenResp struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil || tokenResp.Token == "" {
+		return "", fmt.Errorf("no token returned by token service")
+	}
+	return tokenResp.Token, nil
+}
+
+func (h *BotHandler) tokenServiceRequest(method, path string, query url.Values) ([]byte, error) {
+	token, err := h.getBotToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot token: %w", err)
+	}
+
+	req, err := http.NewRequest(method, h.tokenServiceURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("token service error %d: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// DispatchOptions - Limites du traitement des activités ; 0 = valeur par défaut
+type DispatchOptions struct {
+	Workers    int // activités traitées simultanément, toutes conversations confondues
+	QueueSize  int // activités en attente par conversation
+	MaxPending int // activités en attente au total
+}
+
+// Dispatcher - Une file ordonnée par conversation : les messages d'une même
+// conversation sont traités un par un, dans l'ordre de réception, sous une
+// limite globale de concurrence
+type Dispatcher struct {
+	handle     func(*BotActivity)
+	slots      chan struct{}
+	queueSize  int
+	maxPending int
+
+	mu      sync.Mutex
+	queues  map[string][]*BotActivity // présente tant qu'un drain est actif
+	pending int
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+func NewDispatcher(opts DispatchOptions, handle func(*BotActivity)) *Dispatcher {
+	if opts.Workers <= 0 {
+		opts.Workers = 16
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 5
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = 200
+	}
+	return &Dispatcher{
+		handle:     handle,
+		slots:      make(chan struct{}, opts.Workers),
+		queueSize:  opts.QueueSize,
+		maxPending: opts.MaxPending,
+		queues:     make(map[string][]*BotActivity),
+	}
+}
+
+// Dispatch - Met l'activité en file ; false si la file est pleine (backpressure)
+// ou si le dispatcher est arrêté
+func (d *Dispatcher) Dispatch(key string, activity *BotActivity) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return false
+	}
+	queue, draining := d.queues[key]
+	if len(queue) >= d.queueSize || d.pending >= d.maxPending {
+		return false
+	}
+
+	d.queues[key] = append(queue, activity)
+	d.pending++
+	if !draining {
+		d.wg.Add(1)
+		go d.drain(key)
+	}
+	return true
+}
+
+// Shutdown - Refuse les nouvelles activités et attend la fin des files en cours
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	pending := d.pending
+	d.mu.Unlock()
+	log.Printf("[Dispatcher] Arrêt: %d activité(s) en attente", pending)
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drain - Traite la file d'une conversation jusqu'à ce qu'elle soit vide
+func (d *Dispatcher) drain(key string) {
+	defer d.wg.Done()
+
+	for {
+		d.mu.Lock()
+		queue := d.queues[key]
+		if len(queue) == 0 {
+			delete(d.queues, key)
+			d.mu.Unlock()
+			return
+		}
+		activity := queue[0]
+		d.queues[key] = queue[1:]
+		d.pending--
+		d.mu.Unlock()
+
+		d.slots <- struct{}{}
+		d.run(activity)
+		<-d.slots
+	}
+}
+
+// run - Une panique ne doit pas bloquer la file de la conversation
+func (d *Dispatcher) run(activity *BotActivity) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ [Dispatcher] Panique pendant le traitement de %s: %v\n%s", activity.ID, r, debug.Stack())
+		}
+	}()
+	d.handle(activity)
+}
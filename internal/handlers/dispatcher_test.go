@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func activity(key string, n int) *BotActivity {
+	return &BotActivity{ID: fmt.Sprintf("%s/%d", key, n)}
+}
+
+func TestDispatcherOrderPerKeyUnderConcurrencyLimit(t *testing.T) {
+	const workers, perKey = 2, 5
+	keys := []string{"conv-a", "conv-b", "conv-c", "conv-d"}
+
+	var (
+		mu        sync.Mutex
+		seen      = map[string][]string{}
+		active    int
+		maxActive int
+		perActive = map[string]int{}
+	)
+	d := NewDispatcher(DispatchOptions{Workers: workers, QueueSize: perKey}, func(a *BotActivity) {
+		key := a.ID[:strings.Index(a.ID, "/")]
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		perActive[key]++
+		if perActive[key] > 1 {
+			t.Errorf("%s processed concurrently", key)
+		}
+		seen[key] = append(seen[key], a.ID)
+		mu.Unlock()
+
+		time.Sleep(2 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		perActive[key]--
+		mu.Unlock()
+	})
+
+	// Activités entrelacées : a/0, b/0, c/0, d/0, a/1...
+	for n := range perKey {
+		for _, key := range keys {
+			if !d.Dispatch(key, activity(key, n)) {
+				t.Fatalf("%s/%d rejected", key, n)
+			}
+		}
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, key := range keys {
+		if len(seen[key]) != perKey {
+			t.Fatalf("%s: %v", key, seen[key])
+		}
+		for n, id := range seen[key] {
+			if want := fmt.Sprintf("%s/%d", key, n); id != want {
+				t.Fatalf("%s out of order: %v", key, seen[key])
+			}
+		}
+	}
+	if maxActive > workers {
+		t.Fatalf("%d activities ran concurrently, limit %d", maxActive, workers)
+	}
+	if maxActive < workers {
+		t.Errorf("distinct conversations never ran in parallel (max %d)", maxActive)
+	}
+}
+
+func TestDispatcherBackpressure(t *testing.T) {
+	started, release := make(chan string, 10), make(chan struct{})
+	d := NewDispatcher(DispatchOptions{Workers: 1, QueueSize: 2, MaxPending: 3}, func(a *BotActivity) {
+		started <- a.ID
+		<-release
+	})
+
+	// a/0 est en cours : il ne compte plus dans les files d'attente
+	d.Dispatch("a", activity("a", 0))
+	<-started
+
+	for n := 1; n <= 2; n++ {
+		if !d.Dispatch("a", activity("a", n)) {
+			t.Fatalf("a/%d rejected", n)
+		}
+	}
+	if d.Dispatch("a", activity("a", 3)) {
+		t.Fatal("conversation queue limit not enforced")
+	}
+	if !d.Dispatch("b", activity("b", 0)) {
+		t.Fatal("other conversation rejected")
+	}
+	if d.Dispatch("c", activity("c", 0)) {
+		t.Fatal("global pending limit not enforced")
+	}
+
+	close(release)
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(started) != 3 {
+		t.Fatalf("%d activities processed after a/0, want 3", len(started))
+	}
+}
+
+func TestDispatcherRecoversFromPanic(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		done []string
+	)
+	d := NewDispatcher(DispatchOptions{}, func(a *BotActivity) {
+		if a.ID == "a/0" {
+			panic("boom")
+		}
+		mu.Lock()
+		done = append(done, a.ID)
+		mu.Unlock()
+	})
+	d.Dispatch("a", activity("a", 0))
+	d.Dispatch("a", activity("a", 1))
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0] != "a/1" {
+		t.Fatalf("queue blocked by a panic: %v", done)
+	}
+}
+
+func TestDispatcherShutdownDrains(t *testing.T) {
+	release := make(chan struct{})
+	var (
+		mu   sync.Mutex
+		done int
+	)
+	d := NewDispatcher(DispatchOptions{Workers: 1}, func(a *BotActivity) {
+		<-release
+		mu.Lock()
+		done++
+		mu.Unlock()
+	})
+	for n := range 3 {
+		d.Dispatch("a", activity("a", n))
+	}
+	d.Dispatch("b", activity("b", 0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown returned %v with activities pending", err)
+	}
+	if d.Dispatch("c", activity("c", 0)) {
+		t.Fatal("activity accepted after shutdown")
+	}
+
+	// Les activités déjà acceptées sont toutes traitées
+	close(release)
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if done != 4 {
+		t.Fatalf("%d activities processed, want 4", done)
+	}
+}
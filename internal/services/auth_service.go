@@ -1,92 +1,227 @@
 package services
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"net/url"
-	"sync"
+	"strings"
 	"time"
 
 	"microsoft_connector/config"
+	"microsoft_connector/internal/storage"
+
+	"golang.org/x/sync/singleflight"
 )
 
+// ErrUserSignInRequired - Aucun jeton délégué exploitable pour l'utilisateur
+var ErrUserSignInRequired = errors.New("user sign-in required")
+
+// ErrTenantNotAllowed - Tenant refusé par ALLOWED_TENANTS / DENIED_TENANTS
+var ErrTenantNotAllowed = errors.New("tenant not allowed")
+
+// ErrTokenUserMismatch - Le jeton délégué obtenu appartient à un autre utilisateur
+// ou à un autre tenant que l'appelant
+var ErrTokenUserMismatch = errors.New("delegated token does not belong to the caller")
+
 type AuthService struct {
-	config      *config.Config
-	accessToken string
-	expiresAt   time.Time
-	mu          sync.RWMutex
-}
+	config  *config.Config
+	tenants *TenantPolicy
+
+	// App Graph (CLIENT_ID) et app du bot (MICROSOFT_APP_ID : Bot Framework et
+	// cible des jetons SSO échangés en on-behalf-of)
+	graphCredentials *CredentialProvider
+	botCredentials   *CredentialProvider
 
-type tokenResponse struct {
-	AccessToken string `json:"access_token"`
-	ExpiresIn   int    `json:"expires_in"`
-	TokenType   string `json:"token_type"`
+	// Jetons délégués obtenus via on-behalf-of
+	vault         *TokenVault
+	userRefreshes singleflight.Group
 }
 
-func NewAuthService(cfg *config.Config) *AuthService {
+func NewAuthService(cfg *config.Config, vault *TokenVault, graphCredentials, botCredentials *CredentialProvider) *AuthService {
 	return &AuthService{
-		config: cfg,
+		config:           cfg,
+		tenants:          NewTenantPolicy(cfg.TenantID, cfg.AllowedTenants, cfg.DeniedTenants),
+		graphCredentials: graphCredentials,
+		botCredentials:   botCredentials,
+		vault:            vault,
+	}
+}
+
+// IsTenantAllowed - Indique si NEO peut opérer pour ce tenant
+func (s *AuthService) IsTenantAllowed(tenantID string) bool {
+	return s.tenants.Allowed(s.resolveTenant(tenantID))
+}
+
+// resolveTenant - Sans tenant (émulateur, appels internes), on utilise TENANT_ID
+func (s *AuthService) resolveTenant(tenantID string) string {
+	if tenantID == "" {
+		return s.config.TenantID
 	}
+	return tenantID
 }
 
+// GetAccessToken - Jeton applicatif Graph du tenant principal
 func (s *AuthService) GetAccessToken() (string, error) {
-	s.mu.RLock()
-	if s.accessToken != "" && time.Now().Before(s.expiresAt.Add(-5*time.Minute)) {
-		token := s.accessToken
-		s.mu.RUnlock()
-		return token, nil
+	return s.GetTenantAccessToken("")
+}
+
+// GetTenantAccessToken - Jeton applicatif Graph pour le tenant de l'activité
+func (s *AuthService) GetTenantAccessToken(tenantID string) (string, error) {
+	tenantID = s.resolveTenant(tenantID)
+	if !s.tenants.Allowed(tenantID) {
+		return "", fmt.Errorf("%w: %s", ErrTenantNotAllowed, tenantID)
 	}
-	s.mu.RUnlock()
+	return s.graphCredentials.GetToken(tenantID, s.config.Cloud.GraphScope())
+}
 
-	return s.refreshToken()
+// GetBotToken - Jeton Bot Framework pour envoyer les réponses du bot
+//
+// Un bot mono-tenant s'authentifie dans son tenant, un bot multi-tenant dans
+// "botframework.com" (BOT_TOKEN_TENANT).
+func (s *AuthService) GetBotToken() (string, error) {
+	return s.botCredentials.GetToken(s.config.BotTokenTenant, s.config.Cloud.BotScope)
 }
 
-func (s *AuthService) refreshToken() (string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// ExchangeOnBehalfOf - Échange un jeton SSO Teams contre un jeton Graph délégué
+func (s *AuthService) ExchangeOnBehalfOf(tenantID, userID, assertion string) error {
+	if userID == "" || assertion == "" {
+		return fmt.Errorf("user id and assertion are required")
+	}
+	tenantID = s.resolveTenant(tenantID)
+	if !s.tenants.Allowed(tenantID) {
+		return fmt.Errorf("%w: %s", ErrTenantNotAllowed, tenantID)
+	}
 
-	// Double-check après avoir obtenu le lock
-	if s.accessToken != "" && time.Now().Before(s.expiresAt.Add(-5*time.Minute)) {
-		return s.accessToken, nil
+	// L'assertion SSO est émise pour l'application du bot (webApplicationInfo)
+	data := url.Values{}
+	data.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	data.Set("requested_token_use", "on_behalf_of")
+	data.Set("assertion", assertion)
+	data.Set("scope", s.delegatedScope())
+
+	log.Printf("=== OBO TOKEN REQUEST === tenant: %s, user: %s", tenantID, userID)
+
+	tokenResp, err := s.botCredentials.RequestToken(tenantID, data)
+	if err != nil {
+		return err
 	}
 
-	tokenURL := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", s.config.TenantID)
+	// L'assertion vient du client : le jeton obtenu doit être celui de l'appelant,
+	// sinon il serait rangé sous l'identité d'un autre utilisateur
+	oid, tid, err := delegatedIdentity(tokenResp.AccessToken)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(oid, userID) || !strings.EqualFold(tid, tenantID) {
+		log.Printf("⚠️ OBO token for %s/%s rejected: caller is %s/%s", tid, oid, tenantID, userID)
+		return ErrTokenUserMismatch
+	}
 
-	data := url.Values{}
-	data.Set("client_id", s.config.ClientID)
-	data.Set("client_secret", s.config.ClientSecret)
-	data.Set("scope", "https://graph.microsoft.com/.default")
-	data.Set("grant_type", "client_credentials")
+	if _, err := s.storeUserToken(tenantID, userID, tokenResp); err != nil {
+		return err
+	}
+	log.Printf("Delegated Graph token obtained for %s, expires in %d seconds", userID, tokenResp.ExpiresIn)
+	return nil
+}
 
-	log.Printf("=== GRAPH TOKEN REQUEST ===")
-	log.Printf("URL: %s", tokenURL)
-	log.Printf("ClientID: %s", s.config.ClientID)
-	log.Printf("Scope: https://graph.microsoft.com/.default")
+// GetUserAccessToken - Jeton Graph délégué, rafraîchi via le refresh token si besoin
+func (s *AuthService) GetUserAccessToken(tenantID, userID string) (string, error) {
+	tenantID = s.resolveTenant(tenantID)
+	if !s.tenants.Allowed(tenantID) {
+		return "", fmt.Errorf("%w: %s", ErrTenantNotAllowed, tenantID)
+	}
 
-	resp, err := http.PostForm(tokenURL, data)
+	token, err := s.vault.Get(tenantID, userID)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, ErrUserSignInRequired) {
+		return "", ErrUserSignInRequired
+	}
 	if err != nil {
-		return "", fmt.Errorf("token request failed: %w", err)
+		return "", fmt.Errorf("failed to read token vault: %w", err)
 	}
-	defer resp.Body.Close()
 
-	log.Printf("=== GRAPH TOKEN RESPONSE ===")
-	log.Printf("Status: %d", resp.StatusCode)
+	if time.Now().Before(token.ExpiresAt.Add(-tokenRefreshAhead)) {
+		return token.AccessToken, nil
+	}
+	if token.RefreshToken == "" {
+		s.SignOutUser(tenantID, userID)
+		return "", ErrUserSignInRequired
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	// Un seul renouvellement par utilisateur : un refresh token peut être à usage unique
+	result, err, _ := s.userRefreshes.Do(tenantID+"/"+userID, func() (any, error) {
+		data := url.Values{}
+		data.Set("grant_type", "refresh_token")
+		data.Set("refresh_token", token.RefreshToken)
+		data.Set("scope", s.delegatedScope())
+
+		log.Printf("=== DELEGATED TOKEN REFRESH === user: %s", userID)
+
+		tokenResp, err := s.botCredentials.RequestToken(tenantID, data)
+		if err != nil {
+			if errors.Is(err, ErrUserSignInRequired) {
+				s.SignOutUser(tenantID, userID)
+			}
+			return "", err
+		}
+
+		// Le refresh token n'est pas toujours renouvelé
+		if tokenResp.RefreshToken == "" {
+			tokenResp.RefreshToken = token.RefreshToken
+		}
+		return s.storeUserToken(tenantID, userID, tokenResp)
+	})
+	if err != nil {
+		return "", err
 	}
+	return result.(string), nil
+}
 
-	var tokenResp tokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", fmt.Errorf("failed to decode token response: %w", err)
+// HasUserToken - Indique si l'utilisateur s'est déjà connecté
+func (s *AuthService) HasUserToken(tenantID, userID string) bool {
+	return s.vault.Has(s.resolveTenant(tenantID), userID)
+}
+
+// SignOutUser - Révoque les jetons délégués de l'utilisateur
+func (s *AuthService) SignOutUser(tenantID, userID string) {
+	if err := s.vault.Revoke(s.resolveTenant(tenantID), userID); err != nil {
+		log.Printf("Failed to revoke tokens for %s: %v", userID, err)
 	}
+}
 
-	log.Printf("Graph token obtained successfully, expires in %d seconds", tokenResp.ExpiresIn)
+func (s *AuthService) storeUserToken(tenantID, userID string, tokenResp *tokenResponse) (string, error) {
+	token := &VaultToken{
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}
+	if err := s.vault.Put(tenantID, userID, token); err != nil {
+		return "", fmt.Errorf("failed to store delegated token: %w", err)
+	}
+	return token.AccessToken, nil
+}
 
-	s.accessToken = tokenResp.AccessToken
-	s.expiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+// delegatedIdentity - Claims oid et tid du jeton délégué, sans vérification de
+// signature (jeton reçu directement d'AAD)
+func delegatedIdentity(accessToken string) (string, string, error) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return "", "", fmt.Errorf("delegated token is not a JWT")
+	}
+	var claims struct {
+		OID string `json:"oid"`
+		TID string `json:"tid"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", "", fmt.Errorf("failed to parse delegated token: %w", err)
+	}
+	if claims.OID == "" || claims.TID == "" {
+		return "", "", fmt.Errorf("delegated token without oid or tid")
+	}
+	return claims.OID, claims.TID, nil
+}
 
-	return s.accessToken, nil
+// delegatedScope - Scope des jetons délégués, avec refresh token
+func (s *AuthService) delegatedScope() string {
+	return s.config.Cloud.GraphScope() + " offline_access"
 }
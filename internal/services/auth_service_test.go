@@ -0,0 +1,67 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"microsoft_connector/config"
+	"microsoft_connector/internal/storage"
+)
+
+// unsignedJWT - Jeton d'accès simulé ; seuls les claims sont lus
+func unsignedJWT(claims map[string]any) string {
+	payload, _ := json.Marshal(claims)
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func newOBOAuthService(t *testing.T, accessToken string) (*AuthService, *TokenVault) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"access_token": accessToken, "refresh_token": "rt", "expires_in": 3600})
+	}))
+	t.Cleanup(server.Close)
+
+	vault, err := NewTokenVault(storage.NewMemoryStore(), make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials := NewCredentialProvider("BOT", &secretCredential{clientID: "bot", secret: "s"}, server.URL)
+	cfg := &config.Config{TenantID: "tenant-1", Cloud: config.CloudProfile{GraphHost: "https://graph.microsoft.com"}}
+	return NewAuthService(cfg, vault, nil, credentials), vault
+}
+
+func TestExchangeOnBehalfOfStoresCallerToken(t *testing.T) {
+	auth, vault := newOBOAuthService(t, unsignedJWT(map[string]any{"oid": "USER-1", "tid": "tenant-1"}))
+
+	if err := auth.ExchangeOnBehalfOf("tenant-1", "user-1", "sso-token"); err != nil {
+		t.Fatal(err)
+	}
+	if !vault.Has("tenant-1", "user-1") {
+		t.Fatal("delegated token not stored")
+	}
+}
+
+func TestExchangeOnBehalfOfRejectsAnotherIdentity(t *testing.T) {
+	cases := map[string]map[string]any{
+		"other user":   {"oid": "user-2", "tid": "tenant-1"},
+		"other tenant": {"oid": "user-1", "tid": "tenant-2"},
+	}
+	for name, claims := range cases {
+		auth, vault := newOBOAuthService(t, unsignedJWT(claims))
+		if err := auth.ExchangeOnBehalfOf("tenant-1", "user-1", "sso-token"); !errors.Is(err, ErrTokenUserMismatch) {
+			t.Errorf("%s: expected ErrTokenUserMismatch, got %v", name, err)
+		}
+		if vault.Has("tenant-1", "user-1") {
+			t.Errorf("%s: foreign token stored under the caller", name)
+		}
+	}
+
+	auth, _ := newOBOAuthService(t, unsignedJWT(map[string]any{"tid": "tenant-1"}))
+	if err := auth.ExchangeOnBehalfOf("tenant-1", "user-1", "sso-token"); err == nil {
+		t.Fatal("token without oid accepted")
+	}
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	botKeysTTL = 24 * time.Hour
+	// Clé inconnue : les clés sont rechargées au plus une fois par intervalle
+	botKeysMinRefresh = 5 * time.Minute
+	botClockSkew      = 5 * time.Minute
+)
+
+// ErrBotUnauthorized - Jeton Bearer absent ou invalide sur /api/messages
+var ErrBotUnauthorized = errors.New("bot framework token rejected")
+
+// BotAuthenticator - Vérifie le jeton Bearer que le Bot Framework joint à chaque
+// activité : signature (clés publiées dans les métadonnées OpenID), émetteur,
+// audience (App ID du bot) et validité
+type BotAuthenticator struct {
+	metadataURL string
+	appID       string
+	httpClient  *http.Client
+
+	mu        sync.Mutex
+	issuer    string
+	keys      map[string]botSigningKey
+	fetchedAt time.Time
+	group     singleflight.Group
+}
+
+type botSigningKey struct {
+	key          *rsa.PublicKey
+	endorsements []string // canaux autorisés à utiliser la clé
+}
+
+// BotClaims - Claims vérifiés du jeton et endossements de sa clé
+type BotClaims struct {
+	Issuer       string
+	ServiceURL   string
+	Endorsements []string
+}
+
+// NewBotAuthenticator - metadataURL : OpenIDMetadata du profil de cloud
+func NewBotAuthenticator(metadataURL, appID string) *BotAuthenticator {
+	return &BotAuthenticator{
+		metadataURL: metadataURL,
+		appID:       appID,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Authenticate - Vérifie l'en-tête Authorization ; les claims retournés doivent
+// encore être confrontés à l'activité (CheckActivity)
+func (a *BotAuthenticator) Authenticate(authorization string) (*BotClaims, error) {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return nil, fmt.Errorf("%w: missing bearer token", ErrBotUnauthorized)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrBotUnauthorized)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBotUnauthorized, err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrBotUnauthorized, header.Alg)
+	}
+
+	issuer, key, err := a.signingKey(header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBotUnauthorized, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrBotUnauthorized)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key.key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("%w: invalid signature", ErrBotUnauthorized)
+	}
+
+	var claims struct {
+		Iss        string          `json:"iss"`
+		Aud        json.RawMessage `json:"aud"`
+		Exp        int64           `json:"exp"`
+		Nbf        int64           `json:"nbf"`
+		ServiceURL string          `json:"serviceurl"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBotUnauthorized, err)
+	}
+	if claims.Iss != issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrBotUnauthorized, claims.Iss)
+	}
+	if !audienceMatches(claims.Aud, a.appID) {
+		return nil, fmt.Errorf("%w: token not issued for this bot", ErrBotUnauthorized)
+	}
+	now := time.Now()
+	if claims.Exp == 0 || now.After(time.Unix(claims.Exp, 0).Add(botClockSkew)) {
+		return nil, fmt.Errorf("%w: token expired", ErrBotUnauthorized)
+	}
+	if claims.Nbf != 0 && now.Add(botClockSkew).Before(time.Unix(claims.Nbf, 0)) {
+		return nil, fmt.Errorf("%w: token not yet valid", ErrBotUnauthorized)
+	}
+
+	return &BotClaims{Issuer: claims.Iss, ServiceURL: claims.ServiceURL, Endorsements: key.endorsements}, nil
+}
+
+// CheckActivity - Le jeton doit couvrir le serviceUrl et le canal de l'activité
+func (c *BotClaims) CheckActivity(serviceURL, channelID string) error {
+	if c.ServiceURL == "" || !strings.EqualFold(strings.TrimSuffix(c.ServiceURL, "/"), strings.TrimSuffix(serviceURL, "/")) {
+		return fmt.Errorf("%w: serviceUrl %q not covered by token", ErrBotUnauthorized, serviceURL)
+	}
+	if len(c.Endorsements) > 0 && !slices.Contains(c.Endorsements, channelID) {
+		return fmt.Errorf("%w: signing key not endorsed for channel %q", ErrBotUnauthorized, channelID)
+	}
+	return nil
+}
+
+// signingKey - Clé du kid, en rechargeant les métadonnées si elle est inconnue
+// (rotation) ou si le cache a expiré
+func (a *BotAuthenticator) signingKey(kid string) (string, botSigningKey, error) {
+	a.mu.Lock()
+	issuer, key, found := a.issuer, a.keys[kid], a.keys[kid].key != nil
+	loaded, age := a.keys != nil, time.Since(a.fetchedAt)
+	a.mu.Unlock()
+
+	if found && age < botKeysTTL {
+		return issuer, key, nil
+	}
+	if !found && loaded && age < botKeysMinRefresh {
+		return "", botSigningKey{}, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if _, err, _ := a.group.Do("keys", func() (any, error) { return nil, a.refresh() }); err != nil {
+		// Clés en cache encore utilisables si le point de métadonnées est indisponible
+		if found {
+			return issuer, key, nil
+		}
+		return "", botSigningKey{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key, found = a.keys[kid]
+	if !found {
+		return "", botSigningKey{}, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return a.issuer, key, nil
+}
+
+// refresh - Métadonnées OpenID puis jeu de clés (jwks_uri)
+func (a *BotAuthenticator) refresh() error {
+	var metadata struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := a.getJSON(a.metadataURL, &metadata); err != nil {
+		return fmt.Errorf("failed to load OpenID metadata: %w", err)
+	}
+	if metadata.Issuer == "" || metadata.JWKSURI == "" {
+		return fmt.Errorf("OpenID metadata without issuer or jwks_uri")
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty          string   `json:"kty"`
+			Kid          string   `json:"kid"`
+			N            string   `json:"n"`
+			E            string   `json:"e"`
+			Endorsements []string `json:"endorsements"`
+		} `json:"keys"`
+	}
+	if err := a.getJSON(metadata.JWKSURI, &jwks); err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	keys := make(map[string]botSigningKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || k.Kid == "" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = botSigningKey{
+			key:          &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())},
+			endorsements: k.Endorsements,
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no usable signing key in %s", metadata.JWKSURI)
+	}
+
+	a.mu.Lock()
+	a.issuer, a.keys, a.fetchedAt = metadata.Issuer, keys, time.Now()
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *BotAuthenticator) getJSON(url string, out any) error {
+	resp, err := a.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// decodeJWTPart - En-tête ou payload base64url d'un JWT
+func decodeJWTPart(part string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("malformed token part: %w", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("malformed token part: %w", err)
+	}
+	return nil
+}
+
+// audienceMatches - aud est une chaîne ou un tableau
+func audienceMatches(raw json.RawMessage, appID string) bool {
+	if appID == "" {
+		return false
+	}
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == appID
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		return slices.Contains(many, appID)
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testBotIssuer     = "https://api.botframework.com"
+	testBotAppID      = "bot-app-id"
+	testBotServiceURL = "https://smba.trafficmanager.net/emea/"
+)
+
+// botTokenServer - Métadonnées OpenID et JWKS servis localement
+func botTokenServer(t *testing.T, key *rsa.PrivateKey, kid string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/openid", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": testBotIssuer, "jwks_uri": server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]any{{
+			"kty":          "RSA",
+			"kid":          kid,
+			"n":            base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":            base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			"endorsements": []string{"msteams"},
+		}}})
+	})
+	return server
+}
+
+func signBotToken(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validBotClaims() map[string]any {
+	return map[string]any{
+		"iss":        testBotIssuer,
+		"aud":        testBotAppID,
+		"exp":        time.Now().Add(time.Hour).Unix(),
+		"nbf":        time.Now().Add(-time.Minute).Unix(),
+		"serviceurl": testBotServiceURL,
+	}
+}
+
+func TestBotAuthenticator(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := botTokenServer(t, key, "kid-1")
+	auth := NewBotAuthenticator(server.URL+"/openid", testBotAppID)
+
+	claims, err := auth.Authenticate("Bearer " + signBotToken(t, key, "kid-1", validBotClaims()))
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if err := claims.CheckActivity(testBotServiceURL, "msteams"); err != nil {
+		t.Fatalf("matching activity rejected: %v", err)
+	}
+	if err := claims.CheckActivity("https://attacker.example/", "msteams"); !errors.Is(err, ErrBotUnauthorized) {
+		t.Fatalf("foreign serviceUrl accepted: %v", err)
+	}
+	if err := claims.CheckActivity(testBotServiceURL, "webchat"); !errors.Is(err, ErrBotUnauthorized) {
+		t.Fatalf("channel outside key endorsements accepted: %v", err)
+	}
+
+	with := func(name string, value any) map[string]any {
+		c := validBotClaims()
+		c[name] = value
+		return c
+	}
+	rejected := map[string]string{
+		"missing header":  "",
+		"not bearer":      "Basic abc",
+		"malformed":       "Bearer a.b",
+		"wrong audience":  "Bearer " + signBotToken(t, key, "kid-1", with("aud", "another-bot")),
+		"wrong issuer":    "Bearer " + signBotToken(t, key, "kid-1", with("iss", "https://sts.windows.net/x/")),
+		"expired":         "Bearer " + signBotToken(t, key, "kid-1", with("exp", time.Now().Add(-time.Hour).Unix())),
+		"not yet valid":   "Bearer " + signBotToken(t, key, "kid-1", with("nbf", time.Now().Add(time.Hour).Unix())),
+		"foreign key":     "Bearer " + signBotToken(t, other, "kid-1", validBotClaims()),
+		"unknown kid":     "Bearer " + signBotToken(t, key, "kid-2", validBotClaims()),
+		"tampered claims": "Bearer " + tamper(signBotToken(t, key, "kid-1", validBotClaims())),
+	}
+	for name, header := range rejected {
+		if _, err := auth.Authenticate(header); !errors.Is(err, ErrBotUnauthorized) {
+			t.Errorf("%s: expected ErrBotUnauthorized, got %v", name, err)
+		}
+	}
+
+	// aud en tableau
+	if _, err := auth.Authenticate("Bearer " + signBotToken(t, key, "kid-1", with("aud", []string{"x", testBotAppID}))); err != nil {
+		t.Fatalf("audience array rejected: %v", err)
+	}
+}
+
+// tamper - Remplace le payload en gardant la signature d'origine
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	claims := validBotClaims()
+	claims["serviceurl"] = "https://attacker.example/"
+	payload, _ := json.Marshal(claims)
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	return strings.Join(parts, ".")
+}
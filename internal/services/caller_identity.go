@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CallerIdentity - Utilisateur authentifié à l'origine d'un tour de conversation
+type CallerIdentity struct {
+	UserID   string // AAD object id, issu de l'activité Bot Framework
+	TenantID string
+	Name     string
+	// Conversation d'origine, pour prévenir l'utilisateur à la fin d'une opération longue
+	Conversation *ConversationReference
+	// Clé d'idempotence de l'activité en cours (outils à effet de bord)
+	ActivityKey string
+}
+
+// DelegationPolicy - Utilisateurs explicitement autorisés à agir pour d'autres
+//
+// Format de TOOL_DELEGATES : "delegue:cible1|cible2;autre-delegue:*"
+// Les cibles peuvent être des IDs AAD ou des adresses email, "*" = tous.
+type DelegationPolicy struct {
+	delegates map[string]map[string]bool
+}
+
+func NewDelegationPolicy(spec string) *DelegationPolicy {
+	p := &DelegationPolicy{delegates: make(map[string]map[string]bool)}
+
+	for _, entry := range strings.Split(spec, ";") {
+		delegate, targets, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		delegate = strings.ToLower(strings.TrimSpace(delegate))
+		if !ok || delegate == "" {
+			continue
+		}
+		if p.delegates[delegate] == nil {
+			p.delegates[delegate] = make(map[string]bool)
+		}
+		for _, target := range strings.Split(targets, "|") {
+			if target = strings.ToLower(strings.TrimSpace(target)); target != "" {
+				p.delegates[delegate][target] = true
+			}
+		}
+	}
+
+	return p
+}
+
+// CanActAs - Indique si delegate peut utiliser les outils au nom de target
+func (p *DelegationPolicy) CanActAs(delegate, target string) bool {
+	if p == nil {
+		return false
+	}
+	targets := p.delegates[strings.ToLower(delegate)]
+	return targets["*"] || targets[strings.ToLower(target)]
+}
+
+const signInRequiredMessage = "Erreur: connexion requise. Demande à l'utilisateur d'écrire « connexion » pour autoriser NEO à agir en son nom."
+
+// delegatedGraph - Client Graph agissant avec le jeton délégué de l'appelant (/me)
+func (e *ToolExecutor) delegatedGraph(graphService *GraphService) (*GraphService, error) {
+	if e.caller == nil || e.caller.UserID == "" {
+		return nil, fmt.Errorf("utilisateur courant non identifié")
+	}
+	return graphService.AsUser(e.caller.TenantID, e.caller.UserID), nil
+}
+
+// bindUser - Résout l'utilisateur ciblé par un outil lié à une identité
+// (boîte mail, calendrier...). Sans valeur, l'appelant est utilisé ; une autre
+// identité n'est acceptée que si elle désigne l'appelant ou si celui-ci est délégué.
+func (e *ToolExecutor) bindUser(requested string, graphService *GraphService) (string, error) {
+	if e.caller == nil || e.caller.UserID == "" {
+		return "", fmt.Errorf("utilisateur courant non identifié")
+	}
+
+	requested = strings.TrimSpace(requested)
+	if requested == "" || strings.EqualFold(requested, e.caller.UserID) {
+		return e.caller.UserID, nil
+	}
+	if e.delegation.CanActAs(e.caller.UserID, requested) {
+		return requested, nil
+	}
+
+	// Le modèle passe souvent l'email de l'utilisateur : on le résout en ID
+	user, err := graphService.Get("/users/" + PathSegment(requested) + NewQuery().Select("id").String())
+	if err == nil {
+		id, _ := user["id"].(string)
+		if strings.EqualFold(id, e.caller.UserID) {
+			return e.caller.UserID, nil
+		}
+		if id != "" && e.delegation.CanActAs(e.caller.UserID, id) {
+			return id, nil
+		}
+	}
+
+	return "", fmt.Errorf("accès refusé à %s : vous ne pouvez agir que sur votre propre compte", requested)
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"microsoft_connector/config"
+)
+
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// ClientCredential - Authentification de l'application auprès du point de jeton AAD
+type ClientCredential interface {
+	// Apply - Ajoute client_id et la preuve d'identité au formulaire envoyé à tokenURL
+	Apply(data url.Values, tokenURL string) error
+}
+
+// NewClientCredential - Crédential selon AUTH_MODE : secret, certificate ou federated
+func NewClientCredential(cfg *config.Config, clientID, clientSecret string) (ClientCredential, error) {
+	switch cfg.AuthMode {
+	case "", "secret":
+		return &secretCredential{clientID: clientID, secret: clientSecret}, nil
+	case "certificate":
+		return newCertificateCredential(clientID, cfg.ClientCertificatePath, cfg.ClientKeyPath)
+	case "federated":
+		if cfg.FederatedTokenFile == "" {
+			return nil, fmt.Errorf("FEDERATED_TOKEN_FILE is required for federated auth")
+		}
+		return &federatedCredential{clientID: clientID, tokenFile: cfg.FederatedTokenFile}, nil
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", cfg.AuthMode)
+	}
+}
+
+type secretCredential struct {
+	clientID string
+	secret   string
+}
+
+func (c *secretCredential) Apply(data url.Values, tokenURL string) error {
+	data.Set("client_id", c.clientID)
+	data.Set("client_secret", c.secret)
+	return nil
+}
+
+// certificateCredential - client_assertion signée (RS256) avec la clé du certificat
+type certificateCredential struct {
+	clientID   string
+	key        *rsa.PrivateKey
+	thumbprint string // x5t : SHA-1 du certificat DER, base64url
+}
+
+func newCertificateCredential(clientID, certPath, keyPath string) (*certificateCredential, error) {
+	if certPath == "" {
+		return nil, fmt.Errorf("CLIENT_CERTIFICATE_PATH is required for certificate auth")
+	}
+	if keyPath == "" {
+		keyPath = certPath // PEM combiné certificat + clé
+	}
+
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	var cert *x509.Certificate
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+				return nil, fmt.Errorf("failed to parse certificate: %w", err)
+			}
+			break
+		}
+	}
+	if cert == nil {
+		return nil, fmt.Errorf("no certificate found in %s", certPath)
+	}
+
+	var key *rsa.PrivateKey
+	for block, rest := pem.Decode(keyPEM); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			var parsed any
+			parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err == nil {
+				var ok bool
+				if key, ok = parsed.(*rsa.PrivateKey); !ok {
+					err = fmt.Errorf("only RSA keys are supported")
+				}
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		break
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no private key found in %s", keyPath)
+	}
+
+	sum := sha1.Sum(cert.Raw)
+	return &certificateCredential{
+		clientID:   clientID,
+		key:        key,
+		thumbprint: base64.RawURLEncoding.EncodeToString(sum[:]),
+	}, nil
+}
+
+func (c *certificateCredential) Apply(data url.Values, tokenURL string) error {
+	assertion, err := c.assertion(tokenURL)
+	if err != nil {
+		return err
+	}
+	data.Set("client_id", c.clientID)
+	data.Set("client_assertion_type", clientAssertionType)
+	data.Set("client_assertion", assertion)
+	return nil
+}
+
+func (c *certificateCredential) assertion(audience string) (string, error) {
+	jti := make([]byte, 16)
+	rand.Read(jti)
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "x5t": c.thumbprint})
+	claims, _ := json.Marshal(map[string]any{
+		"aud": audience,
+		"iss": c.clientID,
+		"sub": c.clientID,
+		"jti": hex.EncodeToString(jti),
+		"nbf": now.Unix(),
+		"iat": now.Unix(),
+		"exp": now.Add(10 * time.Minute).Unix(),
+	})
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign client assertion: %w", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// federatedCredential - Jeton de workload identity projeté dans un fichier,
+// relu à chaque demande car il est renouvelé par la plateforme
+type federatedCredential struct {
+	clientID  string
+	tokenFile string
+}
+
+func (c *federatedCredential) Apply(data url.Values, tokenURL string) error {
+	token, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read federated token: %w", err)
+	}
+	assertion := strings.TrimSpace(string(token))
+	if assertion == "" {
+		return fmt.Errorf("federated token file %s is empty", c.tokenFile)
+	}
+
+	data.Set("client_id", c.clientID)
+	data.Set("client_assertion_type", clientAssertionType)
+	data.Set("client_assertion", assertion)
+	return nil
+}
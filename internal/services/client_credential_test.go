@@ -0,0 +1,191 @@
+package services
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"microsoft_connector/config"
+)
+
+// tokenEndpoint - Point de jeton AAD simulé : enregistre les formulaires reçus
+type tokenEndpoint struct {
+	server *httptest.Server
+	mu     sync.Mutex
+	forms  []url.Values
+}
+
+func newTokenEndpoint(t *testing.T) *tokenEndpoint {
+	t.Helper()
+	e := &tokenEndpoint{}
+	e.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		e.mu.Lock()
+		e.forms = append(e.forms, r.PostForm)
+		e.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "token", "expires_in": 3600})
+	}))
+	t.Cleanup(e.server.Close)
+	return e
+}
+
+func (e *tokenEndpoint) lastForm(t *testing.T) url.Values {
+	t.Helper()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.forms) == 0 {
+		t.Fatal("no token request received")
+	}
+	return e.forms[len(e.forms)-1]
+}
+
+func TestSecretCredential(t *testing.T) {
+	endpoint := newTokenEndpoint(t)
+	credential, err := NewClientCredential(&config.Config{AuthMode: "secret"}, "app-id", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := NewCredentialProvider("TEST", credential, endpoint.server.URL)
+	if _, err := provider.GetToken("tenant-1", "https://graph.microsoft.com/.default"); err != nil {
+		t.Fatal(err)
+	}
+
+	form := endpoint.lastForm(t)
+	if form.Get("client_id") != "app-id" || form.Get("client_secret") != "s3cret" {
+		t.Fatalf("unexpected form %v", form)
+	}
+	if form.Get("grant_type") != "client_credentials" || form.Get("client_assertion") != "" {
+		t.Fatalf("unexpected form %v", form)
+	}
+}
+
+func TestCertificateCredential(t *testing.T) {
+	endpoint := newTokenEndpoint(t)
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "neo-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// PEM combiné certificat + clé PKCS#8
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	certPath := filepath.Join(t.TempDir(), "app.pem")
+	combined := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})...)
+	if err := os.WriteFile(certPath, combined, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err := NewClientCredential(&config.Config{AuthMode: "certificate", ClientCertificatePath: certPath}, "app-id", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := NewCredentialProvider("TEST", credential, endpoint.server.URL)
+	if _, err := provider.GetToken("tenant-1", "scope"); err != nil {
+		t.Fatal(err)
+	}
+
+	form := endpoint.lastForm(t)
+	if form.Get("client_secret") != "" || form.Get("client_assertion_type") != clientAssertionType {
+		t.Fatalf("unexpected form %v", form)
+	}
+	parts := strings.Split(form.Get("client_assertion"), ".")
+	if len(parts) != 3 {
+		t.Fatalf("client_assertion is not a JWT: %q", form.Get("client_assertion"))
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		X5t string `json:"x5t"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		t.Fatal(err)
+	}
+	thumbprint := sha1.Sum(der)
+	if header.Alg != "RS256" || header.X5t != base64.RawURLEncoding.EncodeToString(thumbprint[:]) {
+		t.Fatalf("unexpected header %+v", header)
+	}
+
+	var claims struct {
+		Aud string `json:"aud"`
+		Iss string `json:"iss"`
+		Sub string `json:"sub"`
+		Jti string `json:"jti"`
+		Exp int64  `json:"exp"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		t.Fatal(err)
+	}
+	if want := endpoint.server.URL + "/tenant-1/oauth2/v2.0/token"; claims.Aud != want {
+		t.Fatalf("aud = %q, want %q", claims.Aud, want)
+	}
+	if claims.Iss != "app-id" || claims.Sub != "app-id" || claims.Jti == "" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+	if exp := time.Unix(claims.Exp, 0); exp.Before(time.Now()) || exp.After(time.Now().Add(11*time.Minute)) {
+		t.Fatalf("exp = %s", exp)
+	}
+
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Fatalf("assertion signature: %v", err)
+	}
+}
+
+func TestFederatedCredential(t *testing.T) {
+	endpoint := newTokenEndpoint(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("first-assertion\n"), 0o600)
+
+	if _, err := NewClientCredential(&config.Config{AuthMode: "federated"}, "app-id", ""); err == nil {
+		t.Fatal("expected an error without FEDERATED_TOKEN_FILE")
+	}
+	credential, err := NewClientCredential(&config.Config{AuthMode: "federated", FederatedTokenFile: tokenFile}, "app-id", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := NewCredentialProvider("TEST", credential, endpoint.server.URL)
+
+	provider.GetToken("tenant-1", "scope-a")
+	if got := endpoint.lastForm(t).Get("client_assertion"); got != "first-assertion" {
+		t.Fatalf("client_assertion = %q", got)
+	}
+
+	// Le fichier est relu à chaque demande (jeton renouvelé par la plateforme)
+	os.WriteFile(tokenFile, []byte("rotated-assertion"), 0o600)
+	provider.GetToken("tenant-1", "scope-b")
+	form := endpoint.lastForm(t)
+	if form.Get("client_assertion") != "rotated-assertion" || form.Get("client_assertion_type") != clientAssertionType {
+		t.Fatalf("unexpected form %v", form)
+	}
+
+	os.WriteFile(tokenFile, nil, 0o600)
+	if _, err := provider.GetToken("tenant-1", "scope-c"); err == nil {
+		t.Fatal("expected an error with an empty token file")
+	}
+}
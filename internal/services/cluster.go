@@ -0,0 +1,265 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"microsoft_connector/internal/storage"
+)
+
+const (
+	callOwnerKeyPrefix = "call-owners/"
+	// Le propriétaire prolonge son bail ; un réplica arrêté le perd à l'expiration
+	callOwnerTTL = 2 * time.Minute
+
+	callsChannel     = "neo:calls"
+	proactiveChannel = "neo:proactive"
+
+	// Marqueur de livraison d'un message proactif : "delivered" ou son livreur
+	proactiveKeyPrefix = "proactive/"
+	proactiveClaimTTL  = 24 * time.Hour
+	proactiveDelivered = "delivered"
+
+	// Filet de sécurité si aucun réplica ne livre le message reçu du bus
+	proactiveJob           = "proactive.deliver"
+	proactiveFallbackDelay = 30 * time.Second
+)
+
+// errProactiveInProgress - Un autre livreur détient le message
+var errProactiveInProgress = errors.New("proactive message delivery in progress")
+
+// Cluster - État partagé entre réplicas : quel réplica porte chaque appel
+// audio, et diffusion de messages via le bus
+type Cluster struct {
+	store      storage.Store
+	bus        storage.PubSub
+	instanceID string
+}
+
+// callEvent - Un réplica vient de prendre en charge un appel
+type callEvent struct {
+	CallID string `json:"call_id"`
+	Owner  string `json:"owner"`
+}
+
+// NewCluster - instanceID vide : nom d'hôte + suffixe aléatoire
+func NewCluster(store storage.Store, bus storage.PubSub, instanceID string) *Cluster {
+	if instanceID == "" {
+		host, _ := os.Hostname()
+		instanceID = strings.Trim(host+"-"+newClientRequestID()[:8], "-")
+	}
+	return &Cluster{store: store, bus: bus, instanceID: instanceID}
+}
+
+// InstanceID - Identifiant de ce réplica
+func (c *Cluster) InstanceID() string {
+	return c.instanceID
+}
+
+// ClaimCall - Ce réplica porte désormais l'appel ; l'ancien propriétaire éventuel
+// est prévenu pour fermer sa session
+func (c *Cluster) ClaimCall(callID string) error {
+	if err := c.store.Set(callOwnerKeyPrefix+callID, []byte(c.instanceID), callOwnerTTL); err != nil {
+		return err
+	}
+	data, _ := json.Marshal(callEvent{CallID: callID, Owner: c.instanceID})
+	return c.bus.Publish(callsChannel, data)
+}
+
+// RefreshCall - Prolonge le bail ; false si un autre réplica a repris l'appel.
+// La comparaison et l'écriture sont atomiques : deux réplicas ne peuvent pas
+// s'écraser mutuellement le bail
+func (c *Cluster) RefreshCall(callID string) bool {
+	key := callOwnerKeyPrefix + callID
+	owner := []byte(c.instanceID)
+	refreshed, err := c.store.CompareAndSwap(key, owner, owner, callOwnerTTL)
+	if err != nil {
+		log.Printf("[Cluster] Bail de l'appel %s non prolongé: %v", callID, err)
+		return true
+	}
+	if refreshed {
+		return true
+	}
+	// Bail expiré sans repreneur : ce réplica le reprend
+	claimed, err := c.store.SetIfAbsent(key, owner, callOwnerTTL)
+	if err != nil {
+		log.Printf("[Cluster] Bail de l'appel %s non repris: %v", callID, err)
+		return true
+	}
+	return claimed
+}
+
+// ReleaseCall - Libère l'appel s'il appartient toujours à ce réplica
+func (c *Cluster) ReleaseCall(callID string) {
+	if _, err := c.store.CompareAndDelete(callOwnerKeyPrefix+callID, []byte(c.instanceID)); err != nil {
+		log.Printf("[Cluster] Appel %s non libéré: %v", callID, err)
+	}
+}
+
+// CallOwner - Réplica qui porte la session audio de l'appel
+func (c *Cluster) CallOwner(callID string) (string, bool) {
+	data, err := c.store.Get(callOwnerKeyPrefix + callID)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Calls - Appels actifs de tous les réplicas : callID -> réplica
+func (c *Cluster) Calls() (map[string]string, error) {
+	keys, err := c.store.Keys(callOwnerKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	calls := make(map[string]string, len(keys))
+	for _, key := range keys {
+		callID := strings.TrimPrefix(key, callOwnerKeyPrefix)
+		if owner, ok := c.CallOwner(callID); ok {
+			calls[callID] = owner
+		}
+	}
+	return calls, nil
+}
+
+// OnCallClaimed - Appelé quand un réplica (celui-ci compris) prend un appel
+func (c *Cluster) OnCallClaimed(fn func(callID, owner string)) (func(), error) {
+	return c.bus.Subscribe(callsChannel, func(payload []byte) {
+		var event callEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return
+		}
+		fn(event.CallID, event.Owner)
+	})
+}
+
+// proactiveMessage - Message proactif publié sur le bus
+type proactiveMessage struct {
+	ID   string                 `json:"id"`
+	Ref  *ConversationReference `json:"ref"`
+	Text string                 `json:"text"`
+}
+
+// ProactiveRelay - ProactiveNotifier qui publie sur le bus : chaque réplica
+// reçoit le message et un seul le livre. Le bus livre au plus une fois ; un job
+// différé livre le message si aucun réplica ne l'a fait, et le rejoue en cas
+// d'échec du Bot Connector
+type ProactiveRelay struct {
+	cluster       *Cluster
+	jobs          *JobQueue
+	deliver       ProactiveNotifier
+	fallbackDelay time.Duration
+	unsubscribe   func()
+}
+
+func NewProactiveRelay(cluster *Cluster, jobs *JobQueue, deliver ProactiveNotifier) *ProactiveRelay {
+	r := &ProactiveRelay{cluster: cluster, jobs: jobs, deliver: deliver, fallbackDelay: proactiveFallbackDelay}
+	jobs.Register(proactiveJob, r.run)
+	return r
+}
+
+// Start - Abonnement au canal des messages proactifs
+func (r *ProactiveRelay) Start() error {
+	unsubscribe, err := r.cluster.bus.Subscribe(proactiveChannel, r.receive)
+	if err != nil {
+		return err
+	}
+	r.unsubscribe = unsubscribe
+	return nil
+}
+
+func (r *ProactiveRelay) Stop() {
+	if r.unsubscribe != nil {
+		r.unsubscribe()
+	}
+}
+
+// Notify - Le job de secours est enregistré avant la publication ; sans bus
+// disponible, le message est livré directement
+func (r *ProactiveRelay) Notify(ref *ConversationReference, text string) error {
+	if ref == nil {
+		return errors.New("no conversation reference")
+	}
+	msg := proactiveMessage{ID: newClientRequestID(), Ref: ref, Text: text}
+
+	_, queueErr := r.jobs.Enqueue(proactiveJob, msg, EnqueueOptions{RunAt: time.Now().Add(r.fallbackDelay)})
+	if queueErr != nil {
+		log.Printf("⚠️ [Cluster] Job de secours du message %s non planifié: %v", msg.ID, queueErr)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal proactive message: %w", err)
+	}
+	if err := r.cluster.bus.Publish(proactiveChannel, data); err != nil {
+		log.Printf("⚠️ [Cluster] Publication impossible, livraison directe: %v", err)
+		err = r.attempt(msg, r.cluster.instanceID)
+		// Le job de secours rejouera la livraison
+		if queueErr == nil || errors.Is(err, errProactiveInProgress) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *ProactiveRelay) receive(payload []byte) {
+	var msg proactiveMessage
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Ref == nil || msg.ID == "" {
+		return
+	}
+	if err := r.attempt(msg, r.cluster.instanceID); err != nil && !errors.Is(err, errProactiveInProgress) {
+		log.Printf("[Cluster] Message proactif %s non livré, repris par le job de secours: %v", msg.ID, err)
+	}
+}
+
+// run - Job de secours : sans effet si le message a été livré via le bus
+func (r *ProactiveRelay) run(job *Job) error {
+	var msg proactiveMessage
+	if err := job.Decode(&msg); err != nil {
+		return err
+	}
+	if msg.Ref == nil || msg.ID == "" {
+		log.Printf("[Cluster] Message proactif %s sans conversation, ignoré", job.ID)
+		return nil
+	}
+	err := r.attempt(msg, "job:"+job.ID)
+	if errors.Is(err, errProactiveInProgress) {
+		return RescheduleJob(r.fallbackDelay)
+	}
+	return err
+}
+
+// attempt - Livraison exclusive : le livreur pose le marqueur, le remplace par
+// "delivered" en cas de succès et le retire en cas d'échec pour qu'un autre
+// reprenne. Une erreur du stockage vaut "non réclamé"
+func (r *ProactiveRelay) attempt(msg proactiveMessage, owner string) error {
+	key := proactiveKeyPrefix + msg.ID
+	claimed, err := r.cluster.store.SetIfAbsent(key, []byte(owner), proactiveClaimTTL)
+	if err != nil {
+		return fmt.Errorf("failed to claim proactive message: %w", err)
+	}
+	if !claimed {
+		holder, err := r.cluster.store.Get(key)
+		switch {
+		case err == nil && string(holder) == proactiveDelivered:
+			return nil
+		case err != nil || string(holder) != owner:
+			return errProactiveInProgress
+		}
+		// Marqueur laissé par ce même livreur (arrêt pendant la livraison)
+	}
+
+	if err := r.deliver.Notify(msg.Ref, msg.Text); err != nil {
+		r.cluster.store.Delete(key)
+		return err
+	}
+	if err := r.cluster.store.Set(key, []byte(proactiveDelivered), proactiveClaimTTL); err != nil {
+		log.Printf("[Cluster] Message proactif %s livré mais non marqué: %v", msg.ID, err)
+	}
+	return nil
+}
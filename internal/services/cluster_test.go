@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"microsoft_connector/internal/storage"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// fakeNotifier - Livraison simulée ; échoue tant que failures > 0
+type fakeNotifier struct {
+	mu        sync.Mutex
+	failures  int
+	delivered []string
+}
+
+func (n *fakeNotifier) Notify(ref *ConversationReference, text string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.failures > 0 {
+		n.failures--
+		return errors.New("bot connector error 502")
+	}
+	n.delivered = append(n.delivered, text)
+	return nil
+}
+
+func (n *fakeNotifier) texts() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.delivered...)
+}
+
+func waitForJob(t *testing.T, jobs *JobQueue, state string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if list, _ := jobs.List(state, proactiveJob); len(list) == 1 {
+			return list[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %s job %s", proactiveJob, state)
+	return Job{}
+}
+
+// newRelayReplica - Réplica : sa file de jobs et son relais, sur le stockage et le bus partagés
+func newRelayReplica(t *testing.T, store storage.Store, bus storage.PubSub, name string, deliver ProactiveNotifier, fallback time.Duration) *ProactiveRelay {
+	t.Helper()
+	jobs := NewJobQueue(store, JobOptions{PollInterval: 10 * time.Millisecond, MaxAttempts: 1})
+	relay := NewProactiveRelay(NewCluster(store, bus, name), jobs, deliver)
+	relay.fallbackDelay = fallback
+	if err := relay.Start(); err != nil {
+		t.Fatal(err)
+	}
+	jobs.Start()
+	t.Cleanup(func() {
+		relay.Stop()
+		jobs.Shutdown(context.Background())
+	})
+	return relay
+}
+
+var testRef = &ConversationReference{ConversationID: "conv-1", ServiceURL: "https://smba.example/"}
+
+func TestProactiveRelayDeliversOnceThroughBus(t *testing.T) {
+	store, bus := storage.NewMemoryStore(), storage.NewMemoryPubSub()
+	first, second := &fakeNotifier{}, &fakeNotifier{}
+	relay := newRelayReplica(t, store, bus, "replica-1", first, 50*time.Millisecond)
+	newRelayReplica(t, store, bus, "replica-2", second, 50*time.Millisecond)
+
+	if err := relay.Notify(nil, "x"); err == nil {
+		t.Fatal("expected an error without conversation reference")
+	}
+	if err := relay.Notify(testRef, "bonjour"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Le job de secours passe après le bus et ne relivre pas
+	waitForJob(t, relay.jobs, JobSucceeded)
+	if delivered := append(first.texts(), second.texts()...); len(delivered) != 1 || delivered[0] != "bonjour" {
+		t.Fatalf("delivered = %v", delivered)
+	}
+}
+
+func TestProactiveRelayFallbackJob(t *testing.T) {
+	store := storage.NewMemoryStore()
+	deliver := &fakeNotifier{failures: 2}
+	relay := newRelayReplica(t, store, storage.NewMemoryPubSub(), "replica-1", deliver, 20*time.Millisecond)
+
+	// Échec via le bus puis dans le job : le job reste visible (dead) au lieu d'être perdu
+	if err := relay.Notify(testRef, "premier"); err != nil {
+		t.Fatal(err)
+	}
+	dead := waitForJob(t, relay.jobs, JobDead)
+	if dead.LastError == "" {
+		t.Fatal("dead job without error")
+	}
+	if _, err := relay.jobs.Retry(dead.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, relay.jobs, JobSucceeded)
+	if delivered := deliver.texts(); len(delivered) != 1 || delivered[0] != "premier" {
+		t.Fatalf("delivered = %v", delivered)
+	}
+}
+
+func TestProactiveRelayWithoutSubscriber(t *testing.T) {
+	store := storage.NewMemoryStore()
+	deliver := &fakeNotifier{}
+	relay := newRelayReplica(t, store, storage.NewMemoryPubSub(), "replica-1", deliver, 20*time.Millisecond)
+	// Message publié alors qu'aucun réplica n'écoute
+	relay.Stop()
+
+	if err := relay.Notify(testRef, "perdu sur le bus"); err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, relay.jobs, JobSucceeded)
+	if delivered := deliver.texts(); len(delivered) != 1 {
+		t.Fatalf("delivered = %v", delivered)
+	}
+}
+
+// failingBus - Bus indisponible
+type failingBus struct{ storage.PubSub }
+
+func (failingBus) Publish(string, []byte) error { return errors.New("redis: connection refused") }
+
+func TestProactiveRelayDeliversDirectlyWithoutBus(t *testing.T) {
+	store := storage.NewMemoryStore()
+	deliver := &fakeNotifier{}
+	relay := newRelayReplica(t, store, failingBus{storage.NewMemoryPubSub()}, "replica-1", deliver, time.Hour)
+
+	if err := relay.Notify(testRef, "direct"); err != nil {
+		t.Fatal(err)
+	}
+	if delivered := deliver.texts(); len(delivered) != 1 || delivered[0] != "direct" {
+		t.Fatalf("delivered = %v", delivered)
+	}
+}
+
+func TestCallLeaseAgainstRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	store, err := storage.NewRedisStore("redis://" + server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	first := NewCluster(store, store, "replica-1")
+	second := NewCluster(store, store, "replica-2")
+
+	if err := first.ClaimCall("call-1"); err != nil {
+		t.Fatal(err)
+	}
+	if !first.RefreshCall("call-1") {
+		t.Fatal("owner could not refresh its lease")
+	}
+	if second.RefreshCall("call-1") {
+		t.Fatal("another replica refreshed a lease it does not hold")
+	}
+
+	// Reprise par le second réplica : l'ancien ne peut ni prolonger ni libérer
+	if err := second.ClaimCall("call-1"); err != nil {
+		t.Fatal(err)
+	}
+	if first.RefreshCall("call-1") {
+		t.Fatal("previous owner kept the call")
+	}
+	first.ReleaseCall("call-1")
+	if owner, _ := first.CallOwner("call-1"); owner != "replica-2" {
+		t.Fatalf("owner = %q after a stale release", owner)
+	}
+
+	// Bail expiré sans repreneur : le réplica qui tient la session le reprend
+	server.FastForward(callOwnerTTL + time.Second)
+	if !second.RefreshCall("call-1") {
+		t.Fatal("expired lease not taken back")
+	}
+	second.ReleaseCall("call-1")
+	if _, ok := second.CallOwner("call-1"); ok {
+		t.Fatal("lease kept after release")
+	}
+}
@@ -1,81 +1,401 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
+
+	"microsoft_connector/internal/storage"
+)
+
+const (
+	conversationKeyPrefix   = "conversations/"
+	conversationUserPrefix  = "conversation-users/" // index utilisateur → conversations
+	conversationCleanupTick = 5 * time.Minute
 )
 
+// ConversationMessage - Role : user, assistant ou tool (réponses de fonctions).
+// Parts conserve les tours structurés (appels de fonctions et leurs résultats).
 type ConversationMessage struct {
-	Role    string
-	Content string
-	Time    time.Time
+	Role    string       `json:"role"`
+	Content string       `json:"content,omitempty"`
+	Parts   []GeminiPart `json:"parts,omitempty"`
+	Author  string       `json:"author,omitempty"` // utilisateur à l'origine du tour (ses messages et les réponses de NEO)
+	Time    time.Time    `json:"time"`
+}
+
+// ConversationOptions - Rétention de l'historique ; 0 = valeur par défaut
+type ConversationOptions struct {
+	TTL         time.Duration // inactivité avant oubli de la conversation
+	MaxMessages int
+	Retention   time.Duration // âge maximal d'un message, même si la conversation est active
+	ToolChars   int           // taille maximale d'un résultat d'outil rejoué au modèle
+	Summarized  bool          // compaction par résumé (HISTORY_TOKEN_BUDGET) : MaxMessages ne s'applique pas
+}
+
+type conversationRecord struct {
+	Summary  string                `json:"summary,omitempty"` // résumé glissant des tours compactés
+	Messages []ConversationMessage `json:"messages"`
 }
 
+// ConversationStore - Historique des conversations, persisté dans le stockage
+// partagé (mémoire, fichiers ou SQLite selon STORAGE_BACKEND)
 type ConversationStore struct {
-	conversations map[string][]ConversationMessage
-	mu            sync.RWMutex
-	maxMessages   int
-	ttl           time.Duration
-	stopChan      chan struct{}
+	store       storage.Store
+	mu          sync.Mutex // lecture-modification-écriture d'un historique
+	maxMessages int
+	ttl         time.Duration
+	retention   time.Duration
+	toolChars   int
+	stopChan    chan struct{}
 }
 
-func NewConversationStore() *ConversationStore {
-	store := &ConversationStore{
-		conversations: make(map[string][]ConversationMessage),
-		maxMessages:   50,
-		ttl:           30 * time.Minute,
-		stopChan:      make(chan struct{}),
+func NewConversationStore(store storage.Store, opts ConversationOptions) *ConversationStore {
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
 	}
-	go store.cleanup()
-	return store
+	if opts.MaxMessages <= 0 {
+		opts.MaxMessages = 50
+	}
+	// Les anciens tours sont fondus dans le résumé : les tronquer ici les perdrait
+	if opts.Summarized {
+		opts.MaxMessages = 0
+	}
+	if opts.ToolChars <= 0 {
+		opts.ToolChars = 2000
+	}
+	s := &ConversationStore{
+		store:       store,
+		maxMessages: opts.MaxMessages,
+		ttl:         opts.TTL,
+		retention:   opts.Retention,
+		toolChars:   opts.ToolChars,
+		stopChan:    make(chan struct{}),
+	}
+	go s.cleanup()
+	return s
 }
 
 func (s *ConversationStore) Stop() {
 	close(s.stopChan)
 }
 
-func (s *ConversationStore) AddMessage(conversationID, role, content string) {
+func (s *ConversationStore) AddMessage(conversationID, author, role, content string) {
+	s.add(conversationID, ConversationMessage{Role: role, Content: content, Author: author})
+}
+
+// AddTurn - Tour structuré : appels de fonctions du modèle ou leurs réponses
+func (s *ConversationStore) AddTurn(conversationID, author, role string, parts []GeminiPart) {
+	s.add(conversationID, ConversationMessage{Role: role, Parts: parts, Author: author})
+}
+
+func (s *ConversationStore) add(conversationID string, msg ConversationMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg.Time = time.Now()
+	record := s.load(conversationID)
+	record.Messages = s.compact(append(record.Messages, msg))
+	s.save(conversationID, record)
+}
+
+func (s *ConversationStore) GetHistory(conversationID string) []ConversationMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compact(s.load(conversationID).Messages)
+}
+
+// Summary - Résumé glissant des tours déjà compactés
+func (s *ConversationStore) Summary(conversationID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(conversationID).Summary
+}
+
+// Summarize - Remplace le résumé et retire les messages qu'il couvre (jusqu'à upTo
+// inclus) ; les messages ajoutés pendant le résumé sont conservés
+func (s *ConversationStore) Summarize(conversationID, summary string, upTo time.Time) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	msg := ConversationMessage{
-		Role:    role,
-		Content: content,
-		Time:    time.Now(),
+	record := s.load(conversationID)
+	start := 0
+	for start < len(record.Messages) && !record.Messages[start].Time.After(upTo) {
+		start++
+	}
+	record.Summary = summary
+	record.Messages = record.Messages[start:]
+	s.save(conversationID, record)
+}
+
+// ReplayContents - Historique au format Gemini, résultats d'outils tronqués
+func (s *ConversationStore) ReplayContents(conversationID string) []GeminiContent {
+	history := s.GetHistory(conversationID)
+
+	// Après compaction, l'historique peut commencer au milieu d'un échange
+	// d'appels de fonctions : on repart du premier message de l'utilisateur
+	start := 0
+	for start < len(history) && (history[start].Role != "user" || len(history[start].Parts) > 0) {
+		start++
 	}
 
-	s.conversations[conversationID] = append(s.conversations[conversationID], msg)
+	contents := []GeminiContent{}
+	for _, msg := range history[start:] {
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
 
-	// Limiter le nombre de messages
-	if len(s.conversations[conversationID]) > s.maxMessages {
-		s.conversations[conversationID] = s.conversations[conversationID][1:]
+		parts := msg.Parts
+		if len(parts) == 0 {
+			parts = []GeminiPart{{Text: msg.Content}}
+		} else {
+			parts = s.truncateToolPayloads(parts)
+		}
+		contents = append(contents, GeminiContent{Role: role, Parts: parts})
 	}
+	return contents
 }
 
-func (s *ConversationStore) GetHistory(conversationID string) []ConversationMessage {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *ConversationStore) truncateToolPayloads(parts []GeminiPart) []GeminiPart {
+	truncated := make([]GeminiPart, len(parts))
+	for i, part := range parts {
+		truncated[i] = part
+		if part.FunctionResponse == nil {
+			continue
+		}
+		result, ok := part.FunctionResponse.Response["result"].(string)
+		if !ok || utf8.RuneCountInString(result) <= s.toolChars {
+			continue
+		}
+		runes := []rune(result)
+		truncated[i].FunctionResponse = &GeminiFunctionResp{
+			Name: part.FunctionResponse.Name,
+			Response: map[string]any{
+				"result": string(runes[:s.toolChars]) + fmt.Sprintf("… [tronqué, %d caractères au total]", len(runes)),
+			},
+		}
+	}
+	return truncated
+}
 
-	return s.conversations[conversationID]
+// Participate - Indexe la conversation pour l'utilisateur (export, effacement RGPD)
+func (s *ConversationStore) Participate(conversationID, userID string) {
+	if userID == "" {
+		return
+	}
+	if err := s.store.Set(conversationUserPrefix+userID+"/"+conversationID, []byte(time.Now().Format(time.RFC3339)), 0); err != nil {
+		log.Printf("[Conversations] Index de %s impossible: %v", conversationID, err)
+	}
+}
+
+// UserConversations - Conversations auxquelles l'utilisateur a participé
+func (s *ConversationStore) UserConversations(userID string) ([]string, error) {
+	prefix := conversationUserPrefix + userID + "/"
+	keys, err := s.store.Keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+	conversations := make([]string, 0, len(keys))
+	for _, key := range keys {
+		conversations = append(conversations, key[len(prefix):])
+	}
+	return conversations, nil
+}
+
+// Shared - Vrai si d'autres utilisateurs ont participé à la conversation
+func (s *ConversationStore) Shared(conversationID, userID string) (bool, error) {
+	keys, err := s.store.Keys(conversationUserPrefix)
+	if err != nil {
+		return false, err
+	}
+	for _, key := range keys {
+		rest := key[len(conversationUserPrefix):]
+		slash := strings.Index(rest, "/")
+		if slash >= 0 && rest[slash+1:] == conversationID && rest[:slash] != userID {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
+// EraseUser - Supprime les conversations personnelles de l'utilisateur et leur
+// index. Dans une conversation partagée, seuls ses tours sont retirés, ainsi que
+// le résumé qui peut les citer : l'historique des autres participants est conservé
+func (s *ConversationStore) EraseUser(userID string) (personal, shared int, err error) {
+	conversations, err := s.UserConversations(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, conversationID := range conversations {
+		isShared, err := s.Shared(conversationID, userID)
+		if err != nil {
+			return personal, shared, err
+		}
+		if isShared {
+			s.eraseTurns(conversationID, userID)
+			shared++
+			continue
+		}
+		if err := s.Clear(conversationID); err != nil {
+			return personal, shared, fmt.Errorf("failed to erase conversation %s: %w", conversationID, err)
+		}
+		personal++
+	}
+	if _, err := s.store.DeletePrefix(conversationUserPrefix + userID + "/"); err != nil {
+		return personal, shared, err
+	}
+	return personal, shared, nil
+}
+
+func (s *ConversationStore) eraseTurns(conversationID, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.load(conversationID)
+	kept := make([]ConversationMessage, 0, len(record.Messages))
+	for _, msg := range record.Messages {
+		if msg.Author != userID {
+			kept = append(kept, msg)
+		}
+	}
+	record.Messages = kept
+	record.Summary = ""
+	s.save(conversationID, record)
+}
+
+// Clear - Oublie l'historique d'une conversation
+func (s *ConversationStore) Clear(conversationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.store.Delete(conversationKeyPrefix + conversationID)
+}
+
+func (s *ConversationStore) load(conversationID string) conversationRecord {
+	var record conversationRecord
+	data, err := s.store.Get(conversationKeyPrefix + conversationID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return record
+	}
+	if err != nil {
+		log.Printf("[Conversations] Lecture de %s impossible: %v", conversationID, err)
+		return record
+	}
+
+	if err := json.Unmarshal(data, &record); err != nil {
+		log.Printf("[Conversations] Historique %s illisible, ignoré: %v", conversationID, err)
+		return conversationRecord{}
+	}
+	return record
+}
+
+// save - L'expiration part du dernier message : le TTL compte l'inactivité
+func (s *ConversationStore) save(conversationID string, record conversationRecord) {
+	key := conversationKeyPrefix + conversationID
+	var ttl time.Duration
+	if len(record.Messages) > 0 {
+		ttl = s.ttl - time.Since(record.Messages[len(record.Messages)-1].Time)
+	}
+	if ttl <= 0 {
+		s.store.Delete(key)
+		return
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("[Conversations] Sérialisation de %s impossible: %v", conversationID, err)
+		return
+	}
+	if err := s.store.Set(key, data, ttl); err != nil {
+		log.Printf("[Conversations] Écriture de %s impossible: %v", conversationID, err)
+	}
+}
+
+// compact - Applique la rétention puis la limite de messages (aucune si 0)
+func (s *ConversationStore) compact(messages []ConversationMessage) []ConversationMessage {
+	if s.retention > 0 {
+		cutoff := time.Now().Add(-s.retention)
+		start := 0
+		for start < len(messages) && messages[start].Time.Before(cutoff) {
+			start++
+		}
+		messages = messages[start:]
+	}
+	if s.maxMessages > 0 && len(messages) > s.maxMessages {
+		messages = messages[len(messages)-s.maxMessages:]
+	}
+	return messages
+}
+
+// cleanup - Purge des entrées expirées du backend et compaction des historiques
+// dépassant la rétention, identique quel que soit le backend
 func (s *ConversationStore) cleanup() {
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(conversationCleanupTick)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			s.mu.Lock()
-			now := time.Now()
-			for id, messages := range s.conversations {
-				if len(messages) > 0 && now.Sub(messages[len(messages)-1].Time) > s.ttl {
-					delete(s.conversations, id)
-				}
+			if count, err := s.store.PurgeExpired(); err != nil {
+				log.Printf("[Conversations] Purge impossible: %v", err)
+			} else if count > 0 {
+				log.Printf("[Conversations] %d entrées expirées purgées", count)
+			}
+			if s.retention > 0 {
+				s.compactAll()
 			}
-			s.mu.Unlock()
+			s.pruneIndex()
 		case <-s.stopChan:
 			return
 		}
 	}
 }
+
+// pruneIndex - Retire de l'index les conversations expirées
+func (s *ConversationStore) pruneIndex() {
+	keys, err := s.store.Keys(conversationUserPrefix)
+	if err != nil {
+		log.Printf("[Conversations] Lecture de l'index impossible: %v", err)
+		return
+	}
+
+	for _, key := range keys {
+		// conversation-users/<utilisateur>/<tenant>/<conversation>
+		rest := key[len(conversationUserPrefix):]
+		slash := strings.Index(rest, "/")
+		if slash < 0 {
+			continue
+		}
+		if _, err := s.store.Get(conversationKeyPrefix + rest[slash+1:]); errors.Is(err, storage.ErrNotFound) {
+			s.store.Delete(key)
+		}
+	}
+}
+
+func (s *ConversationStore) compactAll() {
+	keys, err := s.store.Keys(conversationKeyPrefix)
+	if err != nil {
+		log.Printf("[Conversations] Liste des conversations impossible: %v", err)
+		return
+	}
+
+	for _, key := range keys {
+		conversationID := key[len(conversationKeyPrefix):]
+
+		s.mu.Lock()
+		record := s.load(conversationID)
+		if compacted := s.compact(record.Messages); len(compacted) != len(record.Messages) {
+			record.Messages = compacted
+			s.save(conversationID, record)
+		}
+		s.mu.Unlock()
+	}
+}
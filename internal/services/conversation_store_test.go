@@ -0,0 +1,36 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"microsoft_connector/internal/storage"
+)
+
+func TestConversationStoreMessageCap(t *testing.T) {
+	capped := NewConversationStore(storage.NewMemoryStore(), ConversationOptions{MaxMessages: 3})
+	defer capped.Stop()
+	summarized := NewConversationStore(storage.NewMemoryStore(), ConversationOptions{MaxMessages: 3, Summarized: true})
+	defer summarized.Stop()
+
+	for i := 0; i < 5; i++ {
+		capped.AddMessage("conv-1", "user-1", "user", fmt.Sprint(i))
+		summarized.AddMessage("conv-1", "user-1", "user", fmt.Sprint(i))
+	}
+	if history := capped.GetHistory("conv-1"); len(history) != 3 || history[0].Content != "2" {
+		t.Fatalf("capped history = %+v", history)
+	}
+
+	// Avec la compaction par résumé, rien n'est perdu avant Summarize
+	history := summarized.GetHistory("conv-1")
+	if len(history) != 5 {
+		t.Fatalf("summarized history kept %d messages, want 5", len(history))
+	}
+	summarized.Summarize("conv-1", "- messages 0 à 2", history[2].Time)
+	if history := summarized.GetHistory("conv-1"); len(history) != 2 || history[0].Content != "3" {
+		t.Fatalf("history after Summarize = %+v", history)
+	}
+	if summary := summarized.Summary("conv-1"); summary != "- messages 0 à 2" {
+		t.Fatalf("summary = %q", summary)
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// Cloud public, si aucun hôte n'est fourni
+	DefaultAuthorityHost = "https://login.microsoftonline.com"
+
+	// Renouvellement en arrière-plan dans cette fenêtre avant expiration...
+	tokenRefreshAhead = 5 * time.Minute
+	// ...et renouvellement bloquant quand le jeton est presque expiré
+	tokenMinValidity = time.Minute
+)
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+}
+
+type appToken struct {
+	accessToken string
+	expiresAt   time.Time
+}
+
+// CredentialProvider - Jetons d'une application AAD, par tenant et par scope
+// (Graph, Bot Framework...), avec un seul renouvellement concurrent par jeton
+type CredentialProvider struct {
+	name          string
+	credential    ClientCredential
+	authorityHost string
+	httpClient    *http.Client
+
+	tokens    map[string]*appToken
+	mu        sync.RWMutex
+	group     singleflight.Group
+	observers []func(tenantID, scope, accessToken string)
+}
+
+// NewCredentialProvider - authorityHost permet de pointer vers un autre point de jeton
+func NewCredentialProvider(name string, credential ClientCredential, authorityHost string) *CredentialProvider {
+	if authorityHost == "" {
+		authorityHost = DefaultAuthorityHost
+	}
+	return &CredentialProvider{
+		name:          name,
+		credential:    credential,
+		authorityHost: strings.TrimSuffix(authorityHost, "/"),
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		tokens:        make(map[string]*appToken),
+	}
+}
+
+// OnToken - Appelé à chaque nouveau jeton obtenu (à brancher avant le premier appel)
+func (p *CredentialProvider) OnToken(fn func(tenantID, scope, accessToken string)) {
+	p.observers = append(p.observers, fn)
+}
+
+// GetToken - Jeton client credentials pour le tenant et le scope demandés
+func (p *CredentialProvider) GetToken(tenantID, scope string) (string, error) {
+	key := tenantID + "|" + scope
+
+	p.mu.RLock()
+	token, ok := p.tokens[key]
+	p.mu.RUnlock()
+
+	now := time.Now()
+	if ok && now.Before(token.expiresAt.Add(-tokenMinValidity)) {
+		// Renouvellement proactif : l'appelant garde le jeton encore valide
+		if now.After(token.expiresAt.Add(-tokenRefreshAhead)) {
+			go func() {
+				if _, err := p.refresh(tenantID, scope); err != nil {
+					log.Printf("[%s] Proactive token refresh failed: %v", p.name, err)
+				}
+			}()
+		}
+		return token.accessToken, nil
+	}
+
+	return p.refresh(tenantID, scope)
+}
+
+func (p *CredentialProvider) refresh(tenantID, scope string) (string, error) {
+	key := tenantID + "|" + scope
+
+	result, err, _ := p.group.Do(key, func() (any, error) {
+		// Un autre appel a pu renouveler le jeton entre-temps
+		p.mu.RLock()
+		token, ok := p.tokens[key]
+		p.mu.RUnlock()
+		if ok && time.Now().Before(token.expiresAt.Add(-tokenRefreshAhead)) {
+			return token.accessToken, nil
+		}
+
+		data := url.Values{}
+		data.Set("grant_type", "client_credentials")
+		data.Set("scope", scope)
+
+		log.Printf("=== %s TOKEN REQUEST === tenant: %s, scope: %s", p.name, tenantID, scope)
+
+		tokenResp, err := p.RequestToken(tenantID, data)
+		if err != nil {
+			return "", err
+		}
+
+		log.Printf("%s token obtained successfully, expires in %d seconds", p.name, tokenResp.ExpiresIn)
+
+		p.mu.Lock()
+		p.tokens[key] = &appToken{
+			accessToken: tokenResp.AccessToken,
+			expiresAt:   time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+		}
+		p.mu.Unlock()
+
+		for _, observe := range p.observers {
+			observe(tenantID, scope, tokenResp.AccessToken)
+		}
+
+		return tokenResp.AccessToken, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.(string), nil
+}
+
+// RequestToken - Appel brut au point de jeton (OBO, refresh token...) avec la
+// preuve d'identité de l'application
+func (p *CredentialProvider) RequestToken(tenantID string, data url.Values) (*tokenResponse, error) {
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", p.authorityHost, url.PathEscape(tenantID))
+
+	if err := p.credential.Apply(data, tokenURL); err != nil {
+		return nil, fmt.Errorf("failed to build client credential: %w", err)
+	}
+
+	resp, err := p.httpClient.PostForm(tokenURL, data)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	log.Printf("=== TOKEN RESPONSE Status: %d ===", resp.StatusCode)
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var tokenResp tokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || tokenResp.Error != "" {
+		switch tokenResp.Error {
+		case "invalid_grant", "interaction_required", "consent_required":
+			return nil, fmt.Errorf("%w: %s", ErrUserSignInRequired, firstLine(tokenResp.ErrorDesc))
+		case "":
+			return nil, fmt.Errorf("token request failed with status %d", resp.StatusCode)
+		default:
+			return nil, fmt.Errorf("token error: %s - %s", tokenResp.Error, firstLine(tokenResp.ErrorDesc))
+		}
+	}
+
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("no access token in response")
+	}
+
+	return &tokenResp, nil
+}
+
+// firstLine - Les descriptions d'erreur AAD contiennent trace et correlation id
+func firstLine(s string) string {
+	if idx := strings.IndexAny(s, "\r\n"); idx != -1 {
+		return s[:idx]
+	}
+	return s
+}
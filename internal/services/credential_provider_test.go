@@ -0,0 +1,120 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingTokenServer - Numérote les jetons émis ; expiresIn en secondes
+func countingTokenServer(t *testing.T, expiresIn int, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		time.Sleep(delay)
+		json.NewEncoder(w).Encode(map[string]any{"access_token": fmt.Sprintf("token-%d", n), "expires_in": expiresIn})
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestCredentialProviderSingleflight(t *testing.T) {
+	server, requests := countingTokenServer(t, 3600, 100*time.Millisecond)
+	provider := NewCredentialProvider("TEST", &secretCredential{clientID: "app", secret: "s"}, server.URL)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := provider.GetToken("tenant-1", "scope"); err != nil || token != "token-1" {
+				t.Errorf("GetToken = %q, %v", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("%d token requests for concurrent callers, want 1", n)
+	}
+
+	// Jeton en cache : aucune nouvelle requête
+	provider.GetToken("tenant-1", "scope")
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("%d token requests with a cached token", n)
+	}
+	// Autre tenant : autre jeton
+	provider.GetToken("tenant-2", "scope")
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("%d token requests for a second tenant, want 2", n)
+	}
+}
+
+func TestCredentialProviderProactiveRefresh(t *testing.T) {
+	// 4 minutes : dans la fenêtre de renouvellement anticipé, encore utilisable
+	server, requests := countingTokenServer(t, 240, 0)
+	provider := NewCredentialProvider("TEST", &secretCredential{clientID: "app", secret: "s"}, server.URL)
+
+	observed := make(chan string, 4)
+	provider.OnToken(func(tenantID, scope, accessToken string) { observed <- accessToken })
+
+	if token, _ := provider.GetToken("tenant-1", "scope"); token != "token-1" {
+		t.Fatalf("first token = %q", token)
+	}
+	// Le jeton encore valide est servi sans attendre, le renouvellement part en arrière-plan
+	if token, _ := provider.GetToken("tenant-1", "scope"); token != "token-1" {
+		t.Fatalf("cached token = %q", token)
+	}
+	<-observed
+	select {
+	case token := <-observed:
+		if token != "token-2" {
+			t.Fatalf("refreshed token = %q", token)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no proactive refresh")
+	}
+	if token, _ := provider.GetToken("tenant-1", "scope"); token != "token-2" {
+		t.Fatalf("token after refresh = %q", token)
+	}
+	if n := requests.Load(); n < 2 {
+		t.Fatalf("%d token requests", n)
+	}
+}
+
+func TestCredentialProviderBlockingRefresh(t *testing.T) {
+	// Moins d'une minute de validité : renouvellement avant de répondre
+	server, _ := countingTokenServer(t, 30, 0)
+	provider := NewCredentialProvider("TEST", &secretCredential{clientID: "app", secret: "s"}, server.URL)
+
+	provider.GetToken("tenant-1", "scope")
+	if token, _ := provider.GetToken("tenant-1", "scope"); token != "token-2" {
+		t.Fatalf("nearly expired token served: %q", token)
+	}
+}
+
+func TestCredentialProviderErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":             "invalid_grant",
+			"error_description": "AADSTS50076: MFA required.\r\nTrace ID: x",
+		})
+	}))
+	defer server.Close()
+
+	provider := NewCredentialProvider("TEST", &secretCredential{clientID: "app", secret: "s"}, server.URL)
+	_, err := provider.GetToken("tenant-1", "scope")
+	if !errors.Is(err, ErrUserSignInRequired) {
+		t.Fatalf("invalid_grant: %v", err)
+	}
+	if err.Error() != ErrUserSignInRequired.Error()+": AADSTS50076: MFA required." {
+		t.Fatalf("error description not trimmed: %q", err)
+	}
+}
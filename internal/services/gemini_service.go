@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 const geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash:generateContent"
@@ -17,6 +18,15 @@ type GeminiService struct {
 	apiKey            string
 	httpClient        *http.Client
 	conversationStore *ConversationStore
+	delegation        *DelegationPolicy
+	permissions       *PermissionChecker
+	operations        *OperationTracker
+	profiles          *ProfileStore
+	idempotency       *IdempotencyStore
+	reminders         *ReminderService
+	history           HistoryOptions
+	compacting        sync.Map // conversations en cours de résumé
+	jobs              *JobQueue
 }
 
 // ===== Structures Request =====
@@ -120,32 +130,36 @@ type GeminiFunctionDecl struct {
 
 // ===== Constructor =====
 
-func NewGeminiService(apiKey string) *GeminiService {
+func NewGeminiService(apiKey string, conversations *ConversationStore, delegation *DelegationPolicy, permissions *PermissionChecker, operations *OperationTracker, profiles *ProfileStore, idempotency *IdempotencyStore, reminders *ReminderService, history HistoryOptions) *GeminiService {
+	if history.KeepRecent <= 0 {
+		history.KeepRecent = 6
+	}
 	return &GeminiService{
 		apiKey:            apiKey,
 		httpClient:        &http.Client{},
-		conversationStore: NewConversationStore(),
+		conversationStore: conversations,
+		delegation:        delegation,
+		permissions:       permissions,
+		operations:        operations,
+		profiles:          profiles,
+		idempotency:       idempotency,
+		reminders:         reminders,
+		history:           history,
 	}
 }
 
-// ===== Public Methods =====
-
-func (s *GeminiService) SendMessageWithContext(userMessage string, context string, conversationID string, graphService *GraphService) (string, error) {
-	history := s.conversationStore.GetHistory(conversationID)
+// availableTools - Filtre des outils selon les permissions accordées dans le tenant
+func (s *GeminiService) availableTools(tenantID string) func(name string) bool {
+	return func(name string) bool {
+		return s.permissions.Available(tenantID, name)
+	}
+}
 
-	contents := []GeminiContent{}
+// ===== Public Methods =====
 
-	// Reconstruire l'historique (role: "user" ou "model")
-	for _, msg := range history {
-		role := "user"
-		if msg.Role == "assistant" {
-			role = "model"
-		}
-		contents = append(contents, GeminiContent{
-			Role:  role,
-			Parts: []GeminiPart{{Text: msg.Content}},
-		})
-	}
+func (s *GeminiService) SendMessageWithContext(userMessage string, context string, conversationID string, caller *CallerIdentity, graphService *GraphService) (string, error) {
+	// Historique complet, appels de fonctions et résultats d'outils compris
+	contents := s.conversationStore.ReplayContents(conversationID)
 
 	// Ajouter le nouveau message utilisateur
 	contents = append(contents, GeminiContent{
@@ -154,34 +168,28 @@ func (s *GeminiService) SendMessageWithContext(userMessage string, context strin
 	})
 
 	// Sauvegarder avant l'envoi
-	s.conversationStore.AddMessage(conversationID, "user", userMessage)
+	author := ""
+	if caller != nil {
+		author = caller.UserID
+	}
+	s.conversationStore.AddMessage(conversationID, author, "user", userMessage)
+	s.conversationStore.Participate(conversationID, author)
 
-	response, err := s.sendWithTools(contents, context, graphService)
+	systemContext := withSummary(context, s.conversationStore.Summary(conversationID))
+	response, err := s.sendWithTools(contents, systemContext, conversationID, caller, graphService)
 	if err != nil {
 		return "", err
 	}
 
-	s.conversationStore.AddMessage(conversationID, "assistant", response)
+	s.conversationStore.AddMessage(conversationID, author, "assistant", response)
+	s.scheduleCompaction(conversationID, context)
 	return response, nil
 }
 
 // SendAudioMessage - Envoie de l'audio PCM (base64) à Gemini 2.5 et retourne la réponse audio PCM
 func (s *GeminiService) SendAudioMessage(audioBase64 string, conversationID string, graphService *GraphService) ([]byte, error) {
 
-	history := s.conversationStore.GetHistory(conversationID)
-	contents := []GeminiContent{}
-
-	// Reconstruire l'historique textuel
-	for _, msg := range history {
-		role := "user"
-		if msg.Role == "assistant" {
-			role = "model"
-		}
-		contents = append(contents, GeminiContent{
-			Role:  role,
-			Parts: []GeminiPart{{Text: msg.Content}},
-		})
-	}
+	contents := s.conversationStore.ReplayContents(conversationID)
 
 	// Ajouter l'audio courant comme message user
 	contents = append(contents, GeminiContent{
@@ -199,12 +207,12 @@ func (s *GeminiService) SendAudioMessage(audioBase64 string, conversationID stri
 	reqBody := GeminiRequest{
 		Contents: contents,
 		SystemInstruction: &GeminiSystemInstruc{
-			Parts: []GeminiPart{{Text: `Tu es NEO, un assistant vocal Microsoft 365.
+			Parts: []GeminiPart{{Text: withSummary(s.profiles.WithProfile(`Tu es NEO, un assistant vocal Microsoft 365.
 Réponds de manière concise et claire en français.
-Tu es en conversation vocale, évite les longues listes ou tableaux.`}},
+Tu es en conversation vocale, évite les longues listes ou tableaux.`, s.profiles.CallUser(conversationID), ""), s.conversationStore.Summary(conversationID))}},
 		},
 		Tools: []GeminiTool{{
-			FunctionDeclarations: GetGeminiTools(),
+			FunctionDeclarations: GetGeminiTools(s.availableTools(graphService.tenantID)),
 		}},
 		GenerationConfig: &GeminiGenerationConfig{
 			ResponseModalities: []string{"AUDIO"},
@@ -267,7 +275,8 @@ Tu es en conversation vocale, évite les longues listes ou tableaux.`}},
 			log.Printf("[GeminiAudio] Réponse audio: %d bytes pour conversationID: %s", len(audioBytes), conversationID)
 
 			// Sauvegarder dans l'historique
-			s.conversationStore.AddMessage(conversationID, "assistant", "[audio_response]")
+			s.conversationStore.AddMessage(conversationID, "", "assistant", "[audio_response]")
+			s.scheduleCompaction(conversationID, "")
 			return audioBytes, nil
 		}
 	}
@@ -276,7 +285,7 @@ Tu es en conversation vocale, évite les longues listes ou tableaux.`}},
 	for _, part := range result.Candidates[0].Content.Parts {
 		if part.Text != "" {
 			log.Printf("[GeminiAudio] Réponse texte inattendue: %s", part.Text)
-			s.conversationStore.AddMessage(conversationID, "assistant", part.Text)
+			s.conversationStore.AddMessage(conversationID, "", "assistant", part.Text)
 		}
 	}
 
@@ -285,8 +294,10 @@ Tu es en conversation vocale, évite les longues listes ou tableaux.`}},
 
 // ===== Private Methods =====
 
-func (s *GeminiService) sendWithTools(contents []GeminiContent, systemContext string, graphService *GraphService) (string, error) {
-	executor := &ToolExecutor{}
+// sendWithTools - Boucle d'appels de fonctions ; chaque tour (appel, réponse)
+// est enregistré dans l'historique pour que les IDs retournés restent connus
+func (s *GeminiService) sendWithTools(contents []GeminiContent, systemContext string, conversationID string, caller *CallerIdentity, graphService *GraphService) (string, error) {
+	executor := NewToolExecutor(caller, s.delegation, s.permissions, s.operations, s.profiles, s.idempotency, s.reminders)
 
 	for {
 		reqBody := GeminiRequest{
@@ -295,7 +306,7 @@ func (s *GeminiService) sendWithTools(contents []GeminiContent, systemContext st
 				Parts: []GeminiPart{{Text: systemContext}},
 			},
 			Tools: []GeminiTool{{
-				FunctionDeclarations: GetGeminiTools(),
+				FunctionDeclarations: GetGeminiTools(s.availableTools(caller.TenantID)),
 			}},
 			GenerationConfig: &GeminiGenerationConfig{
 				MaxOutputTokens: 4096,
@@ -349,6 +360,7 @@ func (s *GeminiService) sendWithTools(contents []GeminiContent, systemContext st
 		// Séparer les parts: thoughts, function calls, texte
 		var textParts []string
 		var functionCalls []*GeminiFunctionCall
+		var callParts []GeminiPart
 
 		for _, part := range candidate.Content.Parts {
 			if part.Thought {
@@ -356,6 +368,7 @@ func (s *GeminiService) sendWithTools(contents []GeminiContent, systemContext st
 			}
 			if part.FunctionCall != nil {
 				functionCalls = append(functionCalls, part.FunctionCall)
+				callParts = append(callParts, part)
 			}
 			if part.Text != "" {
 				textParts = append(textParts, part.Text)
@@ -365,6 +378,7 @@ func (s *GeminiService) sendWithTools(contents []GeminiContent, systemContext st
 		// Si on a des function calls, les exécuter
 		if len(functionCalls) > 0 {
 			contents = append(contents, candidate.Content)
+			s.conversationStore.AddTurn(conversationID, caller.UserID, "assistant", callParts)
 
 			funcResponses := []GeminiPart{}
 			for _, fc := range functionCalls {
@@ -385,6 +399,7 @@ func (s *GeminiService) sendWithTools(contents []GeminiContent, systemContext st
 				Role:  "user",
 				Parts: funcResponses,
 			})
+			s.conversationStore.AddTurn(conversationID, caller.UserID, "tool", funcResponses)
 			continue
 		}
 
@@ -1,17 +1,20 @@
 package services
 
+// GetGeminiTools - Déclarations d'outils ; available filtre les outils proposés (nil = tous)
+func GetGeminiTools(available func(name string) bool) []GeminiFunctionDecl {
+	microsoftTools := GetMicrosoftTools()
+	geminiTools := make([]GeminiFunctionDecl, 0, len(microsoftTools))
 
-func GetGeminiTools() []GeminiFunctionDecl {
-    microsoftTools := GetMicrosoftTools()
-    geminiTools := make([]GeminiFunctionDecl, 0, len(microsoftTools))
+	for _, t := range microsoftTools {
+		if available != nil && !available(t.Name) {
+			continue
+		}
+		geminiTools = append(geminiTools, GeminiFunctionDecl{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  t.InputSchema,
+		})
+	}
 
-    for _, t := range microsoftTools {
-        geminiTools = append(geminiTools, GeminiFunctionDecl{
-            Name:        t.Name,
-            Description: t.Description,
-            Parameters:  t.InputSchema,
-        })
-    }
-
-    return geminiTools
-}
\ No newline at end of file
+	return geminiTools
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limite imposée par Graph pour un appel /$batch
+const maxBatchSize = 20
+
+// BatchRequest - Sous-requête d'un lot JSON $batch ; URL relative à la version
+// de l'API (ex. "/users/{id}/events")
+type BatchRequest struct {
+	ID        string
+	Method    string
+	URL       string
+	Body      map[string]any
+	Headers   map[string]string
+	DependsOn []string
+}
+
+// BatchResponse - Réponse d'une sous-requête ; Err est renseigné pour les statuts >= 400
+type BatchResponse struct {
+	ID      string
+	Status  int
+	Headers map[string]string
+	Body    map[string]any
+	Err     error
+}
+
+type batchRequestPayload struct {
+	ID        string            `json:"id"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Body      map[string]any    `json:"body,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	DependsOn []string          `json:"dependsOn,omitempty"`
+}
+
+type batchResponsePayload struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// Batch - Regroupe les requêtes par lots de 20 ; une requête doit apparaître après
+// celles dont elle dépend. Les réponses sont indexées par ID (l'index si ID vide).
+func (s *GraphService) Batch(requests []BatchRequest) (map[string]*BatchResponse, error) {
+	return s.batch(s.baseURL, requests)
+}
+
+func (s *GraphService) batch(baseURL string, requests []BatchRequest) (map[string]*BatchResponse, error) {
+	requests, err := normalizeBatch(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make(map[string]*BatchResponse, len(requests))
+	for start := 0; start < len(requests); start += maxBatchSize {
+		chunk := requests[start:min(start+maxBatchSize, len(requests))]
+		if err := s.sendBatch(baseURL, chunk, responses); err != nil {
+			return responses, err
+		}
+	}
+
+	s.retryThrottled(baseURL, requests, responses)
+	return responses, nil
+}
+
+// normalizeBatch - Attribue les IDs manquants et vérifie l'ordre des dépendances
+func normalizeBatch(requests []BatchRequest) ([]BatchRequest, error) {
+	normalized := make([]BatchRequest, len(requests))
+	seen := make(map[string]bool, len(requests))
+
+	for i, req := range requests {
+		if req.ID == "" {
+			req.ID = strconv.Itoa(i + 1)
+		}
+		if req.Method == "" {
+			req.Method = "GET"
+		}
+		if seen[req.ID] {
+			return nil, fmt.Errorf("duplicate batch request id %q", req.ID)
+		}
+		for _, dep := range req.DependsOn {
+			if !seen[dep] {
+				return nil, fmt.Errorf("batch request %q depends on %q which must come before it", req.ID, dep)
+			}
+		}
+		seen[req.ID] = true
+		normalized[i] = req
+	}
+	return normalized, nil
+}
+
+// sendBatch - Un appel /$batch ; les dépendances vers un lot précédent, déjà
+// exécuté, sont résolues localement (424 si la dépendance a échoué)
+func (s *GraphService) sendBatch(baseURL string, chunk []BatchRequest, responses map[string]*BatchResponse) error {
+	payload := make([]batchRequestPayload, 0, len(chunk))
+	inChunk := make(map[string]bool, len(chunk))
+
+	for _, req := range chunk {
+		item := batchRequestPayload{
+			ID:      req.ID,
+			Method:  req.Method,
+			URL:     req.URL,
+			Body:    req.Body,
+			Headers: req.Headers,
+		}
+		if req.Body != nil && headerValue(item.Headers, "Content-Type") == "" {
+			item.Headers = withHeader(item.Headers, "Content-Type", "application/json")
+		}
+
+		failed := false
+		for _, dep := range req.DependsOn {
+			if inChunk[dep] {
+				item.DependsOn = append(item.DependsOn, dep)
+			} else if prev := responses[dep]; prev == nil || prev.Err != nil {
+				failed = true
+			}
+		}
+		if failed {
+			responses[req.ID] = failedDependency(req.ID)
+			continue
+		}
+
+		inChunk[req.ID] = true
+		payload = append(payload, item)
+	}
+
+	if len(payload) == 0 {
+		return nil
+	}
+
+	jsonBody, err := json.Marshal(map[string]any{"requests": payload})
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+
+	log.Printf("=== GRAPH BATCH === %d requests", len(payload))
+
+	resp, bodyBytes, err := s.send("POST", baseURL+"/$batch", jsonBody, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return newGraphError(resp.StatusCode, flattenHeaders(resp.Header), bodyBytes)
+	}
+
+	var result struct {
+		Responses []batchResponsePayload `json:"responses"`
+	}
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return fmt.Errorf("failed to decode batch response: %w", err)
+	}
+
+	for _, item := range result.Responses {
+		responses[item.ID] = newBatchResponse(item.ID, item.Status, item.Headers, item.Body)
+	}
+
+	// Sous-requête absente de la réponse : ne pas laisser l'appelant sans résultat
+	for _, item := range payload {
+		if responses[item.ID] == nil {
+			responses[item.ID] = &BatchResponse{ID: item.ID, Err: fmt.Errorf("no response for batch request %q", item.ID)}
+		}
+	}
+	return nil
+}
+
+// retryThrottled - Rejoue individuellement les sous-requêtes limitées (429, 503/504
+// idempotentes) puis celles qui avaient échoué par dépendance (424)
+func (s *GraphService) retryThrottled(baseURL string, requests []BatchRequest, responses map[string]*BatchResponse) {
+	var wait time.Duration
+	retry := false
+	for _, req := range requests {
+		resp := responses[req.ID]
+		if !isThrottled(req.Method, resp) {
+			continue
+		}
+		retry = true
+		if delay, ok := parseRetryAfter(headerValue(resp.Headers, "Retry-After")); ok {
+			wait = max(wait, delay)
+		}
+	}
+	if !retry {
+		return
+	}
+	if wait > retryAfterMax {
+		return
+	}
+	time.Sleep(wait)
+
+	for _, req := range requests {
+		resp := responses[req.ID]
+		if resp.Status == http.StatusFailedDependency && !dependenciesSucceeded(req, responses) {
+			continue
+		}
+		if !isThrottled(req.Method, resp) && resp.Status != http.StatusFailedDependency {
+			continue
+		}
+
+		log.Printf("⚠️ Graph batch request %s returned %d, retrying individually", req.ID, resp.Status)
+		s.resilience.retries.Add(1)
+		responses[req.ID] = s.sendSingle(baseURL, req)
+	}
+}
+
+func (s *GraphService) sendSingle(baseURL string, req BatchRequest) *BatchResponse {
+	var payload []byte
+	if req.Body != nil {
+		var err error
+		if payload, err = json.Marshal(req.Body); err != nil {
+			return &BatchResponse{ID: req.ID, Err: fmt.Errorf("failed to marshal request body: %w", err)}
+		}
+	}
+
+	resp, bodyBytes, err := s.send(req.Method, baseURL+req.URL, payload, req.Headers)
+	if err != nil {
+		return &BatchResponse{ID: req.ID, Err: err}
+	}
+
+	return newBatchResponse(req.ID, resp.StatusCode, flattenHeaders(resp.Header), bodyBytes)
+}
+
+func newBatchResponse(id string, status int, headers map[string]string, body []byte) *BatchResponse {
+	resp := &BatchResponse{ID: id, Status: status, Headers: headers}
+	// Corps JSON uniquement ; les contenus binaires arrivent encodés en base64
+	if len(body) > 0 && strings.HasPrefix(strings.TrimSpace(string(body)), "{") {
+		json.Unmarshal(body, &resp.Body)
+	}
+	if status >= 400 {
+		resp.Err = newGraphError(status, headers, body)
+	}
+	return resp
+}
+
+func failedDependency(id string) *BatchResponse {
+	return &BatchResponse{
+		ID:     id,
+		Status: http.StatusFailedDependency,
+		Err:    fmt.Errorf("batch request %q skipped: a dependency failed", id),
+	}
+}
+
+func isThrottled(method string, resp *BatchResponse) bool {
+	switch resp.Status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(method)
+	}
+	return false
+}
+
+func dependenciesSucceeded(req BatchRequest, responses map[string]*BatchResponse) bool {
+	for _, dep := range req.DependsOn {
+		if prev := responses[dep]; prev == nil || prev.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func withHeader(headers map[string]string, name, value string) map[string]string {
+	copied := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		copied[k] = v
+	}
+	copied[name] = value
+	return copied
+}
+
+// headerValue - Les en-têtes des sous-réponses ne sont pas normalisés
+func headerValue(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// batchGraph - Serveur $batch : les ajouts de "member-dup" échouent (déjà membre),
+// "member-busy" est limité (429) dans le lot puis accepté seul
+type batchGraph struct {
+	mu      sync.Mutex
+	batches []int    // taille de chaque appel $batch
+	singles []string // sous-requêtes rejouées individuellement
+}
+
+func (g *batchGraph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+
+	if r.URL.Path != "/v1.0/$batch" {
+		g.singles = append(g.singles, r.Method+" "+r.URL.Path+" "+body["@odata.id"].(string))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var payload struct {
+		Requests []batchRequestPayload `json:"requests"`
+	}
+	raw, _ := json.Marshal(body)
+	json.Unmarshal(raw, &payload)
+	g.batches = append(g.batches, len(payload.Requests))
+
+	responses := []map[string]any{}
+	for _, req := range payload.Requests {
+		ref, _ := req.Body["@odata.id"].(string)
+		resp := map[string]any{"id": req.ID, "status": http.StatusNoContent}
+		switch {
+		case req.Headers["Content-Type"] != "application/json":
+			resp["status"] = http.StatusBadRequest
+		case strings.HasSuffix(ref, "/member-dup"):
+			resp["status"] = http.StatusBadRequest
+			resp["body"] = map[string]any{"error": map[string]string{"code": "Request_BadRequest", "message": "One or more added object references already exist"}}
+		case strings.HasSuffix(ref, "/member-busy"):
+			resp["status"] = http.StatusTooManyRequests
+			resp["headers"] = map[string]string{"retry-after": "0"}
+		}
+		responses = append(responses, resp)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"responses": responses})
+}
+
+func TestBatchSplitsAndReportsEachResponse(t *testing.T) {
+	handler := &batchGraph{}
+	graph := newTestGraphService(t, GraphOptions{}, handler)
+
+	requests := make([]BatchRequest, 45)
+	for i := range requests {
+		requests[i] = BatchRequest{Method: "POST", URL: "/groups/group-1/members/$ref", Body: map[string]any{"@odata.id": "member-" + string(rune('a'+i%26))}}
+	}
+	requests[7].Body["@odata.id"] = "/directoryObjects/member-dup"
+	requests[30].Body["@odata.id"] = "/directoryObjects/member-busy"
+
+	responses, err := graph.Batch(requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handler.batches) != 3 || handler.batches[0] != 20 || handler.batches[1] != 20 || handler.batches[2] != 5 {
+		t.Fatalf("batch sizes = %v, want [20 20 5]", handler.batches)
+	}
+	if len(responses) != 45 {
+		t.Fatalf("%d responses", len(responses))
+	}
+
+	// IDs attribués selon la position : "8" est le doublon, "31" a été rejoué seul
+	if resp := responses["8"]; resp.Status != http.StatusBadRequest || resp.Err == nil || !strings.Contains(resp.Err.Error(), "already exist") {
+		t.Fatalf("duplicate member response = %+v", resp)
+	}
+	if resp := responses["31"]; resp.Status != http.StatusNoContent || resp.Err != nil {
+		t.Fatalf("throttled request not retried: %+v", resp)
+	}
+	if len(handler.singles) != 1 || !strings.HasPrefix(handler.singles[0], "POST /v1.0/groups/group-1/members/$ref") {
+		t.Fatalf("individual retries = %v", handler.singles)
+	}
+	for id, resp := range responses {
+		if id != "8" && resp.Err != nil {
+			t.Fatalf("request %s failed: %v", id, resp.Err)
+		}
+	}
+}
+
+func TestBatchDependencies(t *testing.T) {
+	if _, err := normalizeBatch([]BatchRequest{{ID: "a", DependsOn: []string{"b"}}, {ID: "b"}}); err == nil {
+		t.Fatal("forward dependency accepted")
+	}
+	if _, err := normalizeBatch([]BatchRequest{{ID: "a"}, {ID: "a"}}); err == nil {
+		t.Fatal("duplicate id accepted")
+	}
+
+	handler := &batchGraph{}
+	graph := newTestGraphService(t, GraphOptions{}, handler)
+
+	// Dépendance vers un lot précédent en échec : 424 sans appel
+	requests := make([]BatchRequest, 21)
+	for i := range requests {
+		requests[i] = BatchRequest{Method: "POST", URL: "/groups/group-1/members/$ref", Body: map[string]any{"@odata.id": "/directoryObjects/member-ok"}}
+	}
+	requests[0].Body = map[string]any{"@odata.id": "/directoryObjects/member-dup"}
+	requests[20].DependsOn = []string{"1"}
+
+	responses, err := graph.Batch(requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := responses["21"]; resp.Status != http.StatusFailedDependency || resp.Err == nil {
+		t.Fatalf("dependent request = %+v", resp)
+	}
+	if len(handler.batches) != 1 {
+		t.Fatalf("batch sizes = %v, the dependent-only chunk must not be sent", handler.batches)
+	}
+}
+
+func TestAddGroupMembersUsesBatch(t *testing.T) {
+	handler := &batchGraph{}
+	graph := newTestGraphService(t, GraphOptions{}, handler)
+	executor := NewToolExecutor(nil, NewDelegationPolicy(""), nil, nil, nil, nil, nil)
+
+	input := json.RawMessage(`{"group_id":"group-1","user_ids":["member-1","member-dup","member-2"]}`)
+	var result struct {
+		Added  []string         `json:"added"`
+		Failed []map[string]any `json:"failed"`
+	}
+	if err := json.Unmarshal([]byte(executor.Execute("add_group_member", input, graph)), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(handler.batches) != 1 || handler.batches[0] != 3 {
+		t.Fatalf("batch sizes = %v", handler.batches)
+	}
+	if len(result.Added) != 2 || len(result.Failed) != 1 || result.Failed[0]["user_id"] != "member-dup" {
+		t.Fatalf("result = %+v", result)
+	}
+
+	// Aucun ajout réussi : erreur, l'appel reste retentable
+	input = json.RawMessage(`{"group_id":"group-1","user_ids":["member-dup","member-dup"]}`)
+	if text := executor.Execute("add_group_member", input, graph); !strings.HasPrefix(text, "Erreur") {
+		t.Fatalf("all failed = %q", text)
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// Au-delà, Graph impose une session d'upload
+	simpleUploadMaxSize = 4 * 1024 * 1024
+	// Les fragments d'une session doivent être des multiples de 320 Kio
+	uploadChunkUnit    = 320 * 1024
+	defaultUploadChunk = 10 * uploadChunkUnit
+)
+
+// ByteRange - Plage d'octets inclusive ; End < 0 = jusqu'à la fin
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+func (r ByteRange) header() string {
+	if r.End < 0 {
+		return fmt.Sprintf("bytes=%d-", r.Start)
+	}
+	return fmt.Sprintf("bytes=%d-%d", r.Start, r.End)
+}
+
+// GraphStream - Contenu binaire à lire puis fermer par l'appelant
+type GraphStream struct {
+	Status        int
+	Headers       http.Header
+	ContentType   string
+	ContentLength int64 // -1 si inconnu
+	Body          io.ReadCloser
+}
+
+// Download - Contenu brut (fichier, photo, MIME d'un message) en streaming ;
+// rng limite la lecture à une plage d'octets (206 Partial Content)
+func (s *GraphService) Download(endpoint string, rng *ByteRange) (*GraphStream, error) {
+	return s.download(s.baseURL+endpoint, rng)
+}
+
+func (s *GraphService) download(url string, rng *ByteRange) (*GraphStream, error) {
+	log.Printf("=== GRAPH API DOWNLOAD === %s", url)
+
+	headers := map[string]string{"client-request-id": newClientRequestID()}
+	if rng != nil {
+		headers["Range"] = rng.header()
+	}
+
+	resp, _, err := s.exchange("GET", url, true, func(token string) (*http.Response, []byte, error) {
+		return s.openStream(url, headers, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, newGraphError(resp.StatusCode, flattenHeaders(resp.Header), bodyBytes)
+	}
+
+	return &GraphStream{
+		Status:        resp.StatusCode,
+		Headers:       resp.Header,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+		Body:          resp.Body,
+	}, nil
+}
+
+// openStream - Le timeout ne couvre que l'attente des en-têtes : un long
+// téléchargement ne doit pas être coupé en cours de lecture
+func (s *GraphService) openStream(url string, headers map[string]string, token string) (*http.Response, []byte, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	timer := time.AfterFunc(s.resilience.timeout, cancel)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	setGraphHeaders(req, headers, token)
+	req.Header.Del("Content-Type")
+
+	resp, err := s.httpClient.Do(req)
+	if !timer.Stop() && err == nil {
+		// Le timeout est tombé juste après les en-têtes : le corps est inutilisable
+		resp.Body.Close()
+		err = context.DeadlineExceeded
+	}
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// PutContent - Envoi d'un contenu brut (≤ 4 Mo) avec son type, ex.
+// PUT /users/{id}/drive/root:/rapport.pdf:/content ou une photo de profil
+func (s *GraphService) PutContent(endpoint, contentType string, data []byte) (map[string]any, error) {
+	if len(data) > simpleUploadMaxSize {
+		return nil, fmt.Errorf("content too large for a simple upload (%d bytes), use an upload session", len(data))
+	}
+
+	url := s.baseURL + endpoint
+	log.Printf("=== GRAPH API UPLOAD === %s (%d bytes, %s)", url, len(data), contentType)
+
+	resp, bodyBytes, err := s.send("PUT", url, data, map[string]string{"Content-Type": contentType})
+	if err != nil {
+		return nil, err
+	}
+	return decodeContentResponse(resp, bodyBytes)
+}
+
+// UploadLarge - Session d'upload : sessionEndpoint est l'appel createUploadSession
+// (ex. /users/{id}/drive/root:/video.mp4:/createUploadSession) ; le contenu est
+// envoyé par fragments, chacun rejoué individuellement en cas d'échec
+func (s *GraphService) UploadLarge(sessionEndpoint string, sessionBody map[string]any, content io.Reader, size int64) (map[string]any, error) {
+	session, err := s.Post(sessionEndpoint, sessionBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload session: %w", err)
+	}
+	uploadURL, _ := session["uploadUrl"].(string)
+	if uploadURL == "" {
+		return nil, fmt.Errorf("no uploadUrl in upload session response")
+	}
+
+	result, err := s.uploadChunks(uploadURL, content, size)
+	if err != nil {
+		// Libère la session côté serveur ; l'URL est pré-authentifiée
+		s.exchange("DELETE", uploadURL, false, func(string) (*http.Response, []byte, error) {
+			return s.attempt("DELETE", uploadURL, nil, nil, "")
+		})
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *GraphService) uploadChunks(uploadURL string, content io.Reader, size int64) (map[string]any, error) {
+	chunk := make([]byte, defaultUploadChunk)
+	var offset int64
+
+	for offset < size {
+		n, err := io.ReadFull(content, chunk[:min(int64(len(chunk)), size-offset)])
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("failed to read upload content: %w", err)
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("upload content shorter than announced size (%d/%d bytes)", offset, size)
+		}
+
+		headers := map[string]string{
+			"Content-Type":  "application/octet-stream",
+			"Content-Range": fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(n)-1, size),
+		}
+		data := chunk[:n]
+
+		// PUT d'un fragment : rejouable tel quel, sans jeton (URL pré-authentifiée)
+		resp, bodyBytes, err := s.exchange("PUT", uploadURL, false, func(string) (*http.Response, []byte, error) {
+			return s.attempt("PUT", uploadURL, data, headers, "")
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 400 {
+			return nil, newGraphError(resp.StatusCode, flattenHeaders(resp.Header), bodyBytes)
+		}
+
+		offset += int64(n)
+		log.Printf("=== GRAPH UPLOAD === %d/%d bytes", offset, size)
+
+		// 200/201 : dernier fragment reçu, le corps décrit l'élément créé
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+			return decodeContentResponse(resp, bodyBytes)
+		}
+	}
+
+	return nil, fmt.Errorf("upload session did not complete after %d bytes", size)
+}
+
+func decodeContentResponse(resp *http.Response, bodyBytes []byte) (map[string]any, error) {
+	if resp.StatusCode >= 400 {
+		return nil, newGraphError(resp.StatusCode, flattenHeaders(resp.Header), bodyBytes)
+	}
+	if len(bodyBytes) == 0 {
+		return nil, nil
+	}
+	var result map[string]any
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// contentStore - Hébergement pré-authentifié : refuse tout jeton Graph
+func contentStore(t *testing.T, content []byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("bearer token sent to the pre-authenticated URL")
+		}
+		http.ServeContent(w, r, "report.pdf", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDownloadFollowsRedirectWithRange(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 100))
+	store := contentStore(t, content)
+	// Autre nom d'hôte que le serveur Graph : l'en-tête Authorization ne suit pas
+	storeURL := strings.Replace(store.URL, "127.0.0.1", "localhost", 1)
+
+	graph := newTestGraphService(t, GraphOptions{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer app-token" {
+			t.Errorf("Graph request without token")
+		}
+		http.Redirect(w, r, storeURL+"/content?sig=x", http.StatusFound)
+	}))
+
+	stream, err := graph.Download("/users/user-1/drive/items/item-1/content", &ByteRange{Start: 10, End: 19})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	body, _ := io.ReadAll(stream.Body)
+	if stream.Status != http.StatusPartialContent || string(body) != "0123456789" || stream.ContentLength != 10 {
+		t.Fatalf("status %d, %d bytes: %q", stream.Status, stream.ContentLength, body)
+	}
+
+	stream, err = graph.Download("/users/user-1/drive/items/item-1/content", &ByteRange{Start: 990, End: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(stream.Body)
+	stream.Body.Close()
+	if string(body) != "0123456789" {
+		t.Fatalf("open-ended range = %q", body)
+	}
+}
+
+func TestDownloadReportsGraphError(t *testing.T) {
+	graph := newTestGraphService(t, GraphOptions{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": "itemNotFound", "message": "The resource could not be found."}})
+	}))
+
+	var graphErr *GraphError
+	if _, err := graph.Download("/me/drive/items/missing/content", nil); err == nil || !errors.As(err, &graphErr) || graphErr.Status != http.StatusNotFound {
+		t.Fatalf("expected a 404 GraphError, got %v", err)
+	}
+}
+
+// uploadSession - Session d'upload Graph : reçoit les fragments et vérifie leur ordre
+type uploadSession struct {
+	t        *testing.T
+	mu       sync.Mutex
+	received bytes.Buffer
+	ranges   []string
+	failOnce int // numéro du fragment répondu 503 une fois
+	deleted  bool
+}
+
+func (u *uploadSession) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "" {
+		u.t.Errorf("bearer token sent to the upload URL")
+	}
+	if r.Method == "DELETE" {
+		u.deleted = true
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	chunk, _ := io.ReadAll(r.Body)
+	if u.failOnce == len(u.ranges)+1 {
+		u.failOnce = 0
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var start, end, size int
+	fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &size)
+	if start != u.received.Len() || end-start+1 != len(chunk) {
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	u.ranges = append(u.ranges, r.Header.Get("Content-Range"))
+	u.received.Write(chunk)
+
+	if u.received.Len() < size {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{"nextExpectedRanges": []string{fmt.Sprintf("%d-", u.received.Len())}})
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"id": "item-1", "webUrl": "https://contoso-my.sharepoint.com/video.mp4", "size": size})
+}
+
+func newUploadTest(t *testing.T, session *uploadSession) *GraphService {
+	t.Helper()
+	upload := httptest.NewServer(session)
+	t.Cleanup(upload.Close)
+
+	// Un fragment en échec (503) est rejoué seul
+	return newTestGraphService(t, GraphOptions{MaxRetries: 1}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, ":/createUploadSession") {
+			t.Errorf("unexpected Graph call %s %s", r.Method, r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"uploadUrl": upload.URL + "/upload/session-1"})
+	}))
+}
+
+func TestUploadLargeSendsChunks(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 2*defaultUploadChunk+1000)
+	session := &uploadSession{t: t, failOnce: 2}
+	graph := newUploadTest(t, session)
+
+	item, err := graph.UploadLarge("/users/user-1/drive/root:/NEO/video.mp4:/createUploadSession", nil, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item["id"] != "item-1" {
+		t.Fatalf("item = %v", item)
+	}
+
+	want := []string{
+		fmt.Sprintf("bytes 0-%d/%d", defaultUploadChunk-1, len(content)),
+		fmt.Sprintf("bytes %d-%d/%d", defaultUploadChunk, 2*defaultUploadChunk-1, len(content)),
+		fmt.Sprintf("bytes %d-%d/%d", 2*defaultUploadChunk, len(content)-1, len(content)),
+	}
+	if strings.Join(session.ranges, ",") != strings.Join(want, ",") {
+		t.Fatalf("ranges = %v, want %v", session.ranges, want)
+	}
+	if !bytes.Equal(session.received.Bytes(), content) {
+		t.Fatal("uploaded content differs")
+	}
+	if session.failOnce != 0 || session.deleted {
+		t.Fatal("failed chunk was not retried within the session")
+	}
+	if defaultUploadChunk%uploadChunkUnit != 0 {
+		t.Fatal("chunk size is not a multiple of 320 KiB")
+	}
+}
+
+func TestUploadLargeCancelsSessionOnShortContent(t *testing.T) {
+	session := &uploadSession{t: t}
+	graph := newUploadTest(t, session)
+
+	content := bytes.Repeat([]byte("x"), 1000)
+	if _, err := graph.UploadLarge("/me/drive/root:/a.bin:/createUploadSession", nil, bytes.NewReader(content), 5000); err == nil {
+		t.Fatal("short content accepted")
+	}
+	if !session.deleted {
+		t.Fatal("upload session not deleted after failure")
+	}
+}
+
+func TestPutContent(t *testing.T) {
+	graph := newTestGraphService(t, GraphOptions{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != "PUT" || r.Header.Get("Content-Type") != "text/markdown" || string(body) != "# Export" {
+			t.Errorf("%s %s (%s): %q", r.Method, r.URL.Path, r.Header.Get("Content-Type"), body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"id": "item-1"})
+	}))
+
+	item, err := graph.PutContent("/me/drive/root:/NEO/export.md:/content", "text/markdown", []byte("# Export"))
+	if err != nil || item["id"] != "item-1" {
+		t.Fatalf("PutContent = %v, %v", item, err)
+	}
+	if _, err := graph.PutContent("/me/drive/root:/big.bin:/content", "application/octet-stream", make([]byte, simpleUploadMaxSize+1)); err == nil {
+		t.Fatal("content above 4 MB accepted")
+	}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GraphError - Erreur renvoyée par Microsoft Graph, avec les identifiants à
+// communiquer au support Microsoft
+type GraphError struct {
+	Status          int
+	Code            string
+	Message         string
+	RequestID       string
+	ClientRequestID string
+	InnerError      map[string]any
+}
+
+func (e *GraphError) Error() string {
+	msg := fmt.Sprintf("graph error %d", e.Status)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " (request-id: " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// newGraphError - Décode le corps {"error": {...}} ; les en-têtes priment sur innerError
+func newGraphError(status int, headers map[string]string, body []byte) *GraphError {
+	graphErr := &GraphError{Status: status}
+
+	var payload struct {
+		Error struct {
+			Code       string         `json:"code"`
+			Message    string         `json:"message"`
+			InnerError map[string]any `json:"innerError"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Error.Code != "" {
+		graphErr.Code = payload.Error.Code
+		graphErr.Message = payload.Error.Message
+		graphErr.InnerError = payload.Error.InnerError
+		graphErr.RequestID, _ = payload.Error.InnerError["request-id"].(string)
+		graphErr.ClientRequestID, _ = payload.Error.InnerError["client-request-id"].(string)
+	} else {
+		graphErr.Message = strings.TrimSpace(string(body))
+	}
+
+	if id := headerValue(headers, "request-id"); id != "" {
+		graphErr.RequestID = id
+	}
+	if id := headerValue(headers, "client-request-id"); id != "" {
+		graphErr.ClientRequestID = id
+	}
+	return graphErr
+}
+
+func flattenHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name := range header {
+		headers[name] = header.Get(name)
+	}
+	return headers
+}
+
+// newClientRequestID - UUID v4 envoyé en client-request-id pour corréler les logs
+func newClientRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// permissionDeniedMessage - Message d'outil pour un 403 : nomme la permission manquante
+func permissionDeniedMessage(toolName string, graphErr *GraphError) string {
+	permissions, delegated := toolPermissions(toolName)
+
+	msg := fmt.Sprintf("Erreur: Microsoft Graph a refusé l'accès pour l'outil « %s » (403", toolName)
+	if graphErr.Code != "" {
+		msg += ", " + graphErr.Code
+	}
+	msg += ")."
+
+	switch {
+	case len(permissions) == 0:
+		msg += " L'application NEO n'a pas les droits nécessaires."
+	case delegated:
+		msg += fmt.Sprintf(" La permission déléguée %s doit être consentie pour l'utilisateur.", strings.Join(permissions, ", "))
+	default:
+		msg += fmt.Sprintf(" L'application NEO n'a probablement pas la permission d'application %s : un administrateur doit l'accorder (consentement administrateur) dans Azure AD.", strings.Join(permissions, ", "))
+	}
+
+	if graphErr.RequestID != "" {
+		msg += " request-id: " + graphErr.RequestID
+	}
+	return msg
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	operationPollInterval = 5 * time.Second
+	operationTimeout      = 10 * time.Minute
+	// Erreurs de lecture consécutives tolérées (l'opération peut ne pas encore être visible)
+	operationMaxErrors = 5
+
+	operationJob = "graph.operation"
+)
+
+// ConversationReference - De quoi écrire dans une conversation Teams hors d'un tour
+type ConversationReference struct {
+	ServiceURL     string
+	ChannelID      string
+	ConversationID string
+	TenantID       string
+	BotID          string
+	BotName        string
+	UserID         string // ID Bot Framework du destinataire
+	UserName       string
+}
+
+// ProactiveNotifier - Envoi d'un message proactif (implémenté par le handler du bot)
+type ProactiveNotifier interface {
+	Notify(ref *ConversationReference, text string) error
+}
+
+// OperationResult - État final d'une opération longue Graph (teamsAsyncOperation...)
+type OperationResult struct {
+	Status                 string // succeeded, failed, timeout
+	TargetResourceID       string
+	TargetResourceLocation string
+	Err                    error
+}
+
+// OperationFollowUp - Message à envoyer quand une opération suivie en arrière-plan se termine
+type OperationFollowUp struct {
+	Kind         string // team
+	Name         string
+	Conversation *ConversationReference
+}
+
+func (f OperationFollowUp) message(result OperationResult) string {
+	if f.Kind == "team" {
+		return teamCreationMessage(f.Name, result)
+	}
+	if result.Err != nil {
+		return fmt.Sprintf("❌ L'opération '%s' a échoué : %v", f.Name, result.Err)
+	}
+	return fmt.Sprintf("✅ L'opération '%s' est terminée (%s).", f.Name, result.Status)
+}
+
+// operationPayload - Job de suivi d'une opération, repris après un redémarrage
+type operationPayload struct {
+	TenantID string            `json:"tenant_id,omitempty"`
+	UserID   string            `json:"user_id,omitempty"` // jeton délégué si défini
+	Location string            `json:"location"`
+	Deadline time.Time         `json:"deadline"`
+	FollowUp OperationFollowUp `json:"follow_up"`
+}
+
+// OperationTracker - Suit les opérations 202 Accepted via leur en-tête Location
+type OperationTracker struct {
+	notifier     ProactiveNotifier
+	jobs         *JobQueue
+	graphService *GraphService
+	interval     time.Duration
+	timeout      time.Duration
+}
+
+func NewOperationTracker() *OperationTracker {
+	return &OperationTracker{
+		interval: operationPollInterval,
+		timeout:  operationTimeout,
+	}
+}
+
+// SetNotifier - Le handler du bot est créé après les services
+func (t *OperationTracker) SetNotifier(notifier ProactiveNotifier) {
+	t.notifier = notifier
+}
+
+// RegisterJobs - Au-delà de l'attente inline, le suivi continue dans la file de jobs
+func (t *OperationTracker) RegisterJobs(jobs *JobQueue, graphService *GraphService) {
+	t.jobs = jobs
+	t.graphService = graphService
+	jobs.Register(operationJob, t.runJob)
+}
+
+// Track - Attend l'opération jusqu'à wait ; au-delà, retourne done=false et un
+// job prend le relais pour envoyer followUp quand l'opération se termine
+func (t *OperationTracker) Track(graphService *GraphService, location string, wait time.Duration, followUp OperationFollowUp) (OperationResult, bool) {
+	deadline := time.Now().Add(wait)
+	errorCount := 0
+
+	for {
+		result, done, delay, err := t.check(graphService, location)
+		if done {
+			return result, true
+		}
+		if err != nil {
+			errorCount++
+			if errorCount >= operationMaxErrors {
+				return OperationResult{Status: "failed", Err: err}, true
+			}
+			log.Printf("[Operations] Lecture de %s impossible (%d/%d): %v", location, errorCount, operationMaxErrors, err)
+		} else {
+			errorCount = 0
+		}
+
+		if time.Now().Add(delay).After(deadline) {
+			t.follow(graphService, location, delay, followUp)
+			return OperationResult{Status: "inProgress"}, false
+		}
+		time.Sleep(delay)
+	}
+}
+
+// Notify - Message proactif ; sans référence de conversation, on se contente du log.
+// Une erreur fait rejouer le job de suivi.
+func (t *OperationTracker) Notify(ref *ConversationReference, text string) error {
+	if t.notifier == nil || ref == nil {
+		log.Printf("[Operations] Pas de conversation à notifier: %s", text)
+		return nil
+	}
+	if err := t.notifier.Notify(ref, text); err != nil {
+		return fmt.Errorf("failed to notify operation result: %w", err)
+	}
+	return nil
+}
+
+func (t *OperationTracker) follow(graphService *GraphService, location string, delay time.Duration, followUp OperationFollowUp) {
+	if t.jobs == nil {
+		log.Printf("[Operations] Pas de file de jobs, %s ne sera pas suivie", location)
+		return
+	}
+	payload := operationPayload{
+		TenantID: graphService.tenantID,
+		UserID:   graphService.userID,
+		Location: location,
+		Deadline: time.Now().Add(t.timeout),
+		FollowUp: followUp,
+	}
+	opts := EnqueueOptions{RunAt: time.Now().Add(delay), MaxAttempts: operationMaxErrors}
+	if _, err := t.jobs.Enqueue(operationJob, payload, opts); err != nil {
+		log.Printf("[Operations] Suivi de %s impossible: %v", location, err)
+	}
+}
+
+// runJob - Une lecture par exécution ; le job est replanifié tant que l'opération tourne
+func (t *OperationTracker) runJob(job *Job) error {
+	var payload operationPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+
+	graphService := t.graphService.ForTenant(payload.TenantID)
+	if payload.UserID != "" {
+		graphService = t.graphService.AsUser(payload.TenantID, payload.UserID)
+	}
+
+	result, done, delay, err := t.check(graphService, payload.Location)
+	switch {
+	case done:
+	case err != nil && !job.LastAttempt():
+		return err
+	case err != nil:
+		result = OperationResult{Status: "failed", Err: err}
+	case time.Now().After(payload.Deadline):
+		result = OperationResult{Status: "timeout", Err: fmt.Errorf("operation still running after %s", t.timeout)}
+	default:
+		return RescheduleJob(delay)
+	}
+
+	return t.Notify(payload.FollowUp.Conversation, payload.FollowUp.message(result))
+}
+
+// check - Une lecture de l'opération : done si elle est terminée, sinon le délai
+// avant la prochaine lecture et l'éventuelle erreur de lecture
+func (t *OperationTracker) check(graphService *GraphService, location string) (OperationResult, bool, time.Duration, error) {
+	url := location
+	if !strings.HasPrefix(location, "http") {
+		url = graphService.baseURL + location
+	}
+
+	resp, err := graphService.do("GET", url, nil)
+	if err != nil {
+		if errors.Is(err, ErrUserSignInRequired) {
+			return OperationResult{Status: "failed", Err: err}, true, 0, nil
+		}
+		return OperationResult{}, false, t.interval, err
+	}
+
+	status, _ := resp.Body["status"].(string)
+	switch status {
+	case "succeeded":
+		result := OperationResult{Status: status}
+		result.TargetResourceID, _ = resp.Body["targetResourceId"].(string)
+		result.TargetResourceLocation, _ = resp.Body["targetResourceLocation"].(string)
+		return result, true, 0, nil
+	case "failed", "invalid":
+		return OperationResult{Status: "failed", Err: operationError(resp.Body)}, true, 0, nil
+	}
+
+	delay := t.interval
+	if retryAfter, ok := parseRetryAfter(resp.Headers.Get("Retry-After")); ok && retryAfter > 0 {
+		delay = min(retryAfter, retryAfterMax)
+	}
+	return OperationResult{}, false, delay, nil
+}
+
+func operationError(body map[string]any) error {
+	if detail, ok := body["error"].(map[string]any); ok {
+		code, _ := detail["code"].(string)
+		message, _ := detail["message"].(string)
+		return fmt.Errorf("operation failed: %s %s", code, message)
+	}
+	return fmt.Errorf("operation failed")
+}
@@ -0,0 +1,142 @@
+package services
+
+import "fmt"
+
+// Limites appliquées si ni l'appel ni le service n'en fixent (GRAPH_MAX_ITEMS, GRAPH_MAX_PAGES)
+const (
+	defaultPageMaxItems = 200
+	defaultPageMaxPages = 10
+)
+
+// PageOptions - Limites de pagination ; 0 = limites par défaut du service
+type PageOptions struct {
+	MaxItems int
+	MaxPages int
+}
+
+// PagedResult - Résultat d'un parcours @odata.nextLink
+type PagedResult struct {
+	Items     []map[string]any
+	Pages     int
+	Truncated bool   // d'autres résultats existaient au-delà des limites
+	NextLink  string // page suivante non récupérée, pour reprendre le parcours
+	DeltaLink string // fin d'une requête delta
+}
+
+func (o PageOptions) withDefaults(defaults PageOptions) PageOptions {
+	if o.MaxItems <= 0 {
+		o.MaxItems = defaults.MaxItems
+	}
+	if o.MaxPages <= 0 {
+		o.MaxPages = defaults.MaxPages
+	}
+	// Sans limite, le premier test de boucle tronquerait tout parcours à zéro page
+	if o.MaxItems <= 0 {
+		o.MaxItems = defaultPageMaxItems
+	}
+	if o.MaxPages <= 0 {
+		o.MaxPages = defaultPageMaxPages
+	}
+	return o
+}
+
+// Iterate - Parcourt les éléments de toutes les pages ; fn retourne false pour arrêter
+func (s *GraphService) Iterate(endpoint string, opts PageOptions, fn func(item map[string]any) bool) (*PagedResult, error) {
+	return s.iterate(s.baseURL+endpoint, opts, fn)
+}
+
+func (s *GraphService) IterateBeta(endpoint string, opts PageOptions, fn func(item map[string]any) bool) (*PagedResult, error) {
+	return s.iterate(s.betaURL+endpoint, opts, fn)
+}
+
+// GetAll - Collecte les éléments de toutes les pages, dans la limite des options
+func (s *GraphService) GetAll(endpoint string, opts PageOptions) (*PagedResult, error) {
+	return s.collect(s.baseURL+endpoint, opts)
+}
+
+func (s *GraphService) GetAllBeta(endpoint string, opts PageOptions) (*PagedResult, error) {
+	return s.collect(s.betaURL+endpoint, opts)
+}
+
+func (s *GraphService) collect(url string, opts PageOptions) (*PagedResult, error) {
+	items := []map[string]any{}
+	result, err := s.iterate(url, opts, func(item map[string]any) bool {
+		items = append(items, item)
+		return true
+	})
+	if result != nil {
+		result.Items = items
+	}
+	return result, err
+}
+
+func (s *GraphService) iterate(nextURL string, opts PageOptions, fn func(item map[string]any) bool) (*PagedResult, error) {
+	opts = opts.withDefaults(s.pageLimits)
+	result := &PagedResult{}
+	count := 0
+
+	for nextURL != "" {
+		if result.Pages >= opts.MaxPages {
+			result.Truncated = true
+			result.NextLink = nextURL
+			return result, nil
+		}
+
+		page, err := s.request("GET", nextURL, nil)
+		if err != nil {
+			return result, err
+		}
+		result.Pages++
+
+		items, _ := page["value"].([]any)
+		nextURL, _ = page["@odata.nextLink"].(string)
+		result.DeltaLink, _ = page["@odata.deltaLink"].(string)
+
+		for i, raw := range items {
+			item, ok := raw.(map[string]any)
+			if !ok {
+				continue
+			}
+			if count >= opts.MaxItems {
+				result.Truncated = true
+				result.NextLink = nextURL
+				return result, nil
+			}
+			count++
+			if !fn(item) {
+				// Arrêt demandé par l'appelant : reste-t-il des éléments ?
+				result.Truncated = i < len(items)-1 || nextURL != ""
+				result.NextLink = nextURL
+				return result, nil
+			}
+		}
+
+		if count >= opts.MaxItems && nextURL != "" {
+			result.Truncated = true
+			result.NextLink = nextURL
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+// pagedResponse - Résultat d'outil : signale au modèle que la liste est incomplète
+func pagedResponse(result *PagedResult, err error) (map[string]any, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	response := map[string]any{
+		"value": result.Items,
+		"count": len(result.Items),
+	}
+	if result.Truncated {
+		response["truncated"] = true
+		response["note"] = fmt.Sprintf("Résultats tronqués : seuls les %d premiers éléments sont affichés, il en existe d'autres. Précise la recherche si besoin.", len(result.Items))
+	}
+	if result.DeltaLink != "" {
+		response["@odata.deltaLink"] = result.DeltaLink
+	}
+	return response, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+// pagedGraph - Pages de pageSize éléments chaînées par @odata.nextLink, sans fin
+func pagedGraph(pages *atomic.Int32, pageSize int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(pages.Add(1))
+		items := make([]map[string]any, pageSize)
+		for i := range items {
+			items[i] = map[string]any{"id": fmt.Sprintf("%d-%d", n, i)}
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"value":           items,
+			"@odata.nextLink": fmt.Sprintf("http://%s/v1.0/users?$skiptoken=%d", r.Host, n),
+		})
+	})
+}
+
+func TestGetAllDefaultLimits(t *testing.T) {
+	var pages atomic.Int32
+	graph := newTestGraphService(t, GraphOptions{}, pagedGraph(&pages, 100))
+
+	result, err := graph.GetAll("/users", PageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != defaultPageMaxItems || !result.Truncated || result.NextLink == "" {
+		t.Fatalf("%d items, truncated=%v, next=%q", len(result.Items), result.Truncated, result.NextLink)
+	}
+
+	pages.Store(0)
+	result, err = graph.GetAll("/users", PageOptions{MaxItems: 10000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Pages != defaultPageMaxPages || int(pages.Load()) != defaultPageMaxPages || !result.Truncated {
+		t.Fatalf("%d pages fetched, %d requests", result.Pages, pages.Load())
+	}
+}
+
+func TestGetAllServiceLimits(t *testing.T) {
+	var pages atomic.Int32
+	graph := newTestGraphService(t, GraphOptions{PageLimits: PageOptions{MaxItems: 15, MaxPages: 3}}, pagedGraph(&pages, 10))
+
+	result, err := graph.GetAll("/users", PageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 15 || result.Pages != 2 || !result.Truncated {
+		t.Fatalf("%d items over %d pages, truncated=%v", len(result.Items), result.Pages, result.Truncated)
+	}
+
+	// Limite de l'appel prioritaire sur celle du service
+	result, _ = graph.GetAll("/users", PageOptions{MaxItems: 5})
+	if len(result.Items) != 5 || result.Items[4]["id"] == nil {
+		t.Fatalf("%d items", len(result.Items))
+	}
+}
+
+func TestIterateStopsAcrossNextLink(t *testing.T) {
+	var pages atomic.Int32
+	graph := newTestGraphService(t, GraphOptions{}, pagedGraph(&pages, 10))
+
+	var seen []string
+	result, err := graph.Iterate("/users", PageOptions{}, func(item map[string]any) bool {
+		seen = append(seen, item["id"].(string))
+		return len(seen) < 15
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 15 || seen[14] != "2-4" {
+		t.Fatalf("seen %d items, last %v", len(seen), seen[len(seen)-1])
+	}
+	if pages.Load() != 2 || result.Pages != 2 || !result.Truncated || result.NextLink == "" {
+		t.Fatalf("%d requests, result = %+v", pages.Load(), result)
+	}
+	if result.Items != nil {
+		t.Fatal("Iterate must not collect items")
+	}
+
+	// Les limites s'appliquent aussi au parcours en streaming
+	pages.Store(0)
+	count := 0
+	result, err = graph.IterateBeta("/users", PageOptions{MaxItems: 5}, func(map[string]any) bool {
+		count++
+		return true
+	})
+	if err != nil || count != 5 || !result.Truncated || pages.Load() != 1 {
+		t.Fatalf("count %d, %d requests, %+v, %v", count, pages.Load(), result, err)
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Noms de propriétés OData acceptés : start/dateTime, teamsAppDefinition...
+var odataIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(/[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// Query - Paramètres OData d'une requête Graph, encodés et échappés à la construction
+type Query struct {
+	selects []string
+	filter  string
+	search  string
+	orderBy []string
+	expand  []string
+	top     int
+}
+
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// Select - $select ; les noms invalides sont ignorés
+func (q *Query) Select(fields ...string) *Query {
+	q.selects = append(q.selects, validFields("$select", fields)...)
+	return q
+}
+
+// Filter - $filter, à construire avec Eq, And... qui échappent les valeurs
+func (q *Query) Filter(expr string) *Query {
+	q.filter = expr
+	return q
+}
+
+// Search - $search sur un terme libre, entre guillemets
+func (q *Query) Search(term string) *Query {
+	q.search = SearchPhrase(term)
+	return q
+}
+
+// SearchProperty - $search restreint à une propriété (from:, subject:, importance:...)
+func (q *Query) SearchProperty(property, value string) *Query {
+	if !odataIdentifier.MatchString(property) {
+		log.Printf("⚠️ OData: propriété $search invalide ignorée: %q", property)
+		return q
+	}
+	q.search = SearchPhrase(property + ":" + value)
+	return q
+}
+
+// OrderBy - $orderby ; suffixer par " desc" pour l'ordre décroissant
+func (q *Query) OrderBy(fields ...string) *Query {
+	for _, field := range fields {
+		name, direction, _ := strings.Cut(strings.TrimSpace(field), " ")
+		if direction != "" && direction != "asc" && direction != "desc" {
+			log.Printf("⚠️ OData: tri invalide ignoré: %q", field)
+			continue
+		}
+		if validFields("$orderby", []string{name}) == nil {
+			continue
+		}
+		q.orderBy = append(q.orderBy, strings.TrimSpace(name+" "+direction))
+	}
+	return q
+}
+
+func (q *Query) Expand(fields ...string) *Query {
+	q.expand = append(q.expand, validFields("$expand", fields)...)
+	return q
+}
+
+func (q *Query) Top(n int) *Query {
+	if n > 0 {
+		q.top = n
+	}
+	return q
+}
+
+// String - Chaîne de requête avec le "?" initial, vide sans paramètre
+func (q *Query) String() string {
+	var params []string
+	add := func(name, value string) {
+		params = append(params, name+"="+queryEscape(value))
+	}
+
+	if len(q.selects) > 0 {
+		add("$select", strings.Join(q.selects, ","))
+	}
+	if q.filter != "" {
+		add("$filter", q.filter)
+	}
+	if q.search != "" {
+		add("$search", q.search)
+	}
+	if len(q.orderBy) > 0 {
+		add("$orderby", strings.Join(q.orderBy, ","))
+	}
+	if len(q.expand) > 0 {
+		add("$expand", strings.Join(q.expand, ","))
+	}
+	if q.top > 0 {
+		add("$top", strconv.Itoa(q.top))
+	}
+
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + strings.Join(params, "&")
+}
+
+// ODataString - Littéral chaîne OData : apostrophes doublées
+func ODataString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// SearchPhrase - Phrase $search entre guillemets ; \ et " sont échappés
+func SearchPhrase(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+// Eq - Comparaison property eq 'value'
+func Eq(property, value string) string {
+	if !odataIdentifier.MatchString(property) {
+		log.Printf("⚠️ OData: propriété $filter invalide: %q", property)
+		return ""
+	}
+	return property + " eq " + ODataString(value)
+}
+
+// And - Conjonction des expressions non vides
+func And(exprs ...string) string {
+	var parts []string
+	for _, expr := range exprs {
+		if expr != "" {
+			parts = append(parts, "("+expr+")")
+		}
+	}
+	return strings.Join(parts, " and ")
+}
+
+// PathSegment - Valeur insérée dans un chemin Graph (ID, email) : tout caractère
+// qui changerait la structure du chemin est encodé (/, ?, #, :, $, segments . et ..)
+func PathSegment(value string) string {
+	if value == "." || value == ".." {
+		return strings.ReplaceAll(value, ".", "%2E")
+	}
+	escaped := url.PathEscape(value)
+	escaped = strings.ReplaceAll(escaped, ":", "%3A")
+	escaped = strings.ReplaceAll(escaped, "$", "%24")
+	return escaped
+}
+
+// KeySegment - Clé entre parenthèses, ex. users('id') dans un @odata.bind
+func KeySegment(entitySet, value string) string {
+	return entitySet + "(" + url.PathEscape(ODataString(value)) + ")"
+}
+
+// queryEscape - Comme url.QueryEscape, avec %20 pour les espaces attendus par Graph
+func queryEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func validFields(option string, fields []string) []string {
+	var valid []string
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if !odataIdentifier.MatchString(field) {
+			log.Printf("⚠️ OData: champ %s invalide ignoré: %q", option, field)
+			continue
+		}
+		valid = append(valid, field)
+	}
+	return valid
+}
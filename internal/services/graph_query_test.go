@@ -0,0 +1,187 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var injectionSeeds = []string{
+	"",
+	"abc",
+	"user@contoso.com",
+	"../../me",
+	".",
+	"..",
+	"a/b?c#d",
+	"x'y",
+	"'",
+	"''",
+	`"`,
+	`\`,
+	`a\"b`,
+	"id') or true or ('",
+	"subject:x&$top=999",
+	"%2F%3F",
+	"AAMkAGI2TG93AAA=",
+	"é 日本",
+	"\x00\xff",
+}
+
+// unquoteOData - Inverse de ODataString ; false si une apostrophe n'est pas doublée
+func unquoteOData(literal string) (string, bool) {
+	if len(literal) < 2 || literal[0] != '\'' || literal[len(literal)-1] != '\'' {
+		return "", false
+	}
+	inner := literal[1 : len(literal)-1]
+	var b strings.Builder
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\'' {
+			if i+1 >= len(inner) || inner[i+1] != '\'' {
+				return "", false
+			}
+			i++
+		}
+		b.WriteByte(inner[i])
+	}
+	return b.String(), true
+}
+
+// unquoteSearch - Inverse de SearchPhrase ; false si un guillemet n'est pas échappé
+func unquoteSearch(phrase string) (string, bool) {
+	if len(phrase) < 2 || phrase[0] != '"' || phrase[len(phrase)-1] != '"' {
+		return "", false
+	}
+	inner := phrase[1 : len(phrase)-1]
+	var b strings.Builder
+	for i := 0; i < len(inner); i++ {
+		switch inner[i] {
+		case '"':
+			return "", false
+		case '\\':
+			if i+1 >= len(inner) {
+				return "", false
+			}
+			i++
+		}
+		b.WriteByte(inner[i])
+	}
+	return b.String(), true
+}
+
+// parseGraphURL - L'URL construite doit garder un seul paramètre et aucun fragment
+func parseGraphURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("invalid url %q: %v", raw, err)
+	}
+	if u.Fragment != "" || strings.Contains(raw, "#") {
+		t.Fatalf("fragment escaped into %q", raw)
+	}
+	return u
+}
+
+func FuzzPathSegment(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		segment := PathSegment(value)
+		if strings.ContainsAny(segment, "/?#:$") {
+			t.Fatalf("PathSegment(%q) = %q keeps a structural character", value, segment)
+		}
+		if segment == "." || segment == ".." {
+			t.Fatalf("PathSegment(%q) = %q is a dot segment", value, segment)
+		}
+		if unescaped, err := url.PathUnescape(segment); err != nil || unescaped != value {
+			t.Fatalf("PathSegment(%q) = %q does not round-trip: %q, %v", value, segment, unescaped, err)
+		}
+
+		u := parseGraphURL(t, "https://graph.microsoft.com/v1.0/users/"+segment+"/messages")
+		if u.RawQuery != "" || u.ForceQuery {
+			t.Fatalf("query escaped into %q", u.String())
+		}
+		if parts := strings.Split(u.EscapedPath(), "/"); len(parts) != 5 || parts[3] != segment {
+			t.Fatalf("path structure changed: %q", u.EscapedPath())
+		}
+	})
+}
+
+func FuzzKeySegment(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		key := KeySegment("users", value)
+		inner, ok := strings.CutPrefix(key, "users(")
+		if !ok || !strings.HasSuffix(inner, ")") {
+			t.Fatalf("KeySegment(%q) = %q", value, key)
+		}
+		inner = strings.TrimSuffix(inner, ")")
+		if strings.ContainsAny(inner, "/?#()") {
+			t.Fatalf("KeySegment(%q) = %q keeps a structural character", value, key)
+		}
+
+		literal, err := url.PathUnescape(inner)
+		if err != nil {
+			t.Fatalf("KeySegment(%q) = %q: %v", value, key, err)
+		}
+		if decoded, ok := unquoteOData(literal); !ok || decoded != value {
+			t.Fatalf("KeySegment(%q) = %q has unbalanced quotes (%q)", value, key, literal)
+		}
+
+		u := parseGraphURL(t, "https://graph.microsoft.com/v1.0/directoryObjects/"+key)
+		if u.RawQuery != "" || strings.Count(u.EscapedPath(), "/") != 3 {
+			t.Fatalf("KeySegment(%q) changed the URL structure: %q", value, u.String())
+		}
+	})
+}
+
+func FuzzSearchPhrase(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		phrase := SearchPhrase(value)
+		if decoded, ok := unquoteSearch(phrase); !ok || decoded != value {
+			t.Fatalf("SearchPhrase(%q) = %q has an unbalanced quote", value, phrase)
+		}
+
+		query := NewQuery().Search(value).Top(5).String()
+		u := parseGraphURL(t, "https://graph.microsoft.com/v1.0/me/messages"+query)
+		params, err := url.ParseQuery(u.RawQuery)
+		if err != nil {
+			t.Fatalf("invalid query %q: %v", query, err)
+		}
+		if len(params) != 2 || len(params["$search"]) != 1 || params.Get("$search") != phrase || params.Get("$top") != "5" {
+			t.Fatalf("Search(%q) escaped its parameter: %v", value, params)
+		}
+	})
+}
+
+func FuzzODataString(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		literal := ODataString(value)
+		if decoded, ok := unquoteOData(literal); !ok || decoded != value {
+			t.Fatalf("ODataString(%q) = %q has an unbalanced quote", value, literal)
+		}
+
+		filter := And(Eq("subject", value), Eq("from/emailAddress/address", "a@b.c"))
+		query := NewQuery().Filter(filter).Select("id").String()
+		u := parseGraphURL(t, "https://graph.microsoft.com/v1.0/me/messages"+query)
+		params, err := url.ParseQuery(u.RawQuery)
+		if err != nil {
+			t.Fatalf("invalid query %q: %v", query, err)
+		}
+		if len(params) != 2 || params.Get("$filter") != filter || params.Get("$select") != "id" {
+			t.Fatalf("Filter(%q) escaped its parameter: %v", value, params)
+		}
+		if !strings.HasSuffix(params.Get("$filter"), " and (from/emailAddress/address eq 'a@b.c')") {
+			t.Fatalf("filter structure changed: %q", params.Get("$filter"))
+		}
+	})
+}
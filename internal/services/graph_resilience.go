@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrGraphUnavailable - Disjoncteur ouvert : Graph est considéré dégradé
+var ErrGraphUnavailable = errors.New("graph temporarily unavailable (circuit breaker open)")
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+	// Au-delà, on abandonne plutôt que de bloquer la conversation
+	retryAfterMax = time.Minute
+)
+
+// GraphOptions - Paramètres du client Graph (pagination, retries, disjoncteur)
+type GraphOptions struct {
+	Host             string // hôte Graph du cloud, ex. https://graph.microsoft.us
+	PageLimits       PageOptions
+	Timeout          time.Duration
+	MaxRetries       int
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// GraphMetrics - Compteurs exposés sur /health
+type GraphMetrics struct {
+	Requests     int64  `json:"requests"`
+	Retries      int64  `json:"retries"`
+	Throttled    int64  `json:"throttled"`
+	Failures     int64  `json:"failures"`
+	BreakerState string `json:"breaker_state"`
+	BreakerOpens int64  `json:"breaker_opens"`
+}
+
+// graphResilience - État partagé entre les copies du GraphService (ForTenant, AsUser)
+type graphResilience struct {
+	timeout    time.Duration
+	maxRetries int
+	breaker    *circuitBreaker
+
+	requests  atomic.Int64
+	retries   atomic.Int64
+	throttled atomic.Int64
+	failures  atomic.Int64
+}
+
+func newGraphResilience(opts GraphOptions) *graphResilience {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	return &graphResilience{
+		timeout:    opts.Timeout,
+		maxRetries: opts.MaxRetries,
+		breaker:    newCircuitBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
+	}
+}
+
+func (r *graphResilience) metrics() GraphMetrics {
+	state, opens := r.breaker.snapshot()
+	return GraphMetrics{
+		Requests:     r.requests.Load(),
+		Retries:      r.retries.Load(),
+		Throttled:    r.throttled.Load(),
+		Failures:     r.failures.Load(),
+		BreakerState: state,
+		BreakerOpens: opens,
+	}
+}
+
+// retryDelay - Indique si la tentative doit être rejouée et après quel délai
+func (r *graphResilience) retryDelay(method string, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= r.maxRetries {
+		return 0, false
+	}
+
+	if err != nil {
+		// Timeout ou erreur réseau : la requête a pu être traitée
+		if errors.Is(err, context.Canceled) || !isIdempotent(method) {
+			return 0, false
+		}
+		return backoff(attempt), true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		// Requête refusée avant traitement : rejouable quelle que soit la méthode
+		r.throttled.Add(1)
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if !isIdempotent(method) {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		if delay > retryAfterMax {
+			return 0, false
+		}
+		return delay, true
+	}
+	return backoff(attempt), true
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// backoff - Exponentiel avec jitter complet
+func backoff(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(delay))) + retryBaseDelay/2
+}
+
+// parseRetryAfter - Secondes ou date HTTP
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// circuitBreaker - S'ouvre après N échecs consécutifs (5xx, timeouts), laisse
+// passer une requête de test après le délai de refroidissement
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu          sync.Mutex
+	state       string // closed, open, half-open
+	failures    int
+	openedAt    time.Time
+	probeActive bool
+	opens       int64
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, state: "closed"}
+}
+
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case "open":
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrGraphUnavailable
+		}
+		b.state = "half-open"
+		b.probeActive = true
+		return nil
+	case "half-open":
+		if b.probeActive {
+			return ErrGraphUnavailable
+		}
+		b.probeActive = true
+	}
+	return nil
+}
+
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probeActive = false
+	if success {
+		b.state = "closed"
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == "half-open" || b.failures >= b.threshold {
+		if b.state != "open" {
+			b.opens++
+		}
+		b.state = "open"
+		b.openedAt = time.Now()
+	}
+}
+
+// release - Tentative sans verdict (429, erreur de jeton) : libère la sonde
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probeActive = false
+}
+
+func (b *circuitBreaker) snapshot() (string, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.opens
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"microsoft_connector/config"
+)
+
+// newTestGraphService - GraphService branché sur un Graph simulé, avec un point
+// de jeton local
+func newTestGraphService(t *testing.T, opts GraphOptions, graph http.Handler) *GraphService {
+	t.Helper()
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "app-token", "expires_in": 3600})
+	}))
+	t.Cleanup(tokens.Close)
+	server := httptest.NewServer(graph)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{TenantID: "tenant-1", Cloud: config.CloudProfile{GraphHost: server.URL}}
+	credentials := NewCredentialProvider("GRAPH", &secretCredential{clientID: "app", secret: "s"}, tokens.URL)
+	opts.Host = server.URL
+	return NewGraphService(NewAuthService(cfg, nil, credentials, nil), opts)
+}
+
+// scriptedGraph - Répond avec les statuts donnés, dans l'ordre, puis 200
+func scriptedGraph(requests *atomic.Int32, retryAfter string, statuses ...int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if n <= len(statuses) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(statuses[n-1])
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": "Throttled"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"id": "ok"})
+	})
+}
+
+func TestGraphRetriesThrottledPost(t *testing.T) {
+	var requests atomic.Int32
+	graph := newTestGraphService(t, GraphOptions{MaxRetries: 3}, scriptedGraph(&requests, "1", http.StatusTooManyRequests))
+
+	start := time.Now()
+	result, err := graph.Post("/users/u1/sendMail", map[string]any{"message": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["id"] != "ok" || requests.Load() != 2 {
+		t.Fatalf("result = %v after %d requests", result, requests.Load())
+	}
+	// Retry-After respecté
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("retried after %s, Retry-After was 1s", elapsed)
+	}
+	if metrics := graph.Metrics(); metrics.Throttled != 1 || metrics.Retries != 1 {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+}
+
+func TestGraphRetriesUnavailableOnlyWhenIdempotent(t *testing.T) {
+	var getRequests atomic.Int32
+	graph := newTestGraphService(t, GraphOptions{MaxRetries: 3}, scriptedGraph(&getRequests, "0", http.StatusServiceUnavailable, http.StatusGatewayTimeout))
+	if _, err := graph.Get("/me"); err != nil {
+		t.Fatal(err)
+	}
+	if n := getRequests.Load(); n != 3 {
+		t.Fatalf("GET: %d requests, want 3", n)
+	}
+
+	// Un POST a pu être traité : pas de nouvelle tentative
+	var postRequests atomic.Int32
+	graph = newTestGraphService(t, GraphOptions{MaxRetries: 3}, scriptedGraph(&postRequests, "0", http.StatusServiceUnavailable))
+	_, err := graph.Post("/teams", map[string]any{"displayName": "x"})
+	var graphErr *GraphError
+	if !errors.As(err, &graphErr) || graphErr.Status != http.StatusServiceUnavailable {
+		t.Fatalf("POST: expected a 503 GraphError, got %v", err)
+	}
+	if n := postRequests.Load(); n != 1 {
+		t.Fatalf("POST: %d requests, want 1", n)
+	}
+}
+
+func TestGraphRetryAfterCap(t *testing.T) {
+	var requests atomic.Int32
+	graph := newTestGraphService(t, GraphOptions{MaxRetries: 3}, scriptedGraph(&requests, "120", http.StatusTooManyRequests))
+
+	start := time.Now()
+	_, err := graph.Get("/me")
+	var graphErr *GraphError
+	if !errors.As(err, &graphErr) || graphErr.Status != http.StatusTooManyRequests {
+		t.Fatalf("expected a 429 GraphError, got %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("%d requests, want 1 (Retry-After beyond the cap)", n)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("waited %s despite the Retry-After cap", elapsed)
+	}
+}
+
+func TestGraphCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	var healthy atomic.Bool
+	graph := newTestGraphService(t, GraphOptions{BreakerThreshold: 3, BreakerCooldown: 200 * time.Millisecond},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			if !healthy.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"id": "ok"})
+		}))
+
+	for range 3 {
+		if _, err := graph.Get("/me"); errors.Is(err, ErrGraphUnavailable) {
+			t.Fatal("breaker opened before the threshold")
+		}
+	}
+	if _, err := graph.Get("/me"); !errors.Is(err, ErrGraphUnavailable) {
+		t.Fatalf("expected ErrGraphUnavailable, got %v", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("%d requests reached Graph while the breaker was open", n)
+	}
+	if metrics := graph.Metrics(); metrics.BreakerState != "open" || metrics.BreakerOpens != 1 {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+
+	// Sonde en échec après le refroidissement : le disjoncteur se rouvre
+	time.Sleep(250 * time.Millisecond)
+	graph.Get("/me")
+	if _, err := graph.Get("/me"); !errors.Is(err, ErrGraphUnavailable) {
+		t.Fatalf("breaker not reopened after a failed probe: %v", err)
+	}
+
+	// Sonde réussie : fermeture
+	healthy.Store(true)
+	time.Sleep(250 * time.Millisecond)
+	if _, err := graph.Get("/me"); err != nil {
+		t.Fatalf("half-open probe failed: %v", err)
+	}
+	if _, err := graph.Get("/me"); err != nil {
+		t.Fatalf("breaker not closed after a successful probe: %v", err)
+	}
+	if metrics := graph.Metrics(); metrics.BreakerState != "closed" || metrics.BreakerOpens != 2 {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	breaker := newCircuitBreaker(1, 10*time.Millisecond)
+	breaker.record(false)
+	time.Sleep(20 * time.Millisecond)
+
+	if err := breaker.allow(); err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	// Une seule requête de test à la fois
+	if err := breaker.allow(); !errors.Is(err, ErrGraphUnavailable) {
+		t.Fatalf("second concurrent probe allowed: %v", err)
+	}
+	// 429 : ni succès ni échec, la sonde est libérée
+	breaker.release()
+	if err := breaker.allow(); err != nil {
+		t.Fatalf("probe not released: %v", err)
+	}
+}
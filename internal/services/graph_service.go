@@ -2,114 +2,264 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 )
 
-const (
-	graphBaseURL     = "https://graph.microsoft.com/v1.0"
-	graphBetaBaseURL = "https://graph.microsoft.com/beta"
-)
+// Cloud public, si GraphOptions.Host est vide
+const defaultGraphHost = "https://graph.microsoft.com"
 
 type GraphService struct {
 	authService *AuthService
 	httpClient  *http.Client
+	baseURL     string // v1.0
+	betaURL     string
+	pageLimits  PageOptions
+	resilience  *graphResilience
+	tenantID    string // tenant ciblé, TENANT_ID si vide
+	userID      string // si défini, les appels utilisent le jeton délégué de cet utilisateur
 }
 
-func NewGraphService(authService *AuthService) *GraphService {
+func NewGraphService(authService *AuthService, opts GraphOptions) *GraphService {
+	host := strings.TrimSuffix(opts.Host, "/")
+	if host == "" {
+		host = defaultGraphHost
+	}
 	return &GraphService{
 		authService: authService,
 		httpClient:  &http.Client{},
+		baseURL:     host + "/v1.0",
+		betaURL:     host + "/beta",
+		pageLimits:  opts.PageLimits,
+		resilience:  newGraphResilience(opts),
 	}
 }
 
+// Metrics - Compteurs de retries et état du disjoncteur
+func (s *GraphService) Metrics() GraphMetrics {
+	return s.resilience.metrics()
+}
+
+// ForTenant - Copie du service qui appelle Graph dans le tenant donné
+func (s *GraphService) ForTenant(tenantID string) *GraphService {
+	clone := *s
+	clone.tenantID = tenantID
+	clone.userID = ""
+	return &clone
+}
+
+// AsUser - Copie du service qui appelle Graph au nom de l'utilisateur (jeton délégué)
+func (s *GraphService) AsUser(tenantID, userID string) *GraphService {
+	clone := *s
+	clone.tenantID = tenantID
+	clone.userID = userID
+	return &clone
+}
+
 // Method générique pour les requêtes Graph API
 
 func (s *GraphService) Get(endpoint string) (map[string]any, error) {
-	return s.request("GET", graphBaseURL+endpoint, nil)
+	return s.request("GET", s.baseURL+endpoint, nil)
 }
 
 func (s *GraphService) GetBeta(endpoint string) (map[string]any, error) {
-	return s.request("GET", graphBetaBaseURL+endpoint, nil)
+	return s.request("GET", s.betaURL+endpoint, nil)
 }
 
 func (s *GraphService) Post(endpoint string, body map[string]any) (map[string]any, error) {
-	return s.request("POST", graphBaseURL+endpoint, body)
+	return s.request("POST", s.baseURL+endpoint, body)
 }
 
 func (s *GraphService) PostBeta(endpoint string, body map[string]any) (map[string]any, error) {
-	return s.request("POST", graphBetaBaseURL+endpoint, body)
+	return s.request("POST", s.betaURL+endpoint, body)
 }
 
 func (s *GraphService) Delete(endpoint string) error {
-	_, err := s.request("DELETE", graphBaseURL+endpoint, nil)
+	_, err := s.request("DELETE", s.baseURL+endpoint, nil)
 	return err
 }
 
 func (s *GraphService) Patch(endpoint string, body map[string]any) (map[string]any, error) {
-	return s.request("PATCH", graphBaseURL+endpoint, body)
+	return s.request("PATCH", s.baseURL+endpoint, body)
+}
+
+// GraphResponse - Réponse complète : statut et en-têtes (Location d'une opération 202...)
+type GraphResponse struct {
+	Status  int
+	Headers http.Header
+	Body    map[string]any // nil si la réponse est vide
+}
+
+// Do - Requête dont l'appelant a besoin du statut ou des en-têtes
+func (s *GraphService) Do(method, endpoint string, body map[string]any) (*GraphResponse, error) {
+	return s.do(method, s.baseURL+endpoint, body)
+}
+
+func (s *GraphService) DoBeta(method, endpoint string, body map[string]any) (*GraphResponse, error) {
+	return s.do(method, s.betaURL+endpoint, body)
 }
 
 func (s *GraphService) request(method, url string, body map[string]any) (map[string]any, error) {
-	token, err := s.authService.GetAccessToken()
+	resp, err := s.do(method, url, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get access token: %w", err)
+		return nil, err
 	}
+	return resp.Body, nil
+}
 
+func (s *GraphService) do(method, url string, body map[string]any) (*GraphResponse, error) {
 	log.Printf("=== GRAPH API REQUEST ===")
 	log.Printf("Method: %s", method)
 	log.Printf("URL: %s", url)
 
-	var reqBody io.Reader
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
-		if err != nil {
+		var err error
+		if jsonBody, err = json.Marshal(body); err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		reqBody = bytes.NewBuffer(jsonBody)
-	}
-
-	req, err := http.NewRequest(method, url, reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("ConsistencyLevel", "eventual")
-
-	resp, err := s.httpClient.Do(req)
+	resp, bodyBytes, err := s.send(method, url, jsonBody, nil)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
 	log.Printf("=== GRAPH API RESPONSE ===")
 	log.Printf("Status: %d", resp.StatusCode)
 
-	bodyBytes, _ := io.ReadAll(resp.Body)
-
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(bodyBytes))
+		graphErr := newGraphError(resp.StatusCode, flattenHeaders(resp.Header), bodyBytes)
+		log.Printf("❌ %v", graphErr)
+		return nil, graphErr
 	}
 
-	// Gérer les réponses sans contenu
-	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusAccepted {
-		return nil, nil
-	}
+	result := &GraphResponse{Status: resp.StatusCode, Headers: resp.Header}
 
-	// Si le body est vide, retourner nil sans erreur
-	if len(bodyBytes) == 0 {
-		return nil, nil
+	// Réponses sans contenu (202, 204) ou body vide : pas de JSON à décoder
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusAccepted || len(bodyBytes) == 0 {
+		return result, nil
 	}
 
-	var result map[string]any
-	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+	if err := json.Unmarshal(bodyBytes, &result.Body); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	return result, nil
 }
+
+// send - Exécute la requête avec timeout, retries (429/503/504, Retry-After) et
+// disjoncteur ; le corps de la réponse est lu entièrement
+func (s *GraphService) send(method, url string, payload []byte, headers map[string]string) (*http.Response, []byte, error) {
+	if headerValue(headers, "client-request-id") == "" {
+		headers = withHeader(headers, "client-request-id", newClientRequestID())
+	}
+	return s.exchange(method, url, true, func(token string) (*http.Response, []byte, error) {
+		return s.attempt(method, url, payload, headers, token)
+	})
+}
+
+// exchange - Boucle commune aux appels Graph : disjoncteur, jeton et retries.
+// Sans authenticated, aucun jeton n'est envoyé (URL pré-authentifiée).
+func (s *GraphService) exchange(method, url string, authenticated bool, try func(token string) (*http.Response, []byte, error)) (*http.Response, []byte, error) {
+	r := s.resilience
+
+	for attempt := 0; ; attempt++ {
+		if err := r.breaker.allow(); err != nil {
+			return nil, nil, err
+		}
+
+		var token string
+		if authenticated {
+			var err error
+			if token, err = s.getToken(); err != nil {
+				r.breaker.release()
+				return nil, nil, fmt.Errorf("failed to get access token: %w", err)
+			}
+		}
+
+		resp, bodyBytes, err := try(token)
+		r.requests.Add(1)
+
+		switch {
+		case err != nil || resp.StatusCode >= 500:
+			r.failures.Add(1)
+			r.breaker.record(false)
+		case resp.StatusCode == http.StatusTooManyRequests:
+			r.breaker.release()
+		default:
+			r.breaker.record(true)
+		}
+
+		delay, retry := r.retryDelay(method, resp, err, attempt)
+		if !retry {
+			if err != nil {
+				return nil, nil, fmt.Errorf("request failed: %w", err)
+			}
+			return resp, bodyBytes, nil
+		}
+
+		r.retries.Add(1)
+		if err != nil {
+			log.Printf("⚠️ Graph %s %s failed (%v), retry %d in %s", method, url, err, attempt+1, delay)
+		} else {
+			// Réponse en streaming non consommée
+			resp.Body.Close()
+			log.Printf("⚠️ Graph %s %s returned %d, retry %d in %s", method, url, resp.StatusCode, attempt+1, delay)
+		}
+		time.Sleep(delay)
+	}
+}
+
+func (s *GraphService) attempt(method, url string, payload []byte, headers map[string]string, token string) (*http.Response, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.resilience.timeout)
+	defer cancel()
+
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	setGraphHeaders(req, headers, token)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, bodyBytes, nil
+}
+
+func (s *GraphService) getToken() (string, error) {
+	if s.userID != "" {
+		return s.authService.GetUserAccessToken(s.tenantID, s.userID)
+	}
+	return s.authService.GetTenantAccessToken(s.tenantID)
+}
+
+func setGraphHeaders(req *http.Request, headers map[string]string, token string) {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("ConsistencyLevel", "eventual")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	geminiCountTokensURL = "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash:countTokens"
+	// Longueur maximale d'un résultat d'outil transmis au résumé
+	summaryToolChars = 500
+
+	historyCompactJob = "history.compact"
+)
+
+// HistoryOptions - Budget de tokens de l'historique rejoué au modèle
+type HistoryOptions struct {
+	TokenBudget int    // 0 = pas de compaction par tokens
+	KeepRecent  int    // messages récents jamais résumés
+	Counter     string // local (estimation) ou gemini (endpoint countTokens)
+}
+
+// withSummary - Le résumé des anciens tours complète le contexte système
+func withSummary(systemContext, summary string) string {
+	if summary == "" {
+		return systemContext
+	}
+	return systemContext + "\n\nRÉSUMÉ DES ÉCHANGES PRÉCÉDENTS (conserve les IDs et décisions mentionnés):\n" + summary
+}
+
+type historyCompactPayload struct {
+	ConversationID string `json:"conversation_id"`
+	SystemContext  string `json:"system_context,omitempty"`
+}
+
+// RegisterJobs - La compaction passe par la file de jobs : elle survit à un redémarrage
+func (s *GeminiService) RegisterJobs(jobs *JobQueue) {
+	s.jobs = jobs
+	jobs.Register(historyCompactJob, func(job *Job) error {
+		var payload historyCompactPayload
+		if err := job.Decode(&payload); err != nil {
+			return err
+		}
+		s.compactHistory(payload.ConversationID, payload.SystemContext)
+		return nil
+	})
+}
+
+// scheduleCompaction - Un seul job en attente par conversation
+func (s *GeminiService) scheduleCompaction(conversationID, systemContext string) {
+	if s.history.TokenBudget <= 0 {
+		return
+	}
+	if s.jobs == nil {
+		go s.compactHistory(conversationID, systemContext)
+		return
+	}
+	payload := historyCompactPayload{ConversationID: conversationID, SystemContext: systemContext}
+	if _, err := s.jobs.Enqueue(historyCompactJob, payload, EnqueueOptions{Key: conversationID, MaxAttempts: 1}); err != nil {
+		log.Printf("[History] Compaction de %s non planifiée: %v", conversationID, err)
+	}
+}
+
+// compactHistory - Si l'historique dépasse le budget, les tours les plus anciens
+// sont fondus dans le résumé glissant ; les tours récents restent intacts
+func (s *GeminiService) compactHistory(conversationID, systemContext string) {
+	if s.history.TokenBudget <= 0 {
+		return
+	}
+	if _, busy := s.compacting.LoadOrStore(conversationID, true); busy {
+		return
+	}
+	defer s.compacting.Delete(conversationID)
+
+	summary := s.conversationStore.Summary(conversationID)
+	contents := s.conversationStore.ReplayContents(conversationID)
+	tokens := s.countTokens(contents, withSummary(systemContext, summary))
+	if tokens <= s.history.TokenBudget {
+		return
+	}
+
+	history := s.conversationStore.GetHistory(conversationID)
+	cut := summaryCut(history, s.history.KeepRecent)
+	if cut == 0 {
+		log.Printf("[History] %s dépasse le budget (%d tokens) mais ne contient que des tours récents", conversationID, tokens)
+		return
+	}
+
+	updated, err := s.summarize(summary, history[:cut])
+	if err != nil {
+		log.Printf("⚠️ [History] Résumé de %s impossible: %v", conversationID, err)
+		return
+	}
+	s.conversationStore.Summarize(conversationID, updated, history[cut-1].Time)
+	log.Printf("[History] %s: %d tokens > %d, %d messages résumés", conversationID, tokens, s.history.TokenBudget, cut)
+}
+
+// summaryCut - Index de coupe : les messages récents commencent toujours par un
+// message utilisateur, pour ne pas séparer un appel de fonction de sa réponse
+func summaryCut(history []ConversationMessage, keepRecent int) int {
+	cut := len(history) - keepRecent
+	if cut <= 0 {
+		return 0
+	}
+	for cut > 0 && (history[cut].Role != "user" || len(history[cut].Parts) > 0) {
+		cut--
+	}
+	return cut
+}
+
+func (s *GeminiService) countTokens(contents []GeminiContent, systemContext string) int {
+	if s.history.Counter == "gemini" {
+		tokens, err := s.countTokensRemote(contents, systemContext)
+		if err == nil {
+			return tokens
+		}
+		log.Printf("⚠️ [History] countTokens indisponible, estimation locale: %v", err)
+	}
+	return estimateTokens(contents, systemContext)
+}
+
+// estimateTokens - Approximation d'environ 4 caractères par token
+func estimateTokens(contents []GeminiContent, systemContext string) int {
+	chars := utf8.RuneCountInString(systemContext)
+	for _, content := range contents {
+		for _, part := range content.Parts {
+			chars += utf8.RuneCountInString(part.Text)
+			if part.FunctionCall != nil {
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				chars += len(part.FunctionCall.Name) + len(args)
+			}
+			if part.FunctionResponse != nil {
+				response, _ := json.Marshal(part.FunctionResponse.Response)
+				chars += len(part.FunctionResponse.Name) + utf8.RuneCount(response)
+			}
+		}
+	}
+	return chars/4 + 4*len(contents)
+}
+
+func (s *GeminiService) countTokensRemote(contents []GeminiContent, systemContext string) (int, error) {
+	payload := map[string]any{
+		"generateContentRequest": map[string]any{
+			"model":    "models/gemini-2.5-flash",
+			"contents": contents,
+			"systemInstruction": GeminiSystemInstruc{
+				Parts: []GeminiPart{{Text: systemContext}},
+			},
+		},
+	}
+
+	var result struct {
+		TotalTokens int          `json:"totalTokens"`
+		Error       *GeminiError `json:"error,omitempty"`
+	}
+	if err := s.postGemini(geminiCountTokensURL, payload, &result); err != nil {
+		return 0, err
+	}
+	if result.Error != nil {
+		return 0, fmt.Errorf("Gemini error %d: %s", result.Error.Code, result.Error.Message)
+	}
+	return result.TotalTokens, nil
+}
+
+// summarize - Fusionne le résumé existant et les anciens messages en un nouveau résumé
+func (s *GeminiService) summarize(previous string, messages []ConversationMessage) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("Résumé existant:\n" + previous + "\n\nSuite de la conversation:\n")
+	}
+	for _, msg := range messages {
+		transcript.WriteString(transcriptLine(msg))
+		transcript.WriteString("\n")
+	}
+
+	reqBody := GeminiRequest{
+		Contents: []GeminiContent{{
+			Role:  "user",
+			Parts: []GeminiPart{{Text: transcript.String()}},
+		}},
+		SystemInstruction: &GeminiSystemInstruc{
+			Parts: []GeminiPart{{Text: `Tu résumes une conversation entre un utilisateur et NEO, assistant Microsoft 365.
+Produis un résumé factuel et concis en français, sous forme de puces.
+Conserve impérativement les identifiants (IDs Graph, emails, noms d'équipes, de canaux, dates et heures), les demandes en cours et les décisions prises.`}},
+		},
+		GenerationConfig: &GeminiGenerationConfig{
+			MaxOutputTokens: 1024,
+			Temperature:     0.2,
+			ThinkingConfig: &ThinkingConfig{
+				ThinkingBudget: 0,
+			},
+		},
+	}
+
+	var result GeminiResponse
+	if err := s.postGemini(geminiBaseURL, reqBody, &result); err != nil {
+		return "", err
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("Gemini error %d: %s", result.Error.Code, result.Error.Message)
+	}
+	if len(result.Candidates) == 0 {
+		return "", fmt.Errorf("empty response from Gemini")
+	}
+
+	var text []string
+	for _, part := range result.Candidates[0].Content.Parts {
+		if !part.Thought && part.Text != "" {
+			text = append(text, part.Text)
+		}
+	}
+	if len(text) == 0 {
+		return "", fmt.Errorf("empty summary from Gemini")
+	}
+	return strings.Join(text, "\n"), nil
+}
+
+// transcriptLine - Rendu texte d'un message pour le résumé
+func transcriptLine(msg ConversationMessage) string {
+	stamp := msg.Time.Format(time.DateTime)
+	if len(msg.Parts) == 0 {
+		speaker := "Utilisateur"
+		if msg.Role == "assistant" {
+			speaker = "NEO"
+		}
+		return fmt.Sprintf("[%s] %s: %s", stamp, speaker, msg.Content)
+	}
+
+	var lines []string
+	for _, part := range msg.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			args, _ := json.Marshal(part.FunctionCall.Args)
+			lines = append(lines, fmt.Sprintf("[%s] NEO appelle %s(%s)", stamp, part.FunctionCall.Name, args))
+		case part.FunctionResponse != nil:
+			result := fmt.Sprint(part.FunctionResponse.Response["result"])
+			if runes := []rune(result); len(runes) > summaryToolChars {
+				result = string(runes[:summaryToolChars]) + "…"
+			}
+			lines = append(lines, fmt.Sprintf("[%s] Résultat %s: %s", stamp, part.FunctionResponse.Name, result))
+		case part.Text != "":
+			lines = append(lines, fmt.Sprintf("[%s] NEO: %s", stamp, part.Text))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *GeminiService) postGemini(endpoint string, payload any, out any) error {
+	jsonBody, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s?key=%s", endpoint, s.apiKey), bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Gemini API error %d: %s", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"microsoft_connector/internal/storage"
+)
+
+const (
+	idempotencyKeyPrefix = "idempotency/"
+	activityProcessing   = "processing"
+	activityDone         = "done"
+)
+
+// IdempotencyOptions - Durées de conservation ; 0 = valeur par défaut
+type IdempotencyOptions struct {
+	TTL   time.Duration // mémoire des activités traitées et des actions effectuées
+	Lease time.Duration // au-delà, une activité restée "processing" (crash) peut être rejouée
+}
+
+// IdempotencyStore - Déduplication des activités relivrées par Teams et des
+// outils à effet de bord, dans le stockage partagé
+type IdempotencyStore struct {
+	store storage.Store
+	ttl   time.Duration
+	lease time.Duration
+}
+
+func NewIdempotencyStore(store storage.Store, opts IdempotencyOptions) *IdempotencyStore {
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = 5 * time.Minute
+	}
+	return &IdempotencyStore{store: store, ttl: opts.TTL, lease: opts.Lease}
+}
+
+// ActivityKey - Clé d'une activité : canal, conversation et ID d'activité
+func ActivityKey(channelID, conversationID, activityID string) string {
+	if activityID == "" {
+		return ""
+	}
+	return channelID + "/" + conversationID + "/" + activityID
+}
+
+// Claim - false si l'activité est déjà traitée ou en cours de traitement.
+// En cas d'erreur de stockage, l'activité est traitée (mieux vaut un doublon qu'une perte)
+func (s *IdempotencyStore) Claim(activityKey string) bool {
+	if s == nil || activityKey == "" {
+		return true
+	}
+	claimed, err := s.store.SetIfAbsent(idempotencyKeyPrefix+"activities/"+activityKey, []byte(activityProcessing), s.lease)
+	if err != nil {
+		log.Printf("⚠️ [Idempotency] Vérification de %s impossible: %v", activityKey, err)
+		return true
+	}
+	return claimed
+}
+
+// Complete - L'activité est traitée : les relivraisons sont ignorées jusqu'au TTL
+func (s *IdempotencyStore) Complete(activityKey string) {
+	if s == nil || activityKey == "" {
+		return
+	}
+	if err := s.store.Set(idempotencyKeyPrefix+"activities/"+activityKey, []byte(activityDone), s.ttl); err != nil {
+		log.Printf("⚠️ [Idempotency] Enregistrement de %s impossible: %v", activityKey, err)
+	}
+}
+
+// Release - Abandonne la réservation (activité refusée) : une relivraison sera traitée
+func (s *IdempotencyStore) Release(activityKey string) {
+	if s == nil || activityKey == "" {
+		return
+	}
+	if err := s.store.Delete(idempotencyKeyPrefix + "activities/" + activityKey); err != nil {
+		log.Printf("⚠️ [Idempotency] Libération de %s impossible: %v", activityKey, err)
+	}
+}
+
+// Once - Exécute une action à effet de bord une seule fois par activité et par
+// arguments ; une relivraison retourne le résultat enregistré. Seuls les succès
+// (err nil) sont enregistrés : un échec peut être retenté
+func (s *IdempotencyStore) Once(activityKey, toolName string, args []byte, run func() (string, error)) (string, error) {
+	if s == nil || activityKey == "" {
+		return run()
+	}
+
+	digest := sha256.Sum256(args)
+	key := idempotencyKeyPrefix + "tools/" + activityKey + "/" + toolName + "/" + hex.EncodeToString(digest[:8])
+
+	previous, err := s.store.Get(key)
+	if err == nil {
+		log.Printf("[Idempotency] %s déjà exécuté pour %s, résultat réutilisé", toolName, activityKey)
+		return "Action déjà effectuée pour ce message, elle n'a pas été répétée. Résultat : " + string(previous), nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		log.Printf("⚠️ [Idempotency] Lecture de %s impossible: %v", key, err)
+	}
+
+	result, err := run()
+	if err != nil {
+		return "", err
+	}
+	if err := s.store.Set(key, []byte(result), s.ttl); err != nil {
+		log.Printf("⚠️ [Idempotency] Enregistrement de %s impossible: %v", key, err)
+	}
+	return result, nil
+}
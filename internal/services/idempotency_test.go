@@ -0,0 +1,79 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"microsoft_connector/internal/storage"
+)
+
+func TestIdempotencyOnceCachesOnlySuccesses(t *testing.T) {
+	store := NewIdempotencyStore(storage.NewMemoryStore(), IdempotencyOptions{})
+	args := []byte(`{"to":"a@b.c"}`)
+
+	calls := 0
+	failing := func() (string, error) {
+		calls++
+		return "", errors.New("boom")
+	}
+	succeeding := func() (string, error) {
+		calls++
+		return "Email envoyé", nil
+	}
+
+	// Échec : rien n'est mémorisé, l'action peut être retentée
+	if _, err := store.Once("msteams/conv/1", "send_email", args, failing); err == nil {
+		t.Fatal("failure swallowed")
+	}
+	if result, err := store.Once("msteams/conv/1", "send_email", args, succeeding); err != nil || result != "Email envoyé" {
+		t.Fatalf("retry after failure = %q, %v", result, err)
+	}
+	result, err := store.Once("msteams/conv/1", "send_email", args, succeeding)
+	if err != nil || !strings.Contains(result, "n'a pas été répétée") || !strings.HasSuffix(result, "Email envoyé") {
+		t.Fatalf("replay = %q, %v", result, err)
+	}
+	if calls != 2 {
+		t.Fatalf("%d executions, want 2", calls)
+	}
+
+	// Autres arguments : nouvelle action
+	store.Once("msteams/conv/1", "send_email", []byte(`{"to":"x@y.z"}`), succeeding)
+	if calls != 3 {
+		t.Fatalf("%d executions, want 3", calls)
+	}
+}
+
+func TestToolExecutorRetriesFailedSideEffects(t *testing.T) {
+	var requests atomic.Int32
+	graph := newTestGraphService(t, GraphOptions{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Premier essai refusé par Graph (texte d'erreur sans préfixe "Erreur")
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": "Forbidden", "message": "Missing role"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"id": "channel-1"})
+	}))
+
+	caller := &CallerIdentity{UserID: "user-1", TenantID: "tenant-1", ActivityKey: "msteams/conv/1"}
+	idempotency := NewIdempotencyStore(storage.NewMemoryStore(), IdempotencyOptions{})
+	executor := NewToolExecutor(caller, NewDelegationPolicy(""), nil, nil, nil, idempotency, nil)
+	input := json.RawMessage(`{"team_id":"team-1","display_name":"Projets"}`)
+
+	if result := executor.Execute("create_channel", input, graph); !strings.Contains(result, "403") {
+		t.Fatalf("first attempt = %q", result)
+	}
+	if result := executor.Execute("create_channel", input, graph); result != "Canal 'Projets' créé avec succès" {
+		t.Fatalf("retry = %q", result)
+	}
+	if result := executor.Execute("create_channel", input, graph); !strings.Contains(result, "n'a pas été répétée") {
+		t.Fatalf("replay = %q", result)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("%d Graph requests, want 2", n)
+	}
+}
@@ -0,0 +1,517 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"microsoft_connector/internal/storage"
+)
+
+const (
+	jobKeyPrefix     = "jobs/"
+	jobLockKeyPrefix = "job-locks/"
+
+	jobRetryBaseDelay = 10 * time.Second
+	jobRetryMaxDelay  = time.Hour
+)
+
+// États d'un job
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead" // tentatives épuisées, conservé pour inspection
+)
+
+// Job - Travail persisté, exécuté par un worker du processus
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Key         string          `json:"key,omitempty"` // déduplication des jobs en attente
+	Payload     json.RawMessage `json:"payload"`
+	State       string          `json:"state"`
+	Attempts    int             `json:"attempts"` // échecs successifs
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	LockedUntil time.Time       `json:"locked_until,omitzero"`
+}
+
+// Decode - Lit la charge utile du job
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// LastAttempt - Un échec de cette exécution enverra le job en dead-letter
+func (j *Job) LastAttempt() bool {
+	return j.Attempts+1 >= j.MaxAttempts
+}
+
+// JobHandler - Exécute un job ; une erreur déclenche un nouvel essai avec backoff
+type JobHandler func(job *Job) error
+
+// jobReschedule - Le job n'a pas échoué mais doit être repris plus tard (attente d'une opération)
+type jobReschedule struct {
+	after time.Duration
+}
+
+func (r *jobReschedule) Error() string {
+	return fmt.Sprintf("rescheduled in %s", r.after)
+}
+
+// RescheduleJob - À retourner par un handler pour reprendre le job après un délai,
+// sans compter d'échec
+func RescheduleJob(after time.Duration) error {
+	return &jobReschedule{after: after}
+}
+
+// JobOptions - Paramètres des workers ; 0 = valeur par défaut
+type JobOptions struct {
+	Workers      int
+	PollInterval time.Duration
+	MaxAttempts  int
+	Lease        time.Duration // au-delà, un job "running" est considéré abandonné (crash)
+	Retention    time.Duration // conservation des jobs terminés
+}
+
+// EnqueueOptions - Planification d'un job
+type EnqueueOptions struct {
+	RunAt       time.Time // zéro : dès que possible
+	MaxAttempts int
+	Key         string // si un job de même clé est en attente, il est réutilisé
+}
+
+// JobQueue - File de jobs durable dans le stockage partagé (fichiers ou SQLite),
+// avec retries, planification et dead-letter
+type JobQueue struct {
+	store        storage.Store
+	workers      int
+	pollInterval time.Duration
+	maxAttempts  int
+	lease        time.Duration
+	retention    time.Duration
+
+	mu       sync.Mutex
+	handlers map[string]JobHandler
+	running  map[string]bool
+
+	slots   chan struct{}
+	wake    chan struct{}
+	stop    chan struct{}
+	started bool
+	loopWG  sync.WaitGroup
+	jobWG   sync.WaitGroup
+}
+
+func NewJobQueue(store storage.Store, opts JobOptions) *JobQueue {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = 10 * time.Minute
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = 7 * 24 * time.Hour
+	}
+	return &JobQueue{
+		store:        store,
+		workers:      opts.Workers,
+		pollInterval: opts.PollInterval,
+		maxAttempts:  opts.MaxAttempts,
+		lease:        opts.Lease,
+		retention:    opts.Retention,
+		handlers:     make(map[string]JobHandler),
+		running:      make(map[string]bool),
+		slots:        make(chan struct{}, opts.Workers),
+		wake:         make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+	}
+}
+
+// Register - Associe un type de job à son handler (avant Start)
+func (q *JobQueue) Register(jobType string, handler JobHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = handler
+}
+
+// Enqueue - Persiste un job ; il sera exécuté à RunAt par un worker
+func (q *JobQueue) Enqueue(jobType string, payload any, opts EnqueueOptions) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:          newClientRequestID(),
+		Type:        jobType,
+		Key:         opts.Key,
+		Payload:     data,
+		State:       JobPending,
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       opts.RunAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = q.maxAttempts
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if opts.Key != "" {
+		digest := sha256.Sum256([]byte(jobType + "/" + opts.Key))
+		job.ID = "k-" + hex.EncodeToString(digest[:12])
+		if existing, err := q.load(job.ID); err == nil && (existing.State == JobPending || existing.State == JobRunning) {
+			return existing, nil
+		}
+	}
+
+	if err := q.save(job); err != nil {
+		return nil, err
+	}
+	log.Printf("[Jobs] %s %s planifié pour %s", job.Type, job.ID, job.RunAt.Format(time.RFC3339))
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Start - Lance la boucle de planification
+func (q *JobQueue) Start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started {
+		return
+	}
+	q.started = true
+
+	q.loopWG.Add(1)
+	go q.loop()
+}
+
+// Shutdown - Arrête la planification et attend les jobs en cours ; un job
+// interrompu reste "running" et sera repris à l'expiration de son bail
+func (q *JobQueue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.started {
+		q.mu.Unlock()
+		return nil
+	}
+	q.started = false
+	q.mu.Unlock()
+
+	close(q.stop)
+	q.loopWG.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		q.jobWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// List - Jobs, filtrés par état et/ou type ("" = tous), du plus récent au plus ancien
+func (q *JobQueue) List(state, jobType string) ([]Job, error) {
+	keys, err := q.store.Keys(jobKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []Job{}
+	for _, key := range keys {
+		job, err := q.load(key[len(jobKeyPrefix):])
+		if err != nil {
+			continue
+		}
+		if (state == "" || job.State == state) && (jobType == "" || job.Type == jobType) {
+			jobs = append(jobs, *job)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b Job) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return jobs, nil
+}
+
+// Get - Job par ID
+func (q *JobQueue) Get(id string) (*Job, error) {
+	return q.load(id)
+}
+
+// Retry - Remet en file un job en dead-letter, avec un nouveau compteur de tentatives
+func (q *JobQueue) Retry(id string) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, err := q.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.State != JobDead {
+		return nil, fmt.Errorf("job %s is %s, only dead jobs can be retried", id, job.State)
+	}
+	job.State = JobPending
+	job.Attempts = 0
+	job.RunAt = time.Now()
+	job.UpdatedAt = time.Now()
+	if err := q.save(job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Delete - Supprime un job (annulation d'un job en attente ou nettoyage)
+func (q *JobQueue) Delete(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, err := q.load(id); err != nil {
+		return err
+	}
+	return q.store.Delete(jobKeyPrefix + id)
+}
+
+func (q *JobQueue) loop() {
+	defer q.loopWG.Done()
+
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.dispatchDue()
+		select {
+		case <-ticker.C:
+		case <-q.wake:
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// dispatchDue - Réserve et lance les jobs dus, dans la limite des workers libres.
+// Coût : chaque passage liste toutes les clés jobs/ et relit chaque job, y compris
+// les jobs terminés gardés pendant la rétention, soit O(n) lectures par
+// PollInterval et par instance. Suffisant pour quelques milliers de jobs ; au-delà,
+// il faudra un index par RunAt (sorted set Redis, index SQLite) plutôt qu'un scan
+func (q *JobQueue) dispatchDue() {
+	keys, err := q.store.Keys(jobKeyPrefix)
+	if err != nil {
+		log.Printf("[Jobs] Lecture de la file impossible: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		id := key[len(jobKeyPrefix):]
+
+		q.mu.Lock()
+		busy := q.running[id]
+		q.mu.Unlock()
+		if busy {
+			continue
+		}
+
+		job, err := q.load(id)
+		if err != nil || !q.due(job, now) {
+			continue
+		}
+
+		select {
+		case q.slots <- struct{}{}:
+		default:
+			return // tous les workers sont occupés
+		}
+
+		job, ok := q.claim(id)
+		if !ok {
+			<-q.slots
+			continue
+		}
+
+		q.jobWG.Add(1)
+		go func() {
+			defer q.jobWG.Done()
+			defer func() { <-q.slots }()
+			q.run(job)
+		}()
+	}
+}
+
+func (q *JobQueue) due(job *Job, now time.Time) bool {
+	switch job.State {
+	case JobPending:
+		return !job.RunAt.After(now)
+	case JobRunning:
+		// Worker disparu (redémarrage) : le job est repris
+		return now.After(job.LockedUntil)
+	}
+	return false
+}
+
+// claim - Le verrou de stockage protège aussi contre les autres instances
+func (q *JobQueue) claim(id string) (*Job, bool) {
+	locked, err := q.store.SetIfAbsent(jobLockKeyPrefix+id, []byte(time.Now().Format(time.RFC3339)), q.lease)
+	if err != nil || !locked {
+		return nil, false
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, err := q.load(id)
+	if err != nil || !q.due(job, time.Now()) {
+		q.store.Delete(jobLockKeyPrefix + id)
+		return nil, false
+	}
+	if job.State == JobRunning {
+		log.Printf("[Jobs] %s %s abandonné par un worker, repris", job.Type, job.ID)
+	}
+	job.State = JobRunning
+	job.LockedUntil = time.Now().Add(q.lease)
+	job.UpdatedAt = time.Now()
+	if err := q.save(job); err != nil {
+		q.store.Delete(jobLockKeyPrefix + id)
+		return nil, false
+	}
+	q.running[id] = true
+	return job, true
+}
+
+func (q *JobQueue) run(job *Job) {
+	q.mu.Lock()
+	handler, ok := q.handlers[job.Type]
+	q.mu.Unlock()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no handler registered for job type %q", job.Type)
+	} else {
+		err = q.invoke(handler, job)
+	}
+	q.finish(job, err)
+}
+
+// invoke - Une panique est traitée comme un échec du job
+func (q *JobQueue) invoke(handler JobHandler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ [Jobs] Panique dans %s %s: %v\n%s", job.Type, job.ID, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(job)
+}
+
+func (q *JobQueue) finish(job *Job, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer func() {
+		delete(q.running, job.ID)
+		q.store.Delete(jobLockKeyPrefix + job.ID)
+	}()
+
+	// Supprimé pendant l'exécution (annulation) : rien à enregistrer
+	if _, loadErr := q.load(job.ID); errors.Is(loadErr, storage.ErrNotFound) {
+		return
+	}
+
+	now := time.Now()
+	job.UpdatedAt = now
+	job.LockedUntil = time.Time{}
+
+	var reschedule *jobReschedule
+	switch {
+	case err == nil:
+		job.State = JobSucceeded
+		job.LastError = ""
+	case errors.As(err, &reschedule):
+		job.State = JobPending
+		job.RunAt = now.Add(reschedule.after)
+	default:
+		job.Attempts++
+		job.LastError = err.Error()
+		if job.Attempts >= job.MaxAttempts {
+			job.State = JobDead
+			log.Printf("❌ [Jobs] %s %s en dead-letter après %d tentatives: %v", job.Type, job.ID, job.Attempts, err)
+		} else {
+			job.State = JobPending
+			job.RunAt = now.Add(jobBackoff(job.Attempts))
+			log.Printf("⚠️ [Jobs] %s %s échoué (%d/%d), nouvel essai à %s: %v", job.Type, job.ID, job.Attempts, job.MaxAttempts, job.RunAt.Format(time.RFC3339), err)
+		}
+	}
+
+	if err := q.save(job); err != nil {
+		log.Printf("[Jobs] Enregistrement de %s impossible: %v", job.ID, err)
+	}
+}
+
+func (q *JobQueue) load(id string) (*Job, error) {
+	data, err := q.store.Get(jobKeyPrefix + id)
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("corrupted job %s: %w", id, err)
+	}
+	return &job, nil
+}
+
+// save - Les jobs terminés expirent après la rétention ; les autres sont permanents
+func (q *JobQueue) save(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+	var ttl time.Duration
+	if job.State == JobSucceeded || job.State == JobDead {
+		ttl = q.retention
+	}
+	return q.store.Set(jobKeyPrefix+job.ID, data, ttl)
+}
+
+// jobBackoff - Exponentiel avec jitter, de 10 s à 1 h
+func jobBackoff(failures int) time.Duration {
+	delay := jobRetryBaseDelay << (failures - 1)
+	if delay > jobRetryMaxDelay || delay <= 0 {
+		delay = jobRetryMaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int64N(int64(delay/2)+1))
+}
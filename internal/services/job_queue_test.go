@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"microsoft_connector/internal/storage"
+)
+
+// runDue - Un passage de planification, jobs lancés terminés
+func runDue(q *JobQueue) {
+	q.dispatchDue()
+	q.jobWG.Wait()
+}
+
+// rewind - Rend le job dû tout de suite, sans attendre le backoff
+func rewind(t *testing.T, q *JobQueue, id string) {
+	t.Helper()
+	job, err := q.load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.RunAt = time.Now().Add(-time.Millisecond)
+	if err := q.save(job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJobRescheduleIsNotAnAttempt(t *testing.T) {
+	q := NewJobQueue(storage.NewMemoryStore(), JobOptions{})
+	var calls int
+	q.Register("wait", func(job *Job) error {
+		calls++
+		if calls < 4 {
+			return RescheduleJob(time.Hour)
+		}
+		return nil
+	})
+
+	job, err := q.Enqueue("wait", nil, EnqueueOptions{MaxAttempts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		runDue(q)
+		got, _ := q.Get(job.ID)
+		if got.State != JobPending || got.Attempts != 0 || got.RunAt.Before(time.Now().Add(50*time.Minute)) {
+			t.Fatalf("after reschedule %d: %+v", i, got)
+		}
+		// Pas encore dû : rien ne tourne
+		runDue(q)
+		if calls != i {
+			t.Fatalf("rescheduled job ran early (%d calls)", calls)
+		}
+		rewind(t, q, job.ID)
+	}
+
+	runDue(q)
+	if got, _ := q.Get(job.ID); got.State != JobSucceeded || got.Attempts != 0 {
+		t.Fatalf("final job: %+v", got)
+	}
+}
+
+func TestJobDeadLetterAfterMaxAttempts(t *testing.T) {
+	q := NewJobQueue(storage.NewMemoryStore(), JobOptions{MaxAttempts: 3})
+	var calls int
+	q.Register("flaky", func(job *Job) error {
+		calls++
+		if calls == 2 {
+			panic("boom")
+		}
+		return fmt.Errorf("failure %d", calls)
+	})
+
+	job, _ := q.Enqueue("flaky", nil, EnqueueOptions{})
+	if job.MaxAttempts != 3 {
+		t.Fatalf("MaxAttempts = %d", job.MaxAttempts)
+	}
+	for attempt := 1; attempt <= 3; attempt++ {
+		runDue(q)
+		got, _ := q.Get(job.ID)
+		want := JobPending
+		if attempt == 3 {
+			want = JobDead
+		}
+		if got.State != want || got.Attempts != attempt || got.LastError == "" {
+			t.Fatalf("attempt %d: %+v", attempt, got)
+		}
+		if want == JobPending {
+			if !got.RunAt.After(time.Now()) {
+				t.Fatalf("attempt %d: no backoff", attempt)
+			}
+			rewind(t, q, job.ID)
+		}
+	}
+
+	runDue(q)
+	if calls != 3 {
+		t.Fatalf("dead job ran again (%d calls)", calls)
+	}
+	if dead, _ := q.List(JobDead, "flaky"); len(dead) != 1 || dead[0].LastError != "failure 3" {
+		t.Fatalf("dead-letter = %+v", dead)
+	}
+
+	retried, err := q.Retry(job.ID)
+	if err != nil || retried.State != JobPending || retried.Attempts != 0 {
+		t.Fatalf("Retry = %+v, %v", retried, err)
+	}
+	if _, err := q.Retry(job.ID); err == nil {
+		t.Fatal("pending job retried")
+	}
+}
+
+func TestJobKeyDedup(t *testing.T) {
+	q := NewJobQueue(storage.NewMemoryStore(), JobOptions{})
+	q.Register("sync", func(job *Job) error { return nil })
+
+	first, _ := q.Enqueue("sync", map[string]int{"n": 1}, EnqueueOptions{Key: "user-1"})
+	second, _ := q.Enqueue("sync", map[string]int{"n": 2}, EnqueueOptions{Key: "user-1"})
+	if second.ID != first.ID || string(second.Payload) != `{"n":1}` {
+		t.Fatalf("pending job not reused: %+v", second)
+	}
+	other, _ := q.Enqueue("sync", nil, EnqueueOptions{Key: "user-2"})
+	otherType, _ := q.Enqueue("export", nil, EnqueueOptions{Key: "user-1"})
+	if other.ID == first.ID || otherType.ID == first.ID {
+		t.Fatal("distinct keys or types share a job")
+	}
+	if pending, _ := q.List(JobPending, "sync"); len(pending) != 2 {
+		t.Fatalf("%d pending sync jobs", len(pending))
+	}
+
+	// Une fois terminé, la clé peut être replanifiée
+	runDue(q)
+	again, _ := q.Enqueue("sync", map[string]int{"n": 3}, EnqueueOptions{Key: "user-1"})
+	if again.State != JobPending || string(again.Payload) != `{"n":3}` {
+		t.Fatalf("finished job blocks its key: %+v", again)
+	}
+}
+
+// Deux instances sur le même stockage : chaque job ne tourne qu'une fois
+func TestJobRunsOnceAcrossWorkers(t *testing.T) {
+	store := storage.NewMemoryStore()
+	var (
+		mu      sync.Mutex
+		runs    = map[string]int{}
+		active  atomic.Int32
+		overlap atomic.Bool
+	)
+	handler := func(job *Job) error {
+		if active.Add(1) > 8 {
+			overlap.Store(true) // plus de jobs simultanés que de workers
+		}
+		defer active.Add(-1)
+		mu.Lock()
+		runs[job.ID]++
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		return nil
+	}
+
+	queues := []*JobQueue{}
+	for range 2 {
+		q := NewJobQueue(store, JobOptions{Workers: 4, PollInterval: time.Millisecond})
+		q.Register("work", handler)
+		queues = append(queues, q)
+	}
+
+	const total = 60
+	for i := range total {
+		if _, err := queues[i%2].Enqueue("work", i, EnqueueOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, q := range queues {
+		q.Start()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		done, _ := queues[0].List(JobSucceeded, "work")
+		if len(done) == total {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d/%d jobs succeeded", len(done), total)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, q := range queues {
+		if err := q.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(runs) != total {
+		t.Fatalf("%d distinct jobs ran, want %d", len(runs), total)
+	}
+	for id, n := range runs {
+		if n != 1 {
+			t.Errorf("job %s ran %d times", id, n)
+		}
+	}
+	if overlap.Load() {
+		t.Error("more concurrent jobs than workers")
+	}
+	if keys, _ := store.Keys(jobLockKeyPrefix); len(keys) != 0 {
+		t.Errorf("locks left behind: %v", keys)
+	}
+}
+
+func TestJobShutdownWaitsForRunningJobs(t *testing.T) {
+	q := NewJobQueue(storage.NewMemoryStore(), JobOptions{PollInterval: time.Millisecond})
+	started, release := make(chan struct{}), make(chan struct{})
+	q.Register("slow", func(job *Job) error {
+		close(started)
+		<-release
+		return errors.New("interrupted")
+	})
+	job, _ := q.Enqueue("slow", nil, EnqueueOptions{})
+	q.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown returned %v while a job was running", err)
+	}
+	close(release)
+	q.jobWG.Wait()
+	if got, _ := q.Get(job.ID); got.State != JobPending || got.Attempts != 1 {
+		t.Fatalf("job after shutdown: %+v", got)
+	}
+}
@@ -4,6 +4,30 @@ type MicrosoftTool struct {
 	Name        string
 	Description string
 	InputSchema map[string]interface{}
+	// Permissions d'application Graph requises (délégées si Delegated)
+	Permissions []string
+	Delegated   bool // appelle /me avec le jeton de l'utilisateur
+	SideEffects bool // crée, envoie ou supprime : exécuté une seule fois par activité
+}
+
+// toolSideEffects - Indique si l'outil modifie des données ou envoie un message
+func toolSideEffects(name string) bool {
+	for _, tool := range GetMicrosoftTools() {
+		if tool.Name == name {
+			return tool.SideEffects
+		}
+	}
+	return false
+}
+
+// toolPermissions - Permissions Graph déclarées pour un outil
+func toolPermissions(name string) ([]string, bool) {
+	for _, tool := range GetMicrosoftTools() {
+		if tool.Name == name {
+			return tool.Permissions, tool.Delegated
+		}
+	}
+	return nil, false
 }
 
 func GetMicrosoftTools() []MicrosoftTool {
@@ -12,40 +36,42 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_calendar_events",
 			Description: "Récupère les événements du calendrier d'un utilisateur",
+			Permissions: []string{"Calendars.Read"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"user_id": map[string]interface{}{
 						"type":        "string",
-						"description": "L'ID Azure AD de l'utilisateur",
+						"description": "L'ID Azure AD de l'utilisateur (par défaut : l'utilisateur courant)",
 					},
 				},
-				"required": []string{"user_id"},
 			},
 		},
 		{
 			Name:        "get_calendars",
 			Description: "Récupère la liste des calendriers d'un utilisateur",
+			Permissions: []string{"Calendars.Read"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"user_id": map[string]interface{}{
 						"type":        "string",
-						"description": "L'ID Azure AD de l'utilisateur",
+						"description": "L'ID Azure AD de l'utilisateur (par défaut : l'utilisateur courant)",
 					},
 				},
-				"required": []string{"user_id"},
 			},
 		},
 		{
 			Name:        "create_meeting",
 			Description: "Crée une réunion Teams dans le calendrier d'un utilisateur",
+			Permissions: []string{"Calendars.ReadWrite"},
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"user_id": map[string]interface{}{
 						"type":        "string",
-						"description": "L'ID Azure AD de l'organisateur",
+						"description": "L'ID Azure AD de l'organisateur (par défaut : l'utilisateur courant)",
 					},
 					"subject": map[string]interface{}{
 						"type":        "string",
@@ -65,12 +91,14 @@ func GetMicrosoftTools() []MicrosoftTool {
 						"items":       map[string]interface{}{"type": "string"},
 					},
 				},
-				"required": []string{"user_id", "subject", "start_time", "end_time"},
+				"required": []string{"subject", "start_time", "end_time"},
 			},
 		},
 		{
 			Name:        "find_meeting_times",
-			Description: "Trouve des créneaux disponibles pour une réunion",
+			Description: "Trouve des créneaux disponibles pour une réunion (nécessite que l'utilisateur se soit connecté)",
+			Permissions: []string{"Calendars.Read.Shared"},
+			Delegated:   true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -91,12 +119,14 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "send_email",
 			Description: "Envoie un email via Outlook",
+			Permissions: []string{"Mail.Send"},
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"from": map[string]interface{}{
 						"type":        "string",
-						"description": "Email ou ID de l'expéditeur",
+						"description": "Email ou ID de l'expéditeur (par défaut : l'utilisateur courant)",
 					},
 					"to": map[string]interface{}{
 						"type":        "string",
@@ -111,50 +141,53 @@ func GetMicrosoftTools() []MicrosoftTool {
 						"description": "Corps de l'email",
 					},
 				},
-				"required": []string{"from", "to", "subject", "body"},
+				"required": []string{"to", "subject", "body"},
 			},
 		},
 		{
 			Name:        "get_important_emails",
 			Description: "Récupère les emails importants d'un utilisateur",
+			Permissions: []string{"Mail.Read"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"user_id": map[string]interface{}{
 						"type":        "string",
-						"description": "L'ID Azure AD de l'utilisateur",
+						"description": "L'ID Azure AD de l'utilisateur (par défaut : l'utilisateur courant)",
 					},
 				},
-				"required": []string{"user_id"},
 			},
 		},
 		{
 			Name:        "get_emails_from",
 			Description: "Récupère les emails reçus d'un expéditeur spécifique",
+			Permissions: []string{"Mail.Read"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"user_id": map[string]interface{}{
 						"type":        "string",
-						"description": "L'ID Azure AD de l'utilisateur",
+						"description": "L'ID Azure AD de l'utilisateur (par défaut : l'utilisateur courant)",
 					},
 					"from_email": map[string]interface{}{
 						"type":        "string",
 						"description": "Email de l'expéditeur à filtrer",
 					},
 				},
-				"required": []string{"user_id", "from_email"},
+				"required": []string{"from_email"},
 			},
 		},
 		{
 			Name:        "forward_email",
 			Description: "Transfère un email à un autre destinataire",
+			Permissions: []string{"Mail.Send"},
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"user_id": map[string]interface{}{
 						"type":        "string",
-						"description": "L'ID Azure AD de l'utilisateur",
+						"description": "L'ID Azure AD de l'utilisateur (par défaut : l'utilisateur courant)",
 					},
 					"message_id": map[string]interface{}{
 						"type":        "string",
@@ -169,27 +202,28 @@ func GetMicrosoftTools() []MicrosoftTool {
 						"description": "Commentaire à ajouter",
 					},
 				},
-				"required": []string{"user_id", "message_id", "to_email"},
+				"required": []string{"message_id", "to_email"},
 			},
 		},
 		{
 			Name:        "get_email_delta",
 			Description: "Récupère les nouveaux emails depuis la dernière synchronisation",
+			Permissions: []string{"Mail.Read"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"user_id": map[string]interface{}{
 						"type":        "string",
-						"description": "L'ID Azure AD de l'utilisateur",
+						"description": "L'ID Azure AD de l'utilisateur (par défaut : l'utilisateur courant)",
 					},
 				},
-				"required": []string{"user_id"},
 			},
 		},
 		// === UTILISATEURS ===
 		{
 			Name:        "get_users",
 			Description: "Récupère la liste des utilisateurs de l'organisation",
+			Permissions: []string{"User.Read.All"},
 			InputSchema: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -198,6 +232,7 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_user_presence",
 			Description: "Récupère la présence/disponibilité d'un utilisateur",
+			Permissions: []string{"Presence.Read.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -213,20 +248,22 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_teams",
 			Description: "Récupère les équipes Teams d'un utilisateur",
+			Permissions: []string{"Team.ReadBasic.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"user_id": map[string]interface{}{
 						"type":        "string",
-						"description": "L'ID Azure AD de l'utilisateur",
+						"description": "L'ID Azure AD de l'utilisateur (par défaut : l'utilisateur courant)",
 					},
 				},
-				"required": []string{"user_id"},
 			},
 		},
 		{
 			Name:        "create_team",
 			Description: "Crée une nouvelle équipe Teams",
+			Permissions: []string{"Team.Create"},
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -249,6 +286,7 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_team_members",
 			Description: "Récupère les membres d'une équipe Teams",
+			Permissions: []string{"GroupMember.Read.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -263,6 +301,7 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_team_channels",
 			Description: "Récupère les canaux d'une équipe Teams",
+			Permissions: []string{"Channel.ReadBasic.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -277,6 +316,7 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_channel_info",
 			Description: "Récupère les informations d'un canal Teams",
+			Permissions: []string{"Channel.ReadBasic.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -295,6 +335,8 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "create_channel",
 			Description: "Crée un nouveau canal dans une équipe Teams",
+			Permissions: []string{"Channel.Create"},
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -321,6 +363,7 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_team_apps",
 			Description: "Récupère les applications installées dans une équipe",
+			Permissions: []string{"TeamsAppInstallation.ReadForTeam.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -335,6 +378,8 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "create_chat",
 			Description: "Crée un nouveau chat Teams",
+			Permissions: []string{"Chat.Create"},
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -357,7 +402,10 @@ func GetMicrosoftTools() []MicrosoftTool {
 		},
 		{
 			Name:        "send_chat_message",
-			Description: "Envoie un message dans un chat Teams",
+			Description: "Envoie un message dans un chat Teams, au nom de l'utilisateur (nécessite que l'utilisateur se soit connecté)",
+			Permissions: []string{"ChatMessage.Send"},
+			Delegated:   true,
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -375,7 +423,10 @@ func GetMicrosoftTools() []MicrosoftTool {
 		},
 		{
 			Name:        "send_channel_message",
-			Description: "Envoie un message dans un canal Teams",
+			Description: "Envoie un message dans un canal Teams, au nom de l'utilisateur (nécessite que l'utilisateur se soit connecté)",
+			Permissions: []string{"ChannelMessage.Send"},
+			Delegated:   true,
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -399,6 +450,7 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_groups",
 			Description: "Récupère tous les groupes Microsoft 365",
+			Permissions: []string{"Group.Read.All"},
 			InputSchema: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -407,6 +459,8 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "create_group",
 			Description: "Crée un nouveau groupe Microsoft 365",
+			Permissions: []string{"Group.Create"},
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -436,7 +490,9 @@ func GetMicrosoftTools() []MicrosoftTool {
 		},
 		{
 			Name:        "add_group_member",
-			Description: "Ajoute un membre à un groupe",
+			Description: "Ajoute un ou plusieurs membres à un groupe",
+			Permissions: []string{"GroupMember.ReadWrite.All"},
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -448,13 +504,20 @@ func GetMicrosoftTools() []MicrosoftTool {
 						"type":        "string",
 						"description": "L'ID Azure AD de l'utilisateur",
 					},
+					"user_ids": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "IDs Azure AD de plusieurs utilisateurs à ajouter en une fois",
+					},
 				},
-				"required": []string{"group_id", "user_id"},
+				"required": []string{"group_id"},
 			},
 		},
 		{
 			Name:        "remove_group_member",
 			Description: "Supprime un membre d'un groupe",
+			Permissions: []string{"GroupMember.ReadWrite.All"},
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -473,6 +536,8 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "delete_group",
 			Description: "Supprime un groupe Microsoft 365",
+			Permissions: []string{"Group.ReadWrite.All"},
+			SideEffects: true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -486,21 +551,18 @@ func GetMicrosoftTools() []MicrosoftTool {
 		},
 		{
 			Name:        "get_my_groups",
-			Description: "Récupère les groupes d'un utilisateur",
+			Description: "Récupère les groupes de l'utilisateur courant (nécessite que l'utilisateur se soit connecté)",
+			Permissions: []string{"GroupMember.Read.All"},
+			Delegated:   true,
 			InputSchema: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"user_id": map[string]interface{}{
-						"type":        "string",
-						"description": "L'ID Azure AD de l'utilisateur",
-					},
-				},
-				"required": []string{"user_id"},
+				"type":       "object",
+				"properties": map[string]interface{}{},
 			},
 		},
 		{
 			Name:        "get_group_conversations",
 			Description: "Récupère les conversations d'un groupe",
+			Permissions: []string{"Group.Read.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -515,6 +577,7 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_group_events",
 			Description: "Récupère les événements d'un groupe",
+			Permissions: []string{"Group.Read.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -530,6 +593,7 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_channel_messages",
 			Description: "Récupère les messages d'un canal Teams",
+			Permissions: []string{"ChannelMessage.Read.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -548,6 +612,7 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_message_replies",
 			Description: "Récupère les réponses à un message de canal",
+			Permissions: []string{"ChannelMessage.Read.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -570,20 +635,21 @@ func GetMicrosoftTools() []MicrosoftTool {
 		{
 			Name:        "get_installed_apps",
 			Description: "Récupère les applications Teams installées pour un utilisateur",
+			Permissions: []string{"TeamsAppInstallation.ReadForUser.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"user_id": map[string]interface{}{
 						"type":        "string",
-						"description": "L'ID Azure AD de l'utilisateur",
+						"description": "L'ID Azure AD de l'utilisateur (par défaut : l'utilisateur courant)",
 					},
 				},
-				"required": []string{"user_id"},
 			},
 		},
 		{
 			Name:        "get_chat_members",
 			Description: "Récupère les membres d'un chat Teams",
+			Permissions: []string{"ChatMember.Read.All"},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -595,5 +661,117 @@ func GetMicrosoftTools() []MicrosoftTool {
 				"required": []string{"chat_id"},
 			},
 		},
+
+		// === MÉMOIRE ===
+		{
+			Name:        "remember",
+			Description: "Retient une préférence ou une information sur l'utilisateur courant, conservée d'une conversation à l'autre (chat et appels vocaux)",
+			SideEffects: true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"kind": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"language", "time_zone", "meeting_length", "contact", "fact"},
+						"description": "Type d'entrée : langue, fuseau horaire IANA, durée de réunion en minutes, contact ou fait libre",
+					},
+					"value": map[string]interface{}{
+						"type":        "string",
+						"description": "Valeur à retenir (ex. 'fr', 'Europe/Paris', '30', l'email du contact, ou le fait à retenir)",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Nom du contact (kind = contact)",
+					},
+				},
+				"required": []string{"kind", "value"},
+			},
+		},
+		{
+			Name:        "recall",
+			Description: "Retourne tout ce que NEO a retenu sur l'utilisateur courant (préférences, contacts fréquents, souvenirs)",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "forget",
+			Description: "Oublie une entrée de la mémoire de l'utilisateur courant, ou toute sa mémoire (kind = all)",
+			SideEffects: true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"kind": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"language", "time_zone", "meeting_length", "contact", "fact", "all"},
+						"description": "Type d'entrée à oublier",
+					},
+					"value": map[string]interface{}{
+						"type":        "string",
+						"description": "ID ou texte du souvenir, email ou nom du contact (kind = fact ou contact)",
+					},
+				},
+				"required": []string{"kind"},
+			},
+		},
+
+		// === RAPPELS ===
+		{
+			Name:        "create_reminder",
+			Description: "Programme un rappel pour l'utilisateur courant, envoyé dans cette conversation Teams à l'heure dite (ex. 'rappelle-moi demain à 9h de relancer Paul'). Calcule la date à partir de la date et l'heure actuelles du contexte",
+			SideEffects: true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text": map[string]interface{}{
+						"type":        "string",
+						"description": "Ce qu'il faut rappeler (ex. 'Relancer Paul')",
+					},
+					"due": map[string]interface{}{
+						"type":        "string",
+						"description": "Date et heure locales dans le fuseau de l'utilisateur, format 2006-01-02T15:04 (ex. 2026-10-20T09:00)",
+					},
+					"recurrence": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"none", "daily", "weekdays", "weekly", "monthly"},
+						"description": "Répétition du rappel (none par défaut)",
+					},
+					"link_type": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"email", "event"},
+						"description": "Type de l'élément lié au rappel (optionnel)",
+					},
+					"link_id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID de l'email ou de l'événement lié, obtenu par un autre outil (optionnel)",
+					},
+				},
+				"required": []string{"text", "due"},
+			},
+		},
+		{
+			Name:        "list_reminders",
+			Description: "Liste les rappels programmés de l'utilisateur courant, avec leur ID",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "cancel_reminder",
+			Description: "Annule un rappel de l'utilisateur courant (et ses répétitions)",
+			SideEffects: true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"reminder_id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID du rappel, donné par list_reminders",
+					},
+				},
+				"required": []string{"reminder_id"},
+			},
+		},
 	}
 }
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rôles qui en accordent d'autres, en plus de la règle générique ReadWrite ⇒ Read
+var impliedRoles = map[string][]string{
+	"Directory.Read.All":        {"User.Read.All", "Group.Read.All", "GroupMember.Read.All"},
+	"Directory.ReadWrite.All":   {"User.ReadWrite.All", "Group.ReadWrite.All", "GroupMember.ReadWrite.All"},
+	"Group.Read.All":            {"GroupMember.Read.All"},
+	"Group.ReadWrite.All":       {"Group.Create", "GroupMember.ReadWrite.All"},
+	"GroupMember.ReadWrite.All": {"GroupMember.Read.All"},
+	"TeamSettings.Read.All":     {"Team.ReadBasic.All"},
+	"ChannelSettings.Read.All":  {"Channel.ReadBasic.All"},
+	"Chat.Read.All":             {"ChatMember.Read.All"},
+	"Chat.ReadWrite.All":        {"Chat.Create", "ChatMember.Read.All"},
+}
+
+// DisabledTool - Outil retiré faute de permissions
+type DisabledTool struct {
+	Name    string   `json:"name"`
+	Missing []string `json:"missing"`
+}
+
+// PermissionReport - Résultat de la vérification pour un tenant
+type PermissionReport struct {
+	TenantID  string         `json:"tenant_id"`
+	Roles     []string       `json:"roles"`
+	CheckedAt time.Time      `json:"checked_at"`
+	Disabled  []DisabledTool `json:"disabled_tools"`
+}
+
+// PermissionChecker - Compare les rôles du jeton Graph d'application avec les
+// permissions déclarées par les outils, par tenant
+type PermissionChecker struct {
+	homeTenant string
+	graphScope string
+
+	mu      sync.RWMutex
+	reports map[string]*PermissionReport
+}
+
+func NewPermissionChecker(homeTenant, graphScope string) *PermissionChecker {
+	return &PermissionChecker{
+		homeTenant: homeTenant,
+		graphScope: graphScope,
+		reports:    make(map[string]*PermissionReport),
+	}
+}
+
+// ObserveToken - Branché sur le CredentialProvider Graph, appelé à chaque nouveau jeton
+func (c *PermissionChecker) ObserveToken(tenantID, scope, accessToken string) {
+	if scope != c.graphScope {
+		return
+	}
+
+	roles, err := tokenRoles(accessToken)
+	if err != nil {
+		// Jeton opaque : on ne peut rien conclure, tous les outils restent disponibles
+		log.Printf("⚠️ Permission check skipped for tenant %s: %v", tenantID, err)
+		return
+	}
+
+	report := checkPermissions(tenantID, roles)
+	for _, tool := range report.Disabled {
+		log.Printf("⚠️ Tool %s disabled for tenant %s: missing %s", tool.Name, tenantID, strings.Join(tool.Missing, ", "))
+	}
+
+	c.mu.Lock()
+	c.reports[tenantID] = report
+	c.mu.Unlock()
+}
+
+// Available - Indique si l'outil peut être proposé au modèle pour ce tenant
+func (c *PermissionChecker) Available(tenantID, toolName string) bool {
+	return len(c.Missing(tenantID, toolName)) == 0
+}
+
+// Missing - Permissions d'application manquantes qui désactivent l'outil dans
+// ce tenant ; nil si l'outil est disponible ou si le tenant n'a pas été vérifié
+func (c *PermissionChecker) Missing(tenantID, toolName string) []string {
+	if c == nil {
+		return nil
+	}
+	if tenantID == "" {
+		tenantID = c.homeTenant
+	}
+
+	c.mu.RLock()
+	report, ok := c.reports[tenantID]
+	c.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	for _, tool := range report.Disabled {
+		if tool.Name == toolName {
+			return tool.Missing
+		}
+	}
+	return nil
+}
+
+// disabledToolMessage - Message d'outil quand le modèle appelle un outil désactivé
+func disabledToolMessage(toolName string, missing []string) string {
+	return fmt.Sprintf("Erreur: l'outil « %s » est désactivé dans ce tenant : l'application NEO n'a pas la permission d'application %s. Un administrateur doit l'accorder (consentement administrateur) dans Azure AD.", toolName, strings.Join(missing, ", "))
+}
+
+// Reports - Dernier rapport de chaque tenant vérifié
+func (c *PermissionChecker) Reports() []PermissionReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	reports := make([]PermissionReport, 0, len(c.reports))
+	for _, report := range c.reports {
+		reports = append(reports, *report)
+	}
+	slices.SortFunc(reports, func(a, b PermissionReport) int {
+		return strings.Compare(a.TenantID, b.TenantID)
+	})
+	return reports
+}
+
+func checkPermissions(tenantID string, roles []string) *PermissionReport {
+	granted := expandRoles(roles)
+	report := &PermissionReport{
+		TenantID:  tenantID,
+		Roles:     roles,
+		CheckedAt: time.Now(),
+		Disabled:  []DisabledTool{},
+	}
+
+	for _, tool := range GetMicrosoftTools() {
+		// Les outils délégués dépendent du consentement de l'utilisateur, pas des rôles
+		if tool.Delegated {
+			continue
+		}
+		var missing []string
+		for _, permission := range tool.Permissions {
+			if !granted[permission] {
+				missing = append(missing, permission)
+			}
+		}
+		if len(missing) > 0 {
+			report.Disabled = append(report.Disabled, DisabledTool{Name: tool.Name, Missing: missing})
+		}
+	}
+	return report
+}
+
+func expandRoles(roles []string) map[string]bool {
+	granted := make(map[string]bool)
+	var grant func(role string)
+	grant = func(role string) {
+		if granted[role] {
+			return
+		}
+		granted[role] = true
+		if strings.Contains(role, ".ReadWrite") {
+			grant(strings.Replace(role, ".ReadWrite", ".Read", 1))
+		}
+		for _, implied := range impliedRoles[role] {
+			grant(implied)
+		}
+	}
+	for _, role := range roles {
+		grant(role)
+	}
+	return granted
+}
+
+// tokenRoles - Claim roles du JWT, sans vérification de signature (jeton reçu d'AAD)
+func tokenRoles(accessToken string) ([]string, error) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("access token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode token payload: %w", err)
+	}
+
+	var claims struct {
+		Roles []string `json:"roles"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse token claims: %w", err)
+	}
+	return claims.Roles, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testGraphScope = "https://graph.microsoft.com/.default"
+
+func TestPermissionCheckerMissing(t *testing.T) {
+	checker := NewPermissionChecker("tenant-1", testGraphScope)
+	if !checker.Available("tenant-1", "create_group") {
+		t.Fatal("tool disabled before any token was observed")
+	}
+
+	checker.ObserveToken("tenant-1", testGraphScope, unsignedJWT(map[string]any{"roles": []string{"User.Read.All"}}))
+	if missing := checker.Missing("", "create_group"); len(missing) != 1 || missing[0] != "Group.Create" {
+		t.Fatalf("Missing(create_group) = %v", missing)
+	}
+	if !checker.Available("tenant-1", "get_users") {
+		t.Fatal("get_users disabled with User.Read.All")
+	}
+	// Group.ReadWrite.All accorde Group.Create
+	checker.ObserveToken("tenant-2", testGraphScope, unsignedJWT(map[string]any{"roles": []string{"Group.ReadWrite.All"}}))
+	if !checker.Available("tenant-2", "create_group") {
+		t.Fatal("create_group disabled with Group.ReadWrite.All")
+	}
+}
+
+func TestToolExecutorRefusesDisabledTool(t *testing.T) {
+	var requests atomic.Int32
+	graph := newTestGraphService(t, GraphOptions{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"id": "group-1"})
+	}))
+
+	checker := NewPermissionChecker("tenant-1", testGraphScope)
+	checker.ObserveToken("tenant-1", testGraphScope, unsignedJWT(map[string]any{"roles": []string{"User.Read.All"}}))
+
+	caller := &CallerIdentity{UserID: "user-1", TenantID: "tenant-1"}
+	executor := NewToolExecutor(caller, NewDelegationPolicy(""), checker, nil, nil, nil, nil)
+
+	result := executor.Execute("create_group", json.RawMessage(`{"display_name":"x","mail_nickname":"x"}`), graph)
+	if !strings.Contains(result, "désactivé") || !strings.Contains(result, "Group.Create") {
+		t.Fatalf("result = %q", result)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("disabled tool reached Graph (%d requests)", n)
+	}
+
+	if result := executor.Execute("get_users", nil, graph); strings.HasPrefix(result, "Erreur") {
+		t.Fatalf("available tool refused: %q", result)
+	}
+}
+
+// L'envoi de messages Teams passe par le jeton de l'utilisateur : les rôles
+// d'application du tenant ne le désactivent pas
+func TestMessagingToolsAreDelegated(t *testing.T) {
+	checker := NewPermissionChecker("tenant-1", testGraphScope)
+	checker.ObserveToken("tenant-1", testGraphScope, unsignedJWT(map[string]any{"roles": []string{"User.Read.All", "Chat.Read.All"}}))
+
+	for _, name := range []string{"send_chat_message", "send_channel_message"} {
+		if missing := checker.Missing("tenant-1", name); len(missing) != 0 {
+			t.Errorf("%s disabled, missing %v", name, missing)
+		}
+		permissions, delegated := toolPermissions(name)
+		if !delegated || len(permissions) != 1 || !strings.HasSuffix(permissions[0], "Message.Send") {
+			t.Errorf("%s declares %v (delegated=%v)", name, permissions, delegated)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// memoryTool - Outils remember, recall et forget, toujours sur le profil de l'appelant
+func (e *ToolExecutor) memoryTool(toolName string, input json.RawMessage) (string, error) {
+	if e.profiles == nil {
+		return "", errors.New("la mémoire utilisateur n'est pas configurée")
+	}
+	if e.caller == nil || e.caller.UserID == "" {
+		return "", errors.New("utilisateur courant non identifié")
+	}
+	userID := e.caller.UserID
+
+	var params struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+		Name  string `json:"name"`
+	}
+	json.Unmarshal(input, &params)
+
+	switch toolName {
+	case "remember":
+		if params.Value == "" {
+			return "", errors.New("kind et value requis")
+		}
+		var saved string
+		err := e.profiles.Update(userID, func(profile *UserProfile) error {
+			switch params.Kind {
+			case "fact":
+				fact := profile.AddFact(params.Value)
+				saved = fmt.Sprintf("Souvenir enregistré (id %s)", fact.ID)
+			case "contact":
+				profile.AddContact(params.Name, params.Value)
+				saved = "Contact enregistré"
+			default:
+				if err := profile.SetPreference(params.Kind, params.Value); err != nil {
+					return err
+				}
+				saved = "Préférence enregistrée"
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+		return saved, nil
+
+	case "recall":
+		profile, _ := json.Marshal(e.profiles.Get(userID))
+		return string(profile), nil
+
+	case "forget":
+		if params.Kind == "all" {
+			if err := e.profiles.Delete(userID); err != nil {
+				return "", err
+			}
+			return "Toute la mémoire de l'utilisateur a été effacée", nil
+		}
+		found := true
+		err := e.profiles.Update(userID, func(profile *UserProfile) error {
+			switch params.Kind {
+			case "fact":
+				found = profile.ForgetFact(params.Value)
+			case "contact":
+				found = profile.ForgetContact(params.Value)
+			case "language":
+				profile.Language = ""
+			case "time_zone":
+				profile.TimeZone = ""
+			case "meeting_length":
+				profile.MeetingMinutes = 0
+			default:
+				return fmt.Errorf("type d'entrée inconnu %q", params.Kind)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return fmt.Sprintf("Aucune entrée %s ne correspond à '%s'", params.Kind, params.Value), nil
+		}
+		return "Entrée oubliée", nil
+	}
+	return "", fmt.Errorf("outil inconnu: %s", toolName)
+}
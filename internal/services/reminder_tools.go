@@ -0,0 +1,93 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// reminderTool - Outils create_reminder, list_reminders et cancel_reminder, sur
+// les rappels de l'appelant
+func (e *ToolExecutor) reminderTool(toolName string, input json.RawMessage, graphService *GraphService) (string, error) {
+	if e.reminders == nil {
+		return "", errors.New("les rappels ne sont pas configurés")
+	}
+	if e.caller == nil || e.caller.UserID == "" {
+		return "", errors.New("utilisateur courant non identifié")
+	}
+	userID := e.caller.UserID
+
+	var params struct {
+		Text       string `json:"text"`
+		Due        string `json:"due"`
+		Recurrence string `json:"recurrence"`
+		LinkType   string `json:"link_type"`
+		LinkID     string `json:"link_id"`
+		ReminderID string `json:"reminder_id"`
+	}
+	json.Unmarshal(input, &params)
+
+	switch toolName {
+	case "create_reminder":
+		if params.Text == "" || params.Due == "" {
+			return "", errors.New("text et due requis")
+		}
+		var link *ReminderLink
+		if params.LinkID != "" {
+			var err error
+			if link, err = reminderLink(graphService, userID, params.LinkType, params.LinkID); err != nil {
+				return "", err
+			}
+		}
+		reminder, err := e.reminders.Create(userID, e.caller.Conversation, params.Text, params.Due, params.Recurrence, link)
+		if err != nil {
+			return "", err
+		}
+		return "Rappel programmé : " + reminder.Describe(), nil
+
+	case "list_reminders":
+		reminders := e.reminders.List(userID)
+		if len(reminders) == 0 {
+			return "Aucun rappel programmé", nil
+		}
+		lines := make([]string, 0, len(reminders))
+		for _, reminder := range reminders {
+			lines = append(lines, "- "+reminder.Describe())
+		}
+		return fmt.Sprintf("%d rappel(s) :\n%s", len(reminders), strings.Join(lines, "\n")), nil
+
+	case "cancel_reminder":
+		if params.ReminderID == "" {
+			return "", errors.New("reminder_id requis")
+		}
+		reminder, err := e.reminders.Cancel(userID, strings.Trim(params.ReminderID, "[] "))
+		if err != nil {
+			return "", err
+		}
+		return "Rappel annulé : " + reminder.Text, nil
+	}
+	return "", fmt.Errorf("outil inconnu: %s", toolName)
+}
+
+// reminderLink - Sujet et lien web de l'email ou de l'événement, dans la boîte de l'appelant
+func reminderLink(graphService *GraphService, userID, linkType, linkID string) (*ReminderLink, error) {
+	var endpoint string
+	switch linkType {
+	case "email":
+		endpoint = "/users/" + PathSegment(userID) + "/messages/" + PathSegment(linkID) + NewQuery().Select("subject", "webLink").String()
+	case "event":
+		endpoint = "/users/" + PathSegment(userID) + "/events/" + PathSegment(linkID) + NewQuery().Select("subject", "webLink").String()
+	default:
+		return nil, fmt.Errorf("link_type doit valoir email ou event")
+	}
+
+	item, err := graphService.Get(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s introuvable: %w", linkType, linkID, err)
+	}
+	link := &ReminderLink{Type: linkType, ID: linkID}
+	link.Subject, _ = item["subject"].(string)
+	link.WebURL, _ = item["webLink"].(string)
+	return link, nil
+}
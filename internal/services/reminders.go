@@ -0,0 +1,352 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"microsoft_connector/internal/storage"
+)
+
+const (
+	reminderKeyPrefix  = "reminders/"
+	reminderJob        = "reminder.fire"
+	reminderMaxPerUser = 50
+	// Tolérance pour une échéance "maintenant" arrivée pendant l'appel à l'outil
+	reminderPastSlack = time.Minute
+)
+
+// Récurrences acceptées
+var reminderRecurrences = map[string]string{
+	"daily":    "chaque jour",
+	"weekdays": "chaque jour ouvré",
+	"weekly":   "chaque semaine",
+	"monthly":  "chaque mois",
+}
+
+// ReminderLink - Email ou événement auquel le rappel renvoie
+type ReminderLink struct {
+	Type    string `json:"type"` // email, event
+	ID      string `json:"id"`
+	Subject string `json:"subject,omitempty"`
+	WebURL  string `json:"web_url,omitempty"`
+}
+
+// Reminder - Rappel programmé, livré en message proactif dans Teams
+type Reminder struct {
+	ID           string                 `json:"id"`
+	UserID       string                 `json:"user_id"`
+	Text         string                 `json:"text"`
+	DueAt        time.Time              `json:"due_at"`       // prochaine échéance
+	FirstDueAt   time.Time              `json:"first_due_at"` // ancre des récurrences
+	TimeZone     string                 `json:"time_zone"`    // fuseau de l'utilisateur à la création
+	Recurrence   string                 `json:"recurrence,omitempty"`
+	Link         *ReminderLink          `json:"link,omitempty"`
+	Conversation *ConversationReference `json:"conversation"`
+	JobID        string                 `json:"job_id,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// reminderPayload - Job d'échéance d'un rappel
+type reminderPayload struct {
+	UserID     string `json:"user_id"`
+	ReminderID string `json:"reminder_id"`
+}
+
+// ReminderService - Rappels persistants, déclenchés par la file de jobs
+type ReminderService struct {
+	store    storage.Store
+	jobs     *JobQueue
+	profiles *ProfileStore
+	notifier ProactiveNotifier
+	mu       sync.Mutex
+}
+
+func NewReminderService(store storage.Store, jobs *JobQueue, profiles *ProfileStore) *ReminderService {
+	s := &ReminderService{store: store, jobs: jobs, profiles: profiles}
+	jobs.Register(reminderJob, s.fire)
+	return s
+}
+
+// SetNotifier - Le handler du bot est créé après les services
+func (s *ReminderService) SetNotifier(notifier ProactiveNotifier) {
+	s.notifier = notifier
+}
+
+// Create - due est une date locale dans le fuseau de l'utilisateur
+// ("2006-01-02T15:04"), ou une date RFC 3339 avec décalage
+func (s *ReminderService) Create(userID string, conversation *ConversationReference, text, due, recurrence string, link *ReminderLink) (*Reminder, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("le texte du rappel est requis")
+	}
+	if conversation == nil {
+		return nil, fmt.Errorf("les rappels ne peuvent être créés que depuis une conversation Teams")
+	}
+	if recurrence == "none" {
+		recurrence = ""
+	}
+	if _, ok := reminderRecurrences[recurrence]; recurrence != "" && !ok {
+		return nil, fmt.Errorf("récurrence inconnue %q (daily, weekdays, weekly ou monthly)", recurrence)
+	}
+
+	loc := s.profiles.Location(userID)
+	dueAt, err := ParseLocalTime(due, loc)
+	if err != nil {
+		return nil, err
+	}
+	if dueAt.Before(time.Now().Add(-reminderPastSlack)) {
+		return nil, fmt.Errorf("l'échéance %s est déjà passée", dueAt.In(loc).Format("02/01/2006 15:04"))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing := s.List(userID); len(existing) >= reminderMaxPerUser {
+		return nil, fmt.Errorf("limite de %d rappels atteinte, annule-en avant d'en créer d'autres", reminderMaxPerUser)
+	}
+
+	reminder := &Reminder{
+		ID:           newFactID(),
+		UserID:       userID,
+		Text:         text,
+		DueAt:        dueAt,
+		FirstDueAt:   dueAt,
+		TimeZone:     loc.String(),
+		Recurrence:   recurrence,
+		Link:         link,
+		Conversation: conversation,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.schedule(reminder); err != nil {
+		return nil, err
+	}
+	log.Printf("[Reminders] Rappel %s pour %s le %s", reminder.ID, userID, dueAt.Format(time.RFC3339))
+	return reminder, nil
+}
+
+// List - Rappels actifs de l'utilisateur, par échéance
+func (s *ReminderService) List(userID string) []Reminder {
+	keys, err := s.store.Keys(reminderKeyPrefix + userID + "/")
+	if err != nil {
+		log.Printf("[Reminders] Lecture des rappels de %s impossible: %v", userID, err)
+		return nil
+	}
+
+	reminders := []Reminder{}
+	for _, key := range keys {
+		reminder, err := s.load(userID, key[strings.LastIndex(key, "/")+1:])
+		if err == nil {
+			reminders = append(reminders, *reminder)
+		}
+	}
+	slices.SortFunc(reminders, func(a, b Reminder) int {
+		return a.DueAt.Compare(b.DueAt)
+	})
+	return reminders
+}
+
+// Cancel - Supprime le rappel et son job d'échéance
+func (s *ReminderService) Cancel(userID, reminderID string) (*Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder, err := s.load(userID, reminderID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("aucun rappel %q", reminderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reminder.JobID != "" {
+		s.jobs.Delete(reminder.JobID)
+	}
+	return reminder, s.store.Delete(reminderKeyPrefix + userID + "/" + reminderID)
+}
+
+// EraseUser - Effacement RGPD : rappels et jobs associés
+func (s *ReminderService) EraseUser(userID string) (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	count := 0
+	for _, reminder := range s.List(userID) {
+		if _, err := s.Cancel(userID, reminder.ID); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Describe - Résumé lisible, dans le fuseau du rappel
+func (r *Reminder) Describe() string {
+	loc, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	line := fmt.Sprintf("[%s] %s — %s", r.ID, r.DueAt.In(loc).Format("02/01/2006 15:04"), r.Text)
+	if r.Recurrence != "" {
+		line += " (" + reminderRecurrences[r.Recurrence] + ")"
+	}
+	if r.Link != nil && r.Link.Subject != "" {
+		line += fmt.Sprintf(" [%s : %s]", r.Link.Type, r.Link.Subject)
+	}
+	return line
+}
+
+// message - Texte du message proactif
+func (r *Reminder) message() string {
+	msg := "⏰ Rappel : " + r.Text
+	if r.Link != nil {
+		label := r.Link.Subject
+		if label == "" {
+			label = r.Link.ID
+		}
+		icon := "📧"
+		if r.Link.Type == "event" {
+			icon = "📅"
+		}
+		if r.Link.WebURL != "" {
+			msg += fmt.Sprintf("\n\n%s [%s](%s)", icon, label, r.Link.WebURL)
+		} else {
+			msg += fmt.Sprintf("\n\n%s %s", icon, label)
+		}
+	}
+	return msg
+}
+
+// fire - Livre le rappel puis planifie l'occurrence suivante, ou le supprime
+func (s *ReminderService) fire(job *Job) error {
+	var payload reminderPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	reminder, err := s.load(payload.UserID, payload.ReminderID)
+	s.mu.Unlock()
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && reminder.JobID != job.ID) {
+		return nil // annulé ou replanifié entre-temps
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.notifier == nil {
+		return fmt.Errorf("no proactive notifier configured")
+	}
+	if err := s.notifier.Notify(reminder.Conversation, reminder.message()); err != nil {
+		return err
+	}
+	log.Printf("[Reminders] Rappel %s livré à %s", reminder.ID, reminder.UserID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Annulé pendant la livraison
+	if current, err := s.load(reminder.UserID, reminder.ID); err != nil || current.JobID != job.ID {
+		return nil
+	}
+	if reminder.Recurrence == "" {
+		return s.store.Delete(reminderKeyPrefix + reminder.UserID + "/" + reminder.ID)
+	}
+	// Les occurrences manquées pendant un arrêt ne sont pas rattrapées
+	reminder.DueAt = reminder.next(time.Now())
+	return s.schedule(reminder)
+}
+
+// schedule - Enregistre le rappel et le job de sa prochaine échéance
+func (s *ReminderService) schedule(reminder *Reminder) error {
+	payload := reminderPayload{UserID: reminder.UserID, ReminderID: reminder.ID}
+	job, err := s.jobs.Enqueue(reminderJob, payload, EnqueueOptions{RunAt: reminder.DueAt})
+	if err != nil {
+		return err
+	}
+	reminder.JobID = job.ID
+	if err := s.save(reminder); err != nil {
+		s.jobs.Delete(job.ID)
+		return err
+	}
+	return nil
+}
+
+// next - Première occurrence après t, à heure locale constante (changements d'heure compris)
+func (r *Reminder) next(after time.Time) time.Time {
+	loc, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	first := r.FirstDueAt.In(loc)
+	due := r.DueAt.In(loc)
+
+	for !due.After(after) {
+		switch r.Recurrence {
+		case "daily":
+			due = due.AddDate(0, 0, 1)
+		case "weekdays":
+			due = due.AddDate(0, 0, 1)
+			for due.Weekday() == time.Saturday || due.Weekday() == time.Sunday {
+				due = due.AddDate(0, 0, 1)
+			}
+		case "weekly":
+			due = due.AddDate(0, 0, 7)
+		case "monthly":
+			due = addMonths(first, monthsBetween(first, due)+1)
+		default:
+			return due
+		}
+	}
+	return due
+}
+
+// addMonths - Le 31 devient le dernier jour des mois plus courts
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	firstOfTarget := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), 0, 0, t.Location())
+	lastDay := firstOfTarget.AddDate(0, 1, -1).Day()
+	return firstOfTarget.AddDate(0, 0, min(day, lastDay)-1)
+}
+
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+}
+
+// ParseLocalTime - Date RFC 3339, ou date locale sans décalage interprétée dans loc
+func ParseLocalTime(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date invalide %q (format attendu : 2006-01-02T15:04)", value)
+}
+
+func (s *ReminderService) load(userID, reminderID string) (*Reminder, error) {
+	data, err := s.store.Get(reminderKeyPrefix + userID + "/" + reminderID)
+	if err != nil {
+		return nil, err
+	}
+	var reminder Reminder
+	if err := json.Unmarshal(data, &reminder); err != nil {
+		return nil, fmt.Errorf("corrupted reminder %s: %w", reminderID, err)
+	}
+	return &reminder, nil
+}
+
+func (s *ReminderService) save(reminder *Reminder) error {
+	data, err := json.Marshal(reminder)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reminder: %w", err)
+	}
+	return s.store.Set(reminderKeyPrefix+reminder.UserID+"/"+reminder.ID, data, 0)
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"microsoft_connector/internal/storage"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("tzdata indisponible: %v", err)
+	}
+	return loc
+}
+
+func TestReminderNext(t *testing.T) {
+	paris := mustLocation(t, "Europe/Paris")
+	at := func(value string) time.Time {
+		t.Helper()
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, paris)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name       string
+		recurrence string
+		first, due string
+		after      string
+		want       string
+	}{
+		{"monthly 31 to february", "monthly", "2026-01-31 09:00", "2026-01-31 09:00", "2026-01-31 09:00", "2026-02-28 09:00"},
+		{"monthly february back to 31", "monthly", "2026-01-31 09:00", "2026-02-28 09:00", "2026-02-28 09:00", "2026-03-31 09:00"},
+		{"monthly leap year", "monthly", "2028-01-31 09:00", "2028-01-31 09:00", "2028-01-31 09:00", "2028-02-29 09:00"},
+		{"monthly skips missed occurrences", "monthly", "2026-01-31 09:00", "2026-01-31 09:00", "2026-04-10 12:00", "2026-04-30 09:00"},
+		{"weekdays friday to monday", "weekdays", "2026-10-16 08:30", "2026-10-16 08:30", "2026-10-16 08:30", "2026-10-19 08:30"},
+		{"weekdays from saturday", "weekdays", "2026-10-16 08:30", "2026-10-16 08:30", "2026-10-17 10:00", "2026-10-19 08:30"},
+		{"weekdays midweek", "weekdays", "2026-10-20 08:30", "2026-10-20 08:30", "2026-10-20 08:30", "2026-10-21 08:30"},
+		{"daily across spring forward", "daily", "2026-03-28 09:00", "2026-03-28 09:00", "2026-03-28 09:00", "2026-03-29 09:00"},
+		{"daily across fall back", "daily", "2026-10-24 09:00", "2026-10-24 09:00", "2026-10-24 09:00", "2026-10-25 09:00"},
+		{"weekdays across fall back weekend", "weekdays", "2026-10-23 09:00", "2026-10-23 09:00", "2026-10-23 09:00", "2026-10-26 09:00"},
+		{"weekly across spring forward", "weekly", "2026-03-25 18:00", "2026-03-25 18:00", "2026-03-25 18:00", "2026-04-01 18:00"},
+		{"one-shot", "", "2026-03-25 18:00", "2026-03-25 18:00", "2026-03-26 18:00", "2026-03-25 18:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reminder := &Reminder{
+				Recurrence: tt.recurrence,
+				TimeZone:   paris.String(),
+				FirstDueAt: at(tt.first),
+				DueAt:      at(tt.due),
+			}
+			got := reminder.next(at(tt.after))
+			if want := at(tt.want); !got.Equal(want) {
+				t.Fatalf("next = %s, want %s", got.In(paris), want)
+			}
+			// L'heure locale est conservée, quel que soit le décalage UTC
+			if local := got.In(paris); local.Format("15:04") != tt.want[11:] {
+				t.Fatalf("local time drifted: %s", local)
+			}
+		})
+	}
+
+	// Le décalage UTC suit le changement d'heure
+	daily := &Reminder{Recurrence: "daily", TimeZone: paris.String(), FirstDueAt: at("2026-03-28 09:00"), DueAt: at("2026-03-28 09:00")}
+	if before, after := daily.DueAt.UTC().Hour(), daily.next(daily.DueAt).UTC().Hour(); before != 8 || after != 7 {
+		t.Fatalf("UTC hours %d -> %d, want 8 -> 7", before, after)
+	}
+}
+
+func TestAddMonths(t *testing.T) {
+	paris := mustLocation(t, "Europe/Paris")
+	tests := []struct {
+		from   string
+		months int
+		want   string
+	}{
+		{"2026-01-31", 1, "2026-02-28"},
+		{"2026-01-31", 2, "2026-03-31"},
+		{"2026-01-31", 3, "2026-04-30"},
+		{"2028-01-31", 1, "2028-02-29"},
+		{"2026-01-30", 1, "2026-02-28"},
+		{"2026-12-31", 2, "2027-02-28"},
+		{"2026-05-15", 12, "2027-05-15"},
+	}
+	for _, tt := range tests {
+		from, _ := time.ParseInLocation("2006-01-02 15:04", tt.from+" 07:45", paris)
+		got := addMonths(from, tt.months)
+		if got.Format("2006-01-02 15:04") != tt.want+" 07:45" || got.Location() != paris {
+			t.Errorf("addMonths(%s, %d) = %s, want %s 07:45", tt.from, tt.months, got, tt.want)
+		}
+	}
+}
+
+func TestParseLocalTime(t *testing.T) {
+	paris := mustLocation(t, "Europe/Paris")
+	tests := []struct {
+		value string
+		want  string // RFC 3339
+	}{
+		{"2026-01-15T09:00", "2026-01-15T09:00:00+01:00"},
+		{"2026-07-15T09:00", "2026-07-15T09:00:00+02:00"},
+		{" 2026-07-15 09:00 ", "2026-07-15T09:00:00+02:00"},
+		{"2026-03-29T03:30:15", "2026-03-29T03:30:15+02:00"},
+		{"2026-10-25T04:00", "2026-10-25T04:00:00+01:00"},
+		// Un décalage explicite l'emporte sur le fuseau de l'utilisateur
+		{"2026-07-15T09:00:00-04:00", "2026-07-15T09:00:00-04:00"},
+		{"2026-07-15T09:00:00Z", "2026-07-15T09:00:00Z"},
+	}
+	for _, tt := range tests {
+		got, err := ParseLocalTime(tt.value, paris)
+		if err != nil {
+			t.Errorf("ParseLocalTime(%q): %v", tt.value, err)
+			continue
+		}
+		if want, _ := time.Parse(time.RFC3339, tt.want); !got.Equal(want) {
+			t.Errorf("ParseLocalTime(%q) = %s, want %s", tt.value, got.Format(time.RFC3339), tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "demain 9h", "15/07/2026 09:00", "2026-07-15"} {
+		if _, err := ParseLocalTime(value, paris); err == nil {
+			t.Errorf("ParseLocalTime(%q) accepted", value)
+		}
+	}
+}
+
+func newReminderTest(t *testing.T) (*ReminderService, *JobQueue, *fakeNotifier) {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	// File non démarrée : les jobs sont exécutés à la main via fire
+	jobs := NewJobQueue(store, JobOptions{})
+	reminders := NewReminderService(store, jobs, NewProfileStore(store))
+	notifier := &fakeNotifier{}
+	reminders.SetNotifier(notifier)
+	return reminders, jobs, notifier
+}
+
+func TestReminderFireSkipsCancelledAndRescheduled(t *testing.T) {
+	reminders, jobs, notifier := newReminderTest(t)
+	due := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	// Annulé : le job déjà réclamé ne livre rien
+	cancelled, err := reminders.Create("user-1", testRef, "annulé", due, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := jobs.Get(cancelled.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reminders.Cancel("user-1", cancelled.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := reminders.fire(job); err != nil {
+		t.Fatalf("fire after cancel: %v", err)
+	}
+
+	// Replanifié : seul le job courant livre le rappel
+	// Échéance "maintenant" (tolérée par Create) : l'occurrence suivante est demain
+	now := time.Now().Add(-time.Second).Format(time.RFC3339)
+	recurring, err := reminders.Create("user-1", testRef, "quotidien", now, "daily", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, _ := jobs.Get(recurring.JobID)
+	reminders.mu.Lock()
+	err = reminders.schedule(recurring)
+	reminders.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reminders.fire(stale); err != nil {
+		t.Fatalf("fire stale job: %v", err)
+	}
+	if texts := notifier.texts(); len(texts) != 0 {
+		t.Fatalf("cancelled or rescheduled reminder delivered: %v", texts)
+	}
+	if list := reminders.List("user-1"); len(list) != 1 || list[0].JobID != recurring.JobID {
+		t.Fatalf("stale job changed the reminder: %+v", list)
+	}
+
+	// Job courant : livraison puis occurrence suivante
+	current, _ := jobs.Get(recurring.JobID)
+	if err := reminders.fire(current); err != nil {
+		t.Fatal(err)
+	}
+	if texts := notifier.texts(); len(texts) != 1 || texts[0] != "⏰ Rappel : quotidien" {
+		t.Fatalf("delivered %v", texts)
+	}
+	list := reminders.List("user-1")
+	if len(list) != 1 || list[0].JobID == recurring.JobID || !list[0].DueAt.Equal(recurring.DueAt.AddDate(0, 0, 1)) {
+		t.Fatalf("recurring reminder not rescheduled: %+v", list)
+	}
+
+	// Le même job rejoué (livraison au moins une fois) ne livre pas deux fois
+	if err := reminders.fire(current); err != nil {
+		t.Fatal(err)
+	}
+	if texts := notifier.texts(); len(texts) != 1 {
+		t.Fatalf("replayed job delivered again: %v", texts)
+	}
+}
+
+func TestReminderFireDeletesOneShot(t *testing.T) {
+	reminders, jobs, notifier := newReminderTest(t)
+	reminder, err := reminders.Create("user-1", testRef, "appeler Paul", time.Now().Add(time.Hour).Format(time.RFC3339), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, _ := jobs.Get(reminder.JobID)
+	if err := reminders.fire(job); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.texts()) != 1 || len(reminders.List("user-1")) != 0 {
+		t.Fatalf("delivered %v, remaining %v", notifier.texts(), reminders.List("user-1"))
+	}
+}
@@ -0,0 +1,42 @@
+package services
+
+import "strings"
+
+// TenantPolicy - Tenants pour lesquels NEO accepte d'opérer
+//
+// Sans ALLOWED_TENANTS, tout tenant non refusé est accepté ; le tenant
+// principal (TENANT_ID) est toujours autorisé sauf refus explicite.
+type TenantPolicy struct {
+	home    string
+	allowed map[string]bool
+	denied  map[string]bool
+}
+
+func NewTenantPolicy(homeTenant, allowed, denied string) *TenantPolicy {
+	return &TenantPolicy{
+		home:    strings.ToLower(homeTenant),
+		allowed: parseTenantList(allowed),
+		denied:  parseTenantList(denied),
+	}
+}
+
+func (p *TenantPolicy) Allowed(tenantID string) bool {
+	tenantID = strings.ToLower(tenantID)
+	if tenantID == "" || p.denied[tenantID] {
+		return false
+	}
+	if len(p.allowed) == 0 || tenantID == p.home {
+		return true
+	}
+	return p.allowed[tenantID]
+}
+
+func parseTenantList(list string) map[string]bool {
+	tenants := make(map[string]bool)
+	for _, tenantID := range strings.Split(list, ",") {
+		if tenantID = strings.ToLower(strings.TrimSpace(tenantID)); tenantID != "" {
+			tenants[tenantID] = true
+		}
+	}
+	return tenants
+}
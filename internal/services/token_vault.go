@@ -0,0 +1,223 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"microsoft_connector/config"
+	"microsoft_connector/internal/storage"
+)
+
+const (
+	vaultKeyPrefix = "tokens/"
+	// Durée de vie par défaut d'un refresh token AAD
+	refreshTokenLifetime = 90 * 24 * time.Hour
+)
+
+// ErrVaultTokenExpired - Jeton d'accès déjà expiré et sans refresh token : rien à conserver
+var ErrVaultTokenExpired = errors.New("vault: access token already expired")
+
+// VaultToken - Jetons délégués d'un utilisateur
+type VaultToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// sealedToken - Chiffrement d'enveloppe : chaque entrée a sa propre clé de données,
+// elle-même chiffrée par la clé maître (AES-256-GCM)
+type sealedToken struct {
+	WrappedKey []byte `json:"wrapped_key"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// TokenVault - Stockage chiffré des jetons délégués, indexé par tenant et utilisateur
+type TokenVault struct {
+	store     storage.Store
+	masterKey cipher.AEAD
+}
+
+func NewTokenVault(store storage.Store, masterKey []byte) (*TokenVault, error) {
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault master key: %w", err)
+	}
+	return &TokenVault{store: store, masterKey: aead}, nil
+}
+
+// LoadVaultKey - Clé maître (32 octets, base64) depuis TOKEN_VAULT_KEY ou TOKEN_VAULT_KEY_FILE
+func LoadVaultKey(cfg *config.Config) ([]byte, error) {
+	encoded := cfg.TokenVaultKey
+	if encoded == "" && cfg.TokenVaultKeyFile != "" {
+		data, err := os.ReadFile(cfg.TokenVaultKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault key file: %w", err)
+		}
+		encoded = string(data)
+	}
+
+	if encoded == "" {
+		// Avec un stockage persistant, les entrées chiffrées avec une clé éphémère
+		// deviendraient illisibles au redémarrage
+		if cfg.StorageBackend != "" && cfg.StorageBackend != "memory" {
+			return nil, fmt.Errorf("TOKEN_VAULT_KEY or TOKEN_VAULT_KEY_FILE is required with STORAGE_BACKEND=%s", cfg.StorageBackend)
+		}
+		log.Printf("⚠️  Aucune clé de coffre configurée (TOKEN_VAULT_KEY), clé éphémère générée : les jetons ne survivront pas au redémarrage")
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("vault key must be base64 encoded: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("vault key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+func vaultKey(tenantID, userID string) string {
+	return vaultKeyPrefix + tenantID + "/" + userID
+}
+
+// Get - Une entrée illisible (clé maître changée, entrée altérée) est supprimée :
+// l'utilisateur doit se reconnecter
+func (v *TokenVault) Get(tenantID, userID string) (*VaultToken, error) {
+	key := vaultKey(tenantID, userID)
+
+	data, err := v.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := v.open(key, data)
+	if err != nil {
+		log.Printf("⚠️ [Vault] Entrée de %s illisible, supprimée: %v", userID, err)
+		if err := v.store.Delete(key); err != nil {
+			log.Printf("⚠️ [Vault] Suppression de %s impossible: %v", key, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUserSignInRequired, err)
+	}
+	return token, nil
+}
+
+func (v *TokenVault) open(key string, data []byte) (*VaultToken, error) {
+	var sealed sealedToken
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, fmt.Errorf("corrupted vault entry: %w", err)
+	}
+
+	dataKey, err := vaultOpen(v.masterKey, sealed.WrappedKey, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	plaintext, err := vaultOpen(aead, sealed.Ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt vault entry: %w", err)
+	}
+
+	var token VaultToken
+	if err := json.Unmarshal(plaintext, &token); err != nil {
+		return nil, fmt.Errorf("corrupted vault entry: %w", err)
+	}
+	return &token, nil
+}
+
+// Put - Sans refresh token, l'entrée expire avec le jeton d'accès ; un jeton déjà
+// expiré (ou sans date d'expiration) est refusé : un TTL nul ou négatif vaut
+// "sans expiration" pour le stockage
+func (v *TokenVault) Put(tenantID, userID string, token *VaultToken) error {
+	ttl := refreshTokenLifetime
+	if token.RefreshToken == "" {
+		ttl = time.Until(token.ExpiresAt)
+		if token.ExpiresAt.IsZero() || ttl <= 0 {
+			return ErrVaultTokenExpired
+		}
+	}
+	key := vaultKey(tenantID, userID)
+
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	// La clé de l'entrée sert de données associées : une entrée copiée sous
+	// une autre clé ne se déchiffre pas
+	sealed := sealedToken{Ciphertext: vaultSeal(aead, plaintext, []byte(key))}
+	sealed.WrappedKey = vaultSeal(v.masterKey, dataKey, []byte(key))
+
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+
+	return v.store.Set(key, data, ttl)
+}
+
+// Revoke - Supprime les jetons d'un utilisateur (déconnexion)
+func (v *TokenVault) Revoke(tenantID, userID string) error {
+	return v.store.Delete(vaultKey(tenantID, userID))
+}
+
+// Purge - Supprime les jetons d'un tenant, ou de tous les tenants si tenantID est vide
+func (v *TokenVault) Purge(tenantID string) (int, error) {
+	prefix := vaultKeyPrefix
+	if tenantID != "" {
+		prefix += tenantID + "/"
+	}
+	return v.store.DeletePrefix(prefix)
+}
+
+// Has - Indique si des jetons sont stockés pour l'utilisateur
+func (v *TokenVault) Has(tenantID, userID string) bool {
+	_, err := v.store.Get(vaultKey(tenantID, userID))
+	return err == nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func vaultSeal(aead cipher.AEAD, plaintext, additionalData []byte) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return aead.Seal(nonce, nonce, plaintext, additionalData)
+}
+
+func vaultOpen(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
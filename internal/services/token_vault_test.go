@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"microsoft_connector/config"
+	"microsoft_connector/internal/storage"
+)
+
+func TestLoadVaultKey(t *testing.T) {
+	for _, backend := range []string{"file", "sqlite", "redis"} {
+		if _, err := LoadVaultKey(&config.Config{StorageBackend: backend}); err == nil {
+			t.Errorf("%s: ephemeral key accepted with a persistent backend", backend)
+		}
+	}
+	if key, err := LoadVaultKey(&config.Config{StorageBackend: "memory"}); err != nil || len(key) != 32 {
+		t.Fatalf("memory backend: %d bytes, %v", len(key), err)
+	}
+
+	want := bytes.Repeat([]byte{7}, 32)
+	key, err := LoadVaultKey(&config.Config{StorageBackend: "sqlite", TokenVaultKey: base64.StdEncoding.EncodeToString(want)})
+	if err != nil || !bytes.Equal(key, want) {
+		t.Fatalf("configured key: %v", err)
+	}
+	if _, err := LoadVaultKey(&config.Config{TokenVaultKey: base64.StdEncoding.EncodeToString(want[:16])}); err == nil {
+		t.Fatal("16-byte key accepted")
+	}
+}
+
+func TestTokenVaultRoundTrip(t *testing.T) {
+	vault, _ := NewTokenVault(storage.NewMemoryStore(), make([]byte, 32))
+	token := &VaultToken{AccessToken: "at", RefreshToken: "rt", ExpiresAt: time.Now().Add(time.Hour).Round(0)}
+
+	if err := vault.Put("tenant-1", "user-1", token); err != nil {
+		t.Fatal(err)
+	}
+	got, err := vault.Get("tenant-1", "user-1")
+	if err != nil || got.AccessToken != "at" || got.RefreshToken != "rt" || !got.ExpiresAt.Equal(token.ExpiresAt) {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	if _, err := vault.Get("tenant-1", "user-2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("missing entry: %v", err)
+	}
+}
+
+func TestTokenVaultUnreadableEntry(t *testing.T) {
+	store := storage.NewMemoryStore()
+	old, _ := NewTokenVault(store, bytes.Repeat([]byte{1}, 32))
+	old.Put("tenant-1", "user-1", &VaultToken{AccessToken: "at", RefreshToken: "rt"})
+
+	// Clé maître changée : l'entrée est supprimée et l'utilisateur doit se reconnecter
+	rotated, _ := NewTokenVault(store, bytes.Repeat([]byte{2}, 32))
+	if _, err := rotated.Get("tenant-1", "user-1"); !errors.Is(err, ErrUserSignInRequired) {
+		t.Fatalf("expected ErrUserSignInRequired, got %v", err)
+	}
+	if rotated.Has("tenant-1", "user-1") {
+		t.Fatal("unreadable entry not deleted")
+	}
+
+	// Entrée altérée ou copiée sous une autre clé
+	store.Set(vaultKeyPrefix+"tenant-1/user-2", []byte("not json"), 0)
+	if _, err := rotated.Get("tenant-1", "user-2"); !errors.Is(err, ErrUserSignInRequired) {
+		t.Fatalf("corrupted entry: %v", err)
+	}
+	rotated.Put("tenant-1", "user-3", &VaultToken{AccessToken: "at", RefreshToken: "rt"})
+	data, _ := store.Get(vaultKeyPrefix + "tenant-1/user-3")
+	store.Set(vaultKeyPrefix+"tenant-1/user-4", data, 0)
+	if _, err := rotated.Get("tenant-1", "user-4"); !errors.Is(err, ErrUserSignInRequired) {
+		t.Fatalf("entry moved to another user: %v", err)
+	}
+	if _, err := rotated.Get("tenant-1", "user-3"); err != nil {
+		t.Fatalf("original entry: %v", err)
+	}
+}
+
+func TestTokenVaultExpiredAccessToken(t *testing.T) {
+	store := storage.NewMemoryStore()
+	vault, _ := NewTokenVault(store, make([]byte, 32))
+
+	// Un TTL nul ou négatif vaudrait "sans expiration" : le jeton est refusé
+	for _, expiresAt := range []time.Time{{}, time.Now().Add(-time.Minute)} {
+		if err := vault.Put("tenant-1", "user-1", &VaultToken{AccessToken: "at", ExpiresAt: expiresAt}); !errors.Is(err, ErrVaultTokenExpired) {
+			t.Fatalf("expires %v: %v", expiresAt, err)
+		}
+	}
+	if vault.Has("tenant-1", "user-1") {
+		t.Fatal("expired token stored")
+	}
+
+	if err := vault.Put("tenant-1", "user-1", &VaultToken{AccessToken: "at", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if !vault.Has("tenant-1", "user-1") {
+		t.Fatal("valid access token not stored")
+	}
+}
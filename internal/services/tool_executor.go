@@ -2,15 +2,77 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"net/url"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
-type ToolExecutor struct{}
+// Les messages sont volumineux : on en remonte moins que pour les autres listes
+const mailPageLimit = 50
 
+// Attente dans le tour de conversation avant de basculer sur une notification proactive
+const operationInlineWait = 15 * time.Second
+
+type ToolExecutor struct {
+	caller      *CallerIdentity
+	delegation  *DelegationPolicy
+	permissions *PermissionChecker
+	operations  *OperationTracker
+	profiles    *ProfileStore
+	idempotency *IdempotencyStore
+	reminders   *ReminderService
+}
+
+func NewToolExecutor(caller *CallerIdentity, delegation *DelegationPolicy, permissions *PermissionChecker, operations *OperationTracker, profiles *ProfileStore, idempotency *IdempotencyStore, reminders *ReminderService) *ToolExecutor {
+	return &ToolExecutor{
+		caller:      caller,
+		delegation:  delegation,
+		permissions: permissions,
+		operations:  operations,
+		profiles:    profiles,
+		idempotency: idempotency,
+		reminders:   reminders,
+	}
+}
+
+// Execute - Les outils à effet de bord ne sont exécutés qu'une fois par activité,
+// même si Teams relivre le message ou si le modèle répète l'appel ; seuls les
+// succès sont mémorisés. Un outil désactivé faute de permissions n'est jamais
+// exécuté, même si le modèle l'appelle sans l'avoir reçu
 func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphService *GraphService) string {
+	tenantID := ""
+	if e.caller != nil {
+		tenantID = e.caller.TenantID
+	}
+	if missing := e.permissions.Missing(tenantID, toolName); len(missing) > 0 {
+		log.Printf("⚠️ Tool %s refused for tenant %s: missing %s", toolName, tenantID, strings.Join(missing, ", "))
+		return disabledToolMessage(toolName, missing)
+	}
+
+	run := func() (string, error) {
+		return e.execute(toolName, input, graphService)
+	}
+
+	var (
+		text string
+		err  error
+	)
+	if toolSideEffects(toolName) && e.caller != nil {
+		text, err = e.idempotency.Once(e.caller.ActivityKey, toolName, input, run)
+	} else {
+		text, err = run()
+	}
+	if err != nil {
+		return toolErrorMessage(toolName, err)
+	}
+	return text
+}
+
+func (e *ToolExecutor) execute(toolName string, input json.RawMessage, graphService *GraphService) (string, error) {
 	var result map[string]any
 	var err error
 
@@ -24,20 +86,22 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			UserID string `json:"user_id"`
 		}
 		json.Unmarshal(input, &params)
-		if params.UserID == "" {
-			return "Erreur: user_id requis"
+		userID, bindErr := e.bindUser(params.UserID, graphService)
+		if bindErr != nil {
+			return "", bindErr
 		}
-		result, err = graphService.Get("/users/" + params.UserID + "/events?$select=subject,start,end,location&$orderby=start/dateTime&$top=10")
+		result, err = graphService.Get("/users/" + PathSegment(userID) + "/events" + NewQuery().Select("subject", "start", "end", "location").OrderBy("start/dateTime").Top(10).String())
 
 	case "get_calendars":
 		var params struct {
 			UserID string `json:"user_id"`
 		}
 		json.Unmarshal(input, &params)
-		if params.UserID == "" {
-			return "Erreur: user_id requis"
+		userID, bindErr := e.bindUser(params.UserID, graphService)
+		if bindErr != nil {
+			return "", bindErr
 		}
-		result, err = graphService.Get("/users/" + params.UserID + "/calendars")
+		result, err = pagedResponse(graphService.GetAll("/users/"+PathSegment(userID)+"/calendars", PageOptions{}))
 
 	case "create_meeting":
 		var params struct {
@@ -48,8 +112,12 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			Attendees []string `json:"attendees"`
 		}
 		json.Unmarshal(input, &params)
-		if params.UserID == "" || params.Subject == "" || params.StartTime == "" || params.EndTime == "" {
-			return "Erreur: user_id, subject, start_time et end_time requis"
+		if params.Subject == "" || params.StartTime == "" || params.EndTime == "" {
+			return "", errors.New("subject, start_time et end_time requis")
+		}
+		userID, bindErr := e.bindUser(params.UserID, graphService)
+		if bindErr != nil {
+			return "", bindErr
 		}
 
 		attendeesList := []map[string]any{}
@@ -60,21 +128,26 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			})
 		}
 
+		// Les heures données par l'utilisateur sont dans son fuseau
+		timeZone := e.location().String()
 		body := map[string]any{
 			"subject": params.Subject,
 			"start": map[string]string{
 				"dateTime": params.StartTime,
-				"timeZone": "Europe/Paris",
+				"timeZone": timeZone,
 			},
 			"end": map[string]string{
 				"dateTime": params.EndTime,
-				"timeZone": "Europe/Paris",
+				"timeZone": timeZone,
 			},
 			"attendees":             attendeesList,
 			"isOnlineMeeting":       true,
 			"onlineMeetingProvider": "teamsForBusiness",
 		}
-		result, err = graphService.Post("/users/"+params.UserID+"/events", body)
+		result, err = graphService.Post("/users/"+PathSegment(userID)+"/events", body)
+		if err == nil {
+			e.profiles.NoteContacts(e.caller.UserID, params.Attendees...)
+		}
 
 	case "find_meeting_times":
 		var params struct {
@@ -83,7 +156,7 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if len(params.Attendees) == 0 || params.DurationMinutes == 0 {
-			return "Erreur: attendees et duration_minutes requis"
+			return "", errors.New("attendees et duration_minutes requis")
 		}
 
 		attendeesList := []map[string]any{}
@@ -94,25 +167,32 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			})
 		}
 
+		// Heures ouvrées de l'utilisateur, sur les 7 prochains jours
+		loc := e.location()
+		now := time.Now().In(loc)
 		body := map[string]any{
 			"attendees": attendeesList,
 			"timeConstraint": map[string]any{
 				"timeslots": []map[string]any{
 					{
 						"start": map[string]string{
-							"dateTime": time.Now().Format("2006-01-02T09:00:00"),
-							"timeZone": "Europe/Paris",
+							"dateTime": now.Format("2006-01-02T09:00:00"),
+							"timeZone": loc.String(),
 						},
 						"end": map[string]string{
-							"dateTime": time.Now().AddDate(0, 0, 7).Format("2006-01-02T18:00:00"),
-							"timeZone": "Europe/Paris",
+							"dateTime": now.AddDate(0, 0, 7).Format("2006-01-02T18:00:00"),
+							"timeZone": loc.String(),
 						},
 					},
 				},
 			},
 			"meetingDuration": fmt.Sprintf("PT%dM", params.DurationMinutes),
 		}
-		result, err = graphService.Post("/me/findMeetingTimes", body)
+		userGraph, bindErr := e.delegatedGraph(graphService)
+		if bindErr != nil {
+			return "", bindErr
+		}
+		result, err = userGraph.Post("/me/findMeetingTimes", body)
 
 	// === MESSAGERIE OUTLOOK ===
 	case "send_email":
@@ -123,8 +203,12 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			Body    string `json:"body"`
 		}
 		json.Unmarshal(input, &params)
-		if params.From == "" || params.To == "" || params.Subject == "" || params.Body == "" {
-			return "Erreur: from, to, subject et body requis"
+		if params.To == "" || params.Subject == "" || params.Body == "" {
+			return "", errors.New("to, subject et body requis")
+		}
+		from, bindErr := e.bindUser(params.From, graphService)
+		if bindErr != nil {
+			return "", bindErr
 		}
 
 		body := map[string]any{
@@ -139,9 +223,10 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 				},
 			},
 		}
-		result, err = graphService.Post("/users/"+params.From+"/sendMail", body)
+		result, err = graphService.Post("/users/"+PathSegment(from)+"/sendMail", body)
 		if err == nil {
-			return fmt.Sprintf("Email envoyé à %s avec succès", params.To)
+			e.profiles.NoteContacts(e.caller.UserID, params.To)
+			return fmt.Sprintf("Email envoyé à %s avec succès", params.To), nil
 		}
 
 	case "get_important_emails":
@@ -149,10 +234,11 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			UserID string `json:"user_id"`
 		}
 		json.Unmarshal(input, &params)
-		if params.UserID == "" {
-			return "Erreur: user_id requis"
+		userID, bindErr := e.bindUser(params.UserID, graphService)
+		if bindErr != nil {
+			return "", bindErr
 		}
-		result, err = graphService.Get("/users/" + params.UserID + "/messages?$search=\"importance:high\"&$top=20")
+		result, err = pagedResponse(graphService.GetAll("/users/"+PathSegment(userID)+"/messages"+NewQuery().SearchProperty("importance", "high").Top(20).String(), PageOptions{MaxItems: mailPageLimit}))
 
 	case "get_emails_from":
 		var params struct {
@@ -160,10 +246,14 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			FromEmail string `json:"from_email"`
 		}
 		json.Unmarshal(input, &params)
-		if params.UserID == "" || params.FromEmail == "" {
-			return "Erreur: user_id et from_email requis"
+		if params.FromEmail == "" {
+			return "", errors.New("from_email requis")
 		}
-		result, err = graphService.Get("/users/" + params.UserID + "/messages?$search=\"from:" + params.FromEmail + "\"&$top=20")
+		userID, bindErr := e.bindUser(params.UserID, graphService)
+		if bindErr != nil {
+			return "", bindErr
+		}
+		result, err = pagedResponse(graphService.GetAll("/users/"+PathSegment(userID)+"/messages"+NewQuery().SearchProperty("from", params.FromEmail).Top(20).String(), PageOptions{MaxItems: mailPageLimit}))
 
 	case "forward_email":
 		var params struct {
@@ -173,8 +263,12 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			Comment   string `json:"comment"`
 		}
 		json.Unmarshal(input, &params)
-		if params.UserID == "" || params.MessageID == "" || params.ToEmail == "" {
-			return "Erreur: user_id, message_id et to_email requis"
+		if params.MessageID == "" || params.ToEmail == "" {
+			return "", errors.New("message_id et to_email requis")
+		}
+		userID, bindErr := e.bindUser(params.UserID, graphService)
+		if bindErr != nil {
+			return "", bindErr
 		}
 
 		body := map[string]any{
@@ -183,10 +277,9 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 				{"emailAddress": map[string]string{"address": params.ToEmail}},
 			},
 		}
-		encodedMessageID := url.PathEscape(params.MessageID)
-		result, err = graphService.Post("/users/"+params.UserID+"/messages/"+encodedMessageID+"/forward", body)
+		result, err = graphService.Post("/users/"+PathSegment(userID)+"/messages/"+PathSegment(params.MessageID)+"/forward", body)
 		if err == nil {
-			return "Email transféré avec succès"
+			return "Email transféré avec succès", nil
 		}
 
 	case "get_email_delta":
@@ -194,14 +287,15 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			UserID string `json:"user_id"`
 		}
 		json.Unmarshal(input, &params)
-		if params.UserID == "" {
-			return "Erreur: user_id requis"
+		userID, bindErr := e.bindUser(params.UserID, graphService)
+		if bindErr != nil {
+			return "", bindErr
 		}
-		result, err = graphService.Get("/users/" + params.UserID + "/mailFolders/Inbox/messages/delta")
+		result, err = pagedResponse(graphService.GetAll("/users/"+PathSegment(userID)+"/mailFolders/Inbox/messages/delta", PageOptions{}))
 
 	// === UTILISATEURS ===
 	case "get_users":
-		result, err = graphService.Get("/users?$select=id,displayName,mail,userPrincipalName&$top=50")
+		result, err = pagedResponse(graphService.GetAll("/users"+NewQuery().Select("id", "displayName", "mail", "userPrincipalName").Top(100).String(), PageOptions{}))
 
 	case "get_user_presence":
 		var params struct {
@@ -209,9 +303,9 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.UserID == "" {
-			return "Erreur: user_id requis"
+			return "", errors.New("user_id requis")
 		}
-		result, err = graphService.Get("/users/" + params.UserID + "/presence")
+		result, err = graphService.Get("/users/" + PathSegment(params.UserID) + "/presence")
 
 	// === TEAMS ===
 	case "get_teams":
@@ -219,10 +313,11 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			UserID string `json:"user_id"`
 		}
 		json.Unmarshal(input, &params)
-		if params.UserID == "" {
-			return "Erreur: user_id requis"
+		userID, bindErr := e.bindUser(params.UserID, graphService)
+		if bindErr != nil {
+			return "", bindErr
 		}
-		result, err = graphService.Get("/users/" + params.UserID + "/joinedTeams")
+		result, err = pagedResponse(graphService.GetAll("/users/"+PathSegment(userID)+"/joinedTeams", PageOptions{}))
 
 	case "create_team":
 		var params struct {
@@ -232,7 +327,7 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.DisplayName == "" {
-			return "Erreur: display_name requis"
+			return "", errors.New("display_name requis")
 		}
 
 		visibility := "private"
@@ -241,14 +336,15 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 
 		body := map[string]any{
-			"template@odata.bind": "https://graph.microsoft.com/v1.0/teamsTemplates('standard')",
+			"template@odata.bind": graphService.baseURL + "/teamsTemplates('standard')",
 			"displayName":         params.DisplayName,
 			"description":         params.Description,
 			"visibility":          visibility,
 		}
-		result, err = graphService.Post("/teams", body)
+		var resp *GraphResponse
+		resp, err = graphService.Do("POST", "/teams", body)
 		if err == nil {
-			return fmt.Sprintf("Équipe '%s' créée avec succès", params.DisplayName)
+			return e.awaitTeamCreation(graphService, resp, params.DisplayName)
 		}
 
 	case "get_team_members":
@@ -257,9 +353,9 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.TeamID == "" {
-			return "Erreur: team_id requis"
+			return "", errors.New("team_id requis")
 		}
-		result, err = graphService.Get("/groups/" + params.TeamID + "/members")
+		result, err = pagedResponse(graphService.GetAll("/groups/"+PathSegment(params.TeamID)+"/members", PageOptions{}))
 
 	case "get_team_channels":
 		var params struct {
@@ -267,9 +363,9 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.TeamID == "" {
-			return "Erreur: team_id requis"
+			return "", errors.New("team_id requis")
 		}
-		result, err = graphService.Get("/teams/" + params.TeamID + "/channels")
+		result, err = pagedResponse(graphService.GetAll("/teams/"+PathSegment(params.TeamID)+"/channels", PageOptions{}))
 
 	case "get_channel_info":
 		var params struct {
@@ -278,9 +374,9 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.TeamID == "" || params.ChannelID == "" {
-			return "Erreur: team_id et channel_id requis"
+			return "", errors.New("team_id et channel_id requis")
 		}
-		result, err = graphService.Get("/teams/" + params.TeamID + "/channels/" + params.ChannelID)
+		result, err = graphService.Get("/teams/" + PathSegment(params.TeamID) + "/channels/" + PathSegment(params.ChannelID))
 
 	case "create_channel":
 		var params struct {
@@ -291,7 +387,7 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.TeamID == "" || params.DisplayName == "" {
-			return "Erreur: team_id et display_name requis"
+			return "", errors.New("team_id et display_name requis")
 		}
 
 		membershipType := "standard"
@@ -304,9 +400,9 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			"description":    params.Description,
 			"membershipType": membershipType,
 		}
-		result, err = graphService.Post("/teams/"+params.TeamID+"/channels", body)
+		result, err = graphService.Post("/teams/"+PathSegment(params.TeamID)+"/channels", body)
 		if err == nil {
-			return fmt.Sprintf("Canal '%s' créé avec succès", params.DisplayName)
+			return fmt.Sprintf("Canal '%s' créé avec succès", params.DisplayName), nil
 		}
 
 	case "get_team_apps":
@@ -315,9 +411,9 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.TeamID == "" {
-			return "Erreur: team_id requis"
+			return "", errors.New("team_id requis")
 		}
-		result, err = graphService.Get("/teams/" + params.TeamID + "/installedApps?$expand=teamsAppDefinition")
+		result, err = pagedResponse(graphService.GetAll("/teams/"+PathSegment(params.TeamID)+"/installedApps"+NewQuery().Expand("teamsAppDefinition").String(), PageOptions{}))
 
 	case "create_chat":
 		var params struct {
@@ -327,7 +423,7 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.ChatType == "" || len(params.Members) == 0 {
-			return "Erreur: chat_type et members requis"
+			return "", errors.New("chat_type et members requis")
 		}
 
 		membersList := []map[string]any{}
@@ -335,7 +431,7 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 			membersList = append(membersList, map[string]any{
 				"@odata.type":     "#microsoft.graph.aadUserConversationMember",
 				"roles":           []string{"owner"},
-				"user@odata.bind": fmt.Sprintf("https://graph.microsoft.com/v1.0/users('%s')", userID),
+				"user@odata.bind": graphService.baseURL + "/" + KeySegment("users", userID),
 			})
 		}
 
@@ -355,14 +451,19 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.ChatID == "" || params.Message == "" {
-			return "Erreur: chat_id et message requis"
+			return "", errors.New("chat_id et message requis")
 		}
 		body := map[string]any{
 			"body": map[string]any{
 				"content": params.Message,
 			},
 		}
-		result, err = graphService.Post("/chats/"+params.ChatID+"/messages", body)
+		// Graph n'accepte l'envoi de messages qu'avec un jeton délégué
+		userGraph, bindErr := e.delegatedGraph(graphService)
+		if bindErr != nil {
+			return "", bindErr
+		}
+		result, err = userGraph.Post("/chats/"+PathSegment(params.ChatID)+"/messages", body)
 
 	case "send_channel_message":
 		var params struct {
@@ -372,17 +473,21 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.TeamID == "" || params.ChannelID == "" || params.Message == "" {
-			return "Erreur: team_id, channel_id et message requis"
+			return "", errors.New("team_id, channel_id et message requis")
 		}
 		body := map[string]any{
 			"body": map[string]any{
 				"content": params.Message,
 			},
 		}
-		result, err = graphService.PostBeta("/teams/"+params.TeamID+"/channels/"+params.ChannelID+"/messages", body)
+		userGraph, bindErr := e.delegatedGraph(graphService)
+		if bindErr != nil {
+			return "", bindErr
+		}
+		result, err = userGraph.PostBeta("/teams/"+PathSegment(params.TeamID)+"/channels/"+PathSegment(params.ChannelID)+"/messages", body)
 
 	case "get_groups":
-		result, err = graphService.Get("/groups?$select=id,displayName,mail,groupTypes&$top=50")
+		result, err = pagedResponse(graphService.GetAll("/groups"+NewQuery().Select("id", "displayName", "mail", "groupTypes").Top(100).String(), PageOptions{}))
 
 	case "create_group":
 		var params struct {
@@ -393,7 +498,7 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.DisplayName == "" || params.MailNickname == "" {
-			return "Erreur: display_name et mail_nickname requis"
+			return "", errors.New("display_name et mail_nickname requis")
 		}
 		body := map[string]any{
 			"displayName":     params.DisplayName,
@@ -406,19 +511,28 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 
 	case "add_group_member":
 		var params struct {
-			GroupID string `json:"group_id"`
-			UserID  string `json:"user_id"`
+			GroupID string   `json:"group_id"`
+			UserID  string   `json:"user_id"`
+			UserIDs []string `json:"user_ids"`
 		}
 		json.Unmarshal(input, &params)
-		if params.GroupID == "" || params.UserID == "" {
-			return "Erreur: group_id et user_id requis"
+		if params.UserID != "" {
+			params.UserIDs = append(params.UserIDs, params.UserID)
+		}
+		if params.GroupID == "" || len(params.UserIDs) == 0 {
+			return "", errors.New("group_id et user_id (ou user_ids) requis")
 		}
+		if len(params.UserIDs) > 1 {
+			result, err = addGroupMembers(graphService, params.GroupID, params.UserIDs)
+			break
+		}
+		params.UserID = params.UserIDs[0]
 		body := map[string]any{
-			"@odata.id": fmt.Sprintf("https://graph.microsoft.com/v1.0/directoryObjects/%s", params.UserID),
+			"@odata.id": graphService.baseURL + "/directoryObjects/" + PathSegment(params.UserID),
 		}
-		result, err = graphService.Post("/groups/"+params.GroupID+"/members/$ref", body)
+		result, err = graphService.Post("/groups/"+PathSegment(params.GroupID)+"/members/$ref", body)
 		if err == nil {
-			return `{"status": "member added"}`
+			return `{"status": "member added"}`, nil
 		}
 
 	case "remove_group_member":
@@ -428,11 +542,11 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.GroupID == "" || params.UserID == "" {
-			return "Erreur: group_id et user_id requis"
+			return "", errors.New("group_id et user_id requis")
 		}
-		err = graphService.Delete("/groups/" + params.GroupID + "/members/" + params.UserID + "/$ref")
+		err = graphService.Delete("/groups/" + PathSegment(params.GroupID) + "/members/" + PathSegment(params.UserID) + "/$ref")
 		if err == nil {
-			return `{"status": "member removed"}`
+			return `{"status": "member removed"}`, nil
 		}
 
 	case "delete_group":
@@ -441,15 +555,19 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.GroupID == "" {
-			return "Erreur: group_id requis"
+			return "", errors.New("group_id requis")
 		}
-		err = graphService.Delete("/groups/" + params.GroupID)
+		err = graphService.Delete("/groups/" + PathSegment(params.GroupID))
 		if err == nil {
-			return `{"status": "group deleted"}`
+			return `{"status": "group deleted"}`, nil
 		}
 
 	case "get_my_groups":
-		result, err = graphService.Get("/me/memberOf")
+		userGraph, bindErr := e.delegatedGraph(graphService)
+		if bindErr != nil {
+			return "", bindErr
+		}
+		result, err = pagedResponse(userGraph.GetAll("/me/memberOf", PageOptions{}))
 
 	case "get_group_conversations":
 		var params struct {
@@ -457,9 +575,9 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.GroupID == "" {
-			return "Erreur: group_id requis"
+			return "", errors.New("group_id requis")
 		}
-		result, err = graphService.Get("/groups/" + params.GroupID + "/conversations")
+		result, err = pagedResponse(graphService.GetAll("/groups/"+PathSegment(params.GroupID)+"/conversations", PageOptions{}))
 
 	case "get_group_events":
 		var params struct {
@@ -467,9 +585,9 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.GroupID == "" {
-			return "Erreur: group_id requis"
+			return "", errors.New("group_id requis")
 		}
-		result, err = graphService.Get("/groups/" + params.GroupID + "/events")
+		result, err = pagedResponse(graphService.GetAll("/groups/"+PathSegment(params.GroupID)+"/events", PageOptions{}))
 
 	// === TEAMS BETA ===
 	case "get_channel_messages":
@@ -479,9 +597,9 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.TeamID == "" || params.ChannelID == "" {
-			return "Erreur: team_id et channel_id requis"
+			return "", errors.New("team_id et channel_id requis")
 		}
-		result, err = graphService.GetBeta("/teams/" + params.TeamID + "/channels/" + params.ChannelID + "/messages")
+		result, err = pagedResponse(graphService.GetAllBeta("/teams/"+PathSegment(params.TeamID)+"/channels/"+PathSegment(params.ChannelID)+"/messages", PageOptions{}))
 
 	case "get_message_replies":
 		var params struct {
@@ -491,19 +609,20 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.TeamID == "" || params.ChannelID == "" || params.MessageID == "" {
-			return "Erreur: team_id, channel_id et message_id requis"
+			return "", errors.New("team_id, channel_id et message_id requis")
 		}
-		result, err = graphService.GetBeta("/teams/" + params.TeamID + "/channels/" + params.ChannelID + "/messages/" + params.MessageID + "/replies")
+		result, err = pagedResponse(graphService.GetAllBeta("/teams/"+PathSegment(params.TeamID)+"/channels/"+PathSegment(params.ChannelID)+"/messages/"+PathSegment(params.MessageID)+"/replies", PageOptions{}))
 
 	case "get_installed_apps":
 		var params struct {
 			UserID string `json:"user_id"`
 		}
 		json.Unmarshal(input, &params)
-		if params.UserID == "" {
-			return "Erreur: user_id requis"
+		userID, bindErr := e.bindUser(params.UserID, graphService)
+		if bindErr != nil {
+			return "", bindErr
 		}
-		result, err = graphService.Get("/users/" + params.UserID + "/teamwork/installedApps?$expand=teamsAppDefinition")
+		result, err = pagedResponse(graphService.GetAll("/users/"+PathSegment(userID)+"/teamwork/installedApps"+NewQuery().Expand("teamsAppDefinition").String(), PageOptions{}))
 
 	case "get_chat_members":
 		var params struct {
@@ -511,18 +630,145 @@ func (e *ToolExecutor) Execute(toolName string, input json.RawMessage, graphServ
 		}
 		json.Unmarshal(input, &params)
 		if params.ChatID == "" {
-			return "Erreur: chat_id requis"
+			return "", errors.New("chat_id requis")
 		}
-		result, err = graphService.Get("/chats/" + params.ChatID + "/members")
+		result, err = pagedResponse(graphService.GetAll("/chats/"+PathSegment(params.ChatID)+"/members", PageOptions{}))
+
+	// === MÉMOIRE ===
+	case "remember", "recall", "forget":
+		return e.memoryTool(toolName, input)
+
+	// === RAPPELS ===
+	case "create_reminder", "list_reminders", "cancel_reminder":
+		return e.reminderTool(toolName, input, graphService)
 
 	default:
-		return fmt.Sprintf("Outil inconnu: %s", toolName)
+		return "", fmt.Errorf("outil inconnu: %s", toolName)
 	}
 
 	if err != nil {
-		return fmt.Sprintf("Erreur: %s", err.Error())
+		return "", err
 	}
 
 	jsonResult, _ := json.Marshal(result)
-	return string(jsonResult)
+	return string(jsonResult), nil
+}
+
+// addGroupMembers - Un ajout par utilisateur, regroupés en appels $batch ; un
+// échec individuel (déjà membre, ID inconnu) n'empêche pas les autres ajouts
+func addGroupMembers(graphService *GraphService, groupID string, userIDs []string) (map[string]any, error) {
+	requests := make([]BatchRequest, len(userIDs))
+	for i, userID := range userIDs {
+		requests[i] = BatchRequest{
+			ID:     strconv.Itoa(i + 1),
+			Method: "POST",
+			URL:    "/groups/" + PathSegment(groupID) + "/members/$ref",
+			Body:   map[string]any{"@odata.id": graphService.baseURL + "/directoryObjects/" + PathSegment(userID)},
+		}
+	}
+
+	responses, err := graphService.Batch(requests)
+	if err != nil && len(responses) == 0 {
+		return nil, err
+	}
+
+	added := []string{}
+	failed := []map[string]any{}
+	var firstErr error
+	for i, req := range requests {
+		resp := responses[req.ID]
+		if resp == nil {
+			resp = &BatchResponse{Err: fmt.Errorf("batch interrompu: %w", err)}
+		}
+		if resp.Err == nil {
+			added = append(added, userIDs[i])
+			continue
+		}
+		if firstErr == nil {
+			firstErr = resp.Err
+		}
+		failed = append(failed, map[string]any{"user_id": userIDs[i], "error": resp.Err.Error()})
+	}
+	// Aucun ajout : l'appel peut être retenté tel quel
+	if len(added) == 0 {
+		return nil, firstErr
+	}
+	return map[string]any{"status": "members added", "added": added, "failed": failed}, nil
+}
+
+// toolFailure - Échec dont le texte est présenté tel quel au modèle
+type toolFailure string
+
+func (f toolFailure) Error() string { return string(f) }
+
+// toolErrorMessage - Texte présenté au modèle quand un outil échoue
+func toolErrorMessage(toolName string, err error) string {
+	var failure toolFailure
+	if errors.As(err, &failure) {
+		return string(failure)
+	}
+	if errors.Is(err, ErrUserSignInRequired) {
+		return signInRequiredMessage
+	}
+	if errors.Is(err, ErrGraphUnavailable) {
+		return "Erreur: Microsoft Graph est momentanément indisponible. Préviens l'utilisateur et propose de réessayer dans quelques instants."
+	}
+	var graphErr *GraphError
+	if errors.As(err, &graphErr) && graphErr.Status == http.StatusForbidden {
+		return permissionDeniedMessage(toolName, graphErr)
+	}
+	return "Erreur: " + err.Error()
+}
+
+// location - Fuseau de l'appelant (profil, sinon fuseau par défaut) ; Graph
+// n'accepte que des noms IANA ou Windows, pas "Local"
+func (e *ToolExecutor) location() *time.Location {
+	userID := ""
+	if e.caller != nil {
+		userID = e.caller.UserID
+	}
+	if loc := e.profiles.Location(userID); loc != time.Local {
+		return loc
+	}
+	return time.UTC
+}
+
+// awaitTeamCreation - POST /teams répond 202 : l'équipe n'existe qu'une fois
+// l'opération teamsAsyncOperation terminée
+func (e *ToolExecutor) awaitTeamCreation(graphService *GraphService, resp *GraphResponse, displayName string) (string, error) {
+	location := resp.Headers.Get("Location")
+	if resp.Status != http.StatusAccepted || location == "" || e.operations == nil {
+		return fmt.Sprintf("Équipe '%s' créée avec succès", displayName), nil
+	}
+
+	var conversation *ConversationReference
+	if e.caller != nil {
+		conversation = e.caller.Conversation
+	}
+
+	followUp := OperationFollowUp{Kind: "team", Name: displayName, Conversation: conversation}
+	result, done := e.operations.Track(graphService, location, operationInlineWait, followUp)
+	if !done {
+		return fmt.Sprintf("La création de l'équipe '%s' est en cours côté Microsoft. L'utilisateur recevra un message dès qu'elle sera prête.", displayName), nil
+	}
+	// Une création échouée peut être retentée
+	if result.Status == "failed" {
+		return "", toolFailure(teamCreationMessage(displayName, result))
+	}
+	return teamCreationMessage(displayName, result), nil
+}
+
+func teamCreationMessage(displayName string, result OperationResult) string {
+	switch result.Status {
+	case "succeeded":
+		msg := fmt.Sprintf("✅ L'équipe '%s' est prête", displayName)
+		if result.TargetResourceID != "" {
+			msg += fmt.Sprintf(" (ID : %s)", result.TargetResourceID)
+		}
+		return msg + "."
+	case "timeout":
+		return fmt.Sprintf("⏳ La création de l'équipe '%s' prend plus de temps que prévu, vérifie dans Teams d'ici quelques minutes.", displayName)
+	default:
+		return fmt.Sprintf("❌ La création de l'équipe '%s' a échoué : %v", displayName, result.Err)
+	}
 }
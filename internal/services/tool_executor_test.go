@@ -0,0 +1,49 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"microsoft_connector/internal/storage"
+)
+
+// Les heures d'une réunion sont interprétées dans le fuseau de l'appelant
+func TestCreateMeetingUsesCallerTimeZone(t *testing.T) {
+	var event struct {
+		Start map[string]string `json:"start"`
+		End   map[string]string `json:"end"`
+	}
+	graph := newTestGraphService(t, GraphOptions{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&event)
+		json.NewEncoder(w).Encode(map[string]any{"id": "event-1"})
+	}))
+
+	profiles := NewProfileStore(storage.NewMemoryStore())
+	if err := profiles.SetDefaultTimeZone("Europe/Paris"); err != nil {
+		t.Skipf("tzdata indisponible: %v", err)
+	}
+	profiles.Update("user-1", func(profile *UserProfile) error {
+		profile.TimeZone = "America/New_York"
+		return nil
+	})
+
+	input := json.RawMessage(`{"subject":"Point","start_time":"2026-11-02T10:00:00","end_time":"2026-11-02T10:30:00"}`)
+	for userID, want := range map[string]string{"user-1": "America/New_York", "user-2": "Europe/Paris"} {
+		executor := NewToolExecutor(&CallerIdentity{UserID: userID, TenantID: "tenant-1"}, NewDelegationPolicy(""), nil, nil, profiles, nil, nil)
+		if result := executor.Execute("create_meeting", input, graph); strings.HasPrefix(result, "Erreur") {
+			t.Fatalf("create_meeting: %q", result)
+		}
+		if event.Start["timeZone"] != want || event.End["timeZone"] != want {
+			t.Errorf("%s: start %v, end %v, want %s", userID, event.Start, event.End, want)
+		}
+	}
+
+	// Sans profil ni fuseau par défaut, "Local" n'est pas un nom accepté par Graph
+	executor := NewToolExecutor(&CallerIdentity{UserID: "user-1"}, nil, nil, nil, nil, nil, nil)
+	if loc := executor.location(); loc != time.UTC {
+		t.Fatalf("location() = %s", loc)
+	}
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"microsoft_connector/internal/storage"
+)
+
+const (
+	// Pièces jointes Graph : au-delà, sendMail exige une session d'upload
+	exportMailMaxSize = 3 * 1024 * 1024
+
+	// Demande d'effacement en attente de confirmation
+	erasePendingPrefix = "erase-pending/"
+	erasePendingTTL    = 5 * time.Minute
+)
+
+// ConversationExport - Historique exporté (Markdown ou JSON)
+type ConversationExport struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// ErasureReport - Résultat d'un effacement RGPD
+type ErasureReport struct {
+	UserID        string    `json:"user_id"`
+	TenantID      string    `json:"tenant_id"`
+	Conversations int       `json:"conversations"`
+	Shared        int       `json:"shared_conversations"` // conversations de groupe : seuls ses tours sont retirés
+	Calls         int       `json:"calls"`
+	Reminders     int       `json:"reminders"`
+	Profile       bool      `json:"profile"`
+	Tokens        bool      `json:"tokens"`
+	ErasedAt      time.Time `json:"erased_at"`
+}
+
+// UserDataService - Réinitialisation, export et effacement des données d'un utilisateur
+type UserDataService struct {
+	store         storage.Store
+	conversations *ConversationStore
+	profiles      *ProfileStore
+	reminders     *ReminderService
+	auth          *AuthService
+}
+
+func NewUserDataService(store storage.Store, conversations *ConversationStore, profiles *ProfileStore, reminders *ReminderService, auth *AuthService) *UserDataService {
+	return &UserDataService{
+		store:         store,
+		conversations: conversations,
+		profiles:      profiles,
+		reminders:     reminders,
+		auth:          auth,
+	}
+}
+
+// Reset - Oublie l'historique et le résumé d'une conversation
+func (d *UserDataService) Reset(conversationID string) error {
+	return d.conversations.Clear(conversationID)
+}
+
+// Export - Historique d'une conversation au format markdown ou json. Si userID est
+// renseigné et que la conversation est partagée, seuls ses tours sont exportés,
+// sans le résumé qui mêle ceux des autres participants
+func (d *UserDataService) Export(conversationID, userID, format string) (*ConversationExport, error) {
+	history := d.conversations.GetHistory(conversationID)
+	summary := d.conversations.Summary(conversationID)
+	if userID != "" {
+		shared, err := d.conversations.Shared(conversationID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read participants: %w", err)
+		}
+		if shared {
+			history = authoredBy(history, userID)
+			summary = ""
+		}
+	}
+	if len(history) == 0 && summary == "" {
+		return nil, fmt.Errorf("aucun historique pour cette conversation")
+	}
+
+	now := time.Now()
+	base := "conversation-neo-" + now.Format("2006-01-02-150405")
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(map[string]any{
+			"conversation_id": conversationID,
+			"exported_at":     now,
+			"summary":         summary,
+			"messages":        history,
+		}, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal export: %w", err)
+		}
+		return &ConversationExport{FileName: base + ".json", ContentType: "application/json", Data: data}, nil
+
+	case "", "markdown", "md":
+		var md strings.Builder
+		fmt.Fprintf(&md, "# Conversation avec NEO\n\n_Exportée le %s_\n\n", now.Format("02/01/2006 à 15:04"))
+		if summary != "" {
+			md.WriteString("## Résumé des échanges précédents\n\n" + summary + "\n\n")
+		}
+		md.WriteString("## Messages\n\n")
+		for _, msg := range history {
+			md.WriteString(markdownMessage(msg))
+		}
+		return &ConversationExport{FileName: base + ".md", ContentType: "text/markdown; charset=utf-8", Data: []byte(md.String())}, nil
+
+	default:
+		return nil, fmt.Errorf("format d'export inconnu %q (markdown ou json)", format)
+	}
+}
+
+func authoredBy(history []ConversationMessage, userID string) []ConversationMessage {
+	own := []ConversationMessage{}
+	for _, msg := range history {
+		if msg.Author == userID {
+			own = append(own, msg)
+		}
+	}
+	return own
+}
+
+// SendToOneDrive - Dépose l'export dans le OneDrive de l'utilisateur et retourne son lien
+func (d *UserDataService) SendToOneDrive(graphService *GraphService, userID string, export *ConversationExport) (string, error) {
+	path := "/users/" + PathSegment(userID) + "/drive/root:/NEO/" + PathSegment(export.FileName)
+
+	var item map[string]any
+	var err error
+	if len(export.Data) <= simpleUploadMaxSize {
+		item, err = graphService.PutContent(path+":/content", export.ContentType, export.Data)
+	} else {
+		item, err = graphService.UploadLarge(path+":/createUploadSession", map[string]any{
+			"item": map[string]any{"@microsoft.graph.conflictBehavior": "rename"},
+		}, bytes.NewReader(export.Data), int64(len(export.Data)))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	webURL, _ := item["webUrl"].(string)
+	return webURL, nil
+}
+
+// SendByEmail - Envoie l'export en pièce jointe à l'utilisateur, depuis sa boîte
+func (d *UserDataService) SendByEmail(graphService *GraphService, userID string, export *ConversationExport) (string, error) {
+	if len(export.Data) > exportMailMaxSize {
+		return "", fmt.Errorf("export trop volumineux pour un email (%d octets), utilisez OneDrive", len(export.Data))
+	}
+
+	user, err := graphService.Get("/users/" + PathSegment(userID) + NewQuery().Select("mail", "userPrincipalName").String())
+	if err != nil {
+		return "", err
+	}
+	address, _ := user["mail"].(string)
+	if address == "" {
+		address, _ = user["userPrincipalName"].(string)
+	}
+	if address == "" {
+		return "", fmt.Errorf("adresse email de l'utilisateur introuvable")
+	}
+
+	body := map[string]any{
+		"message": map[string]any{
+			"subject": "Export de votre conversation avec NEO",
+			"body": map[string]any{
+				"contentType": "Text",
+				"content":     "Vous trouverez en pièce jointe l'historique de votre conversation avec NEO.",
+			},
+			"toRecipients": []map[string]any{
+				{"emailAddress": map[string]string{"address": address}},
+			},
+			"attachments": []map[string]any{{
+				"@odata.type":  "#microsoft.graph.fileAttachment",
+				"name":         export.FileName,
+				"contentType":  export.ContentType,
+				"contentBytes": base64.StdEncoding.EncodeToString(export.Data),
+			}},
+		},
+		"saveToSentItems": false,
+	}
+	if _, err := graphService.Post("/users/"+PathSegment(userID)+"/sendMail", body); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// Erase - Effacement RGPD : conversations (chat et appels vocaux), mémoire et jetons
+func (d *UserDataService) Erase(tenantID, userID string) (*ErasureReport, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("utilisateur non identifié")
+	}
+	report := &ErasureReport{UserID: userID, TenantID: tenantID}
+
+	personal, shared, err := d.conversations.EraseUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to erase conversations: %w", err)
+	}
+	report.Conversations = personal
+	report.Shared = shared
+
+	calls, err := d.profiles.EraseCalls(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to erase call bindings: %w", err)
+	}
+	for _, callID := range calls {
+		if err := d.conversations.Clear(callID); err != nil {
+			return nil, fmt.Errorf("failed to erase call %s: %w", callID, err)
+		}
+	}
+	report.Calls = len(calls)
+
+	if report.Reminders, err = d.reminders.EraseUser(userID); err != nil {
+		return nil, fmt.Errorf("failed to erase reminders: %w", err)
+	}
+
+	if err := d.profiles.Delete(userID); err != nil {
+		return nil, fmt.Errorf("failed to erase profile: %w", err)
+	}
+	report.Profile = true
+
+	if d.auth != nil {
+		d.auth.SignOutUser(tenantID, userID)
+		report.Tokens = true
+	}
+
+	report.ErasedAt = time.Now()
+	log.Printf("[Privacy] Données effacées pour %s (tenant %s): %d conversations, %d partagées, %d appels, %d rappels", userID, tenantID, report.Conversations, report.Shared, report.Calls, report.Reminders)
+	return report, nil
+}
+
+// RequestErase - Mémorise la demande d'effacement ; elle doit être confirmée
+// dans la même conversation avant erasePendingTTL
+func (d *UserDataService) RequestErase(tenantID, userID, conversationID string) error {
+	return d.store.Set(erasePendingKey(tenantID, userID, conversationID), []byte(time.Now().Format(time.RFC3339)), erasePendingTTL)
+}
+
+// ConfirmErase - Consomme la demande en attente ; false si aucune ou expirée
+func (d *UserDataService) ConfirmErase(tenantID, userID, conversationID string) bool {
+	key := erasePendingKey(tenantID, userID, conversationID)
+	if _, err := d.store.Get(key); err != nil {
+		return false
+	}
+	d.store.Delete(key)
+	return true
+}
+
+func erasePendingKey(tenantID, userID, conversationID string) string {
+	return erasePendingPrefix + tenantID + "/" + userID + "/" + conversationID
+}
+
+// markdownMessage - Rendu Markdown d'un message, tours d'outils compris
+func markdownMessage(msg ConversationMessage) string {
+	stamp := msg.Time.Format("02/01/2006 15:04")
+	if len(msg.Parts) == 0 {
+		speaker := "Vous"
+		if msg.Role == "assistant" {
+			speaker = "NEO"
+		}
+		return fmt.Sprintf("**%s** — %s\n\n%s\n\n", speaker, stamp, msg.Content)
+	}
+
+	var md strings.Builder
+	for _, part := range msg.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			args, _ := json.Marshal(part.FunctionCall.Args)
+			fmt.Fprintf(&md, "> 🔧 %s — NEO appelle `%s` avec `%s`\n\n", stamp, part.FunctionCall.Name, args)
+		case part.FunctionResponse != nil:
+			result := fmt.Sprint(part.FunctionResponse.Response["result"])
+			fmt.Fprintf(&md, "> 📄 Résultat de `%s` :\n>\n> ```\n> %s\n> ```\n\n", part.FunctionResponse.Name, strings.ReplaceAll(result, "\n", "\n> "))
+		case part.Text != "":
+			fmt.Fprintf(&md, "**NEO** — %s\n\n%s\n\n", stamp, part.Text)
+		}
+	}
+	return md.String()
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"microsoft_connector/internal/storage"
+)
+
+func newUserDataTest(t *testing.T) (*UserDataService, *ConversationStore) {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	conversations := NewConversationStore(store, ConversationOptions{})
+	t.Cleanup(conversations.Stop)
+	return NewUserDataService(store, conversations, NewProfileStore(store), nil, nil), conversations
+}
+
+// say - Tour complet d'un utilisateur : son message et la réponse de NEO
+func say(conversations *ConversationStore, conversationID, userID, text string) {
+	conversations.AddMessage(conversationID, userID, "user", text)
+	conversations.Participate(conversationID, userID)
+	conversations.AddMessage(conversationID, userID, "assistant", "réponse à "+text)
+}
+
+func TestEraseKeepsOtherParticipantsTurns(t *testing.T) {
+	data, conversations := newUserDataTest(t)
+	say(conversations, "tenant-1/personal", "alice", "mon secret")
+	say(conversations, "tenant-1/group", "alice", "question d'alice")
+	say(conversations, "tenant-1/group", "bob", "question de bob")
+	conversations.Summarize("tenant-1/group", "- alice et bob ont parlé", conversations.GetHistory("tenant-1/group")[0].Time)
+
+	report, err := data.Erase("tenant-1", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Conversations != 1 || report.Shared != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if history := conversations.GetHistory("tenant-1/personal"); len(history) != 0 {
+		t.Fatalf("personal conversation kept %d messages", len(history))
+	}
+
+	history := conversations.GetHistory("tenant-1/group")
+	if len(history) != 2 || history[0].Author != "bob" || history[1].Author != "bob" {
+		t.Fatalf("group history = %+v", history)
+	}
+	if conversations.Summary("tenant-1/group") != "" {
+		t.Fatal("summary quoting the erased user kept")
+	}
+	if ids, _ := conversations.UserConversations("bob"); len(ids) != 1 || ids[0] != "tenant-1/group" {
+		t.Fatalf("bob's index = %v", ids)
+	}
+	if ids, _ := conversations.UserConversations("alice"); len(ids) != 0 {
+		t.Fatalf("alice's index = %v", ids)
+	}
+}
+
+func TestExportFiltersSharedConversation(t *testing.T) {
+	data, conversations := newUserDataTest(t)
+	say(conversations, "tenant-1/group", "alice", "question d'alice")
+	say(conversations, "tenant-1/group", "bob", "question de bob")
+
+	export, err := data.Export("tenant-1/group", "alice", "markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := string(export.Data); !strings.Contains(text, "question d'alice") || strings.Contains(text, "bob") {
+		t.Fatalf("export = %s", text)
+	}
+
+	// Export administrateur : l'historique complet
+	export, err = data.Export("tenant-1/group", "", "json")
+	if err != nil || !strings.Contains(string(export.Data), "question de bob") {
+		t.Fatalf("admin export = %s, %v", export.Data, err)
+	}
+}
+
+func TestEraseRequiresPendingRequest(t *testing.T) {
+	data, _ := newUserDataTest(t)
+
+	if data.ConfirmErase("tenant-1", "alice", "tenant-1/personal") {
+		t.Fatal("confirmation accepted without a request")
+	}
+	data.RequestErase("tenant-1", "alice", "tenant-1/personal")
+	if data.ConfirmErase("tenant-1", "bob", "tenant-1/personal") || data.ConfirmErase("tenant-1", "alice", "tenant-1/group") {
+		t.Fatal("request confirmed by another user or conversation")
+	}
+	if !data.ConfirmErase("tenant-1", "alice", "tenant-1/personal") {
+		t.Fatal("pending request not confirmed")
+	}
+	if data.ConfirmErase("tenant-1", "alice", "tenant-1/personal") {
+		t.Fatal("request confirmed twice")
+	}
+}
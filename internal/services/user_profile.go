@@ -0,0 +1,455 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"microsoft_connector/internal/storage"
+)
+
+const (
+	profileKeyPrefix = "profiles/"
+	callKeyPrefix    = "calls/"
+	// Un appel vocal ne dure pas plus longtemps
+	callBindingTTL = 12 * time.Hour
+
+	profileMaxFacts    = 100
+	profileMaxContacts = 50
+	// Entrées injectées dans le contexte système
+	profileContextFacts    = 15
+	profileContextContacts = 5
+)
+
+// UserProfile - Préférences et mémoire à long terme d'un utilisateur (clé : object id AAD)
+type UserProfile struct {
+	UserID         string           `json:"user_id"`
+	Language       string           `json:"language,omitempty"`
+	TimeZone       string           `json:"time_zone,omitempty"`
+	MeetingMinutes int              `json:"meeting_minutes,omitempty"`
+	Contacts       []ProfileContact `json:"contacts,omitempty"`
+	Facts          []ProfileFact    `json:"facts,omitempty"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// ProfileContact - Contact fréquent ; Pinned = ajouté explicitement par l'utilisateur
+type ProfileContact struct {
+	Name     string    `json:"name,omitempty"`
+	Email    string    `json:"email"`
+	Uses     int       `json:"uses"`
+	LastUsed time.Time `json:"last_used"`
+	Pinned   bool      `json:"pinned,omitempty"`
+}
+
+// ProfileFact - Information que l'utilisateur a demandé à NEO de retenir
+type ProfileFact struct {
+	ID        string    `json:"id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProfileStore - Mémoire des utilisateurs, partagée entre le chat et les appels vocaux
+type ProfileStore struct {
+	store       storage.Store
+	mu          sync.Mutex     // lecture-modification-écriture d'un profil
+	defaultZone *time.Location // fuseau des utilisateurs qui n'en ont pas choisi
+}
+
+func NewProfileStore(store storage.Store) *ProfileStore {
+	return &ProfileStore{store: store}
+}
+
+// SetDefaultTimeZone - Fuseau IANA utilisé tant que l'utilisateur n'en a pas défini
+func (p *ProfileStore) SetDefaultTimeZone(name string) error {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+	p.defaultZone = loc
+	return nil
+}
+
+// Location - Fuseau de l'utilisateur (profil, sinon fuseau par défaut)
+func (p *ProfileStore) Location(userID string) *time.Location {
+	if p == nil {
+		return time.Local
+	}
+	if userID != "" {
+		if zone := p.Get(userID).TimeZone; zone != "" {
+			if loc, err := time.LoadLocation(zone); err == nil {
+				return loc
+			}
+		}
+	}
+	if p.defaultZone != nil {
+		return p.defaultZone
+	}
+	return time.Local
+}
+
+// Get - Profil de l'utilisateur (vide s'il n'existe pas encore)
+func (p *ProfileStore) Get(userID string) *UserProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.load(userID)
+}
+
+// Update - Modifie le profil sous verrou puis l'enregistre
+func (p *ProfileStore) Update(userID string, fn func(profile *UserProfile) error) error {
+	if userID == "" {
+		return fmt.Errorf("utilisateur non identifié")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	profile := p.load(userID)
+	if err := fn(profile); err != nil {
+		return err
+	}
+	profile.UpdatedAt = time.Now()
+	return p.save(profile)
+}
+
+// Delete - Oublie tout ce que NEO sait de l'utilisateur
+func (p *ProfileStore) Delete(userID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.store.Delete(profileKeyPrefix + userID)
+}
+
+// NoteContacts - Les destinataires des emails et réunions alimentent les contacts fréquents
+func (p *ProfileStore) NoteContacts(userID string, emails ...string) {
+	if p == nil || userID == "" || len(emails) == 0 {
+		return
+	}
+	err := p.Update(userID, func(profile *UserProfile) error {
+		for _, email := range emails {
+			profile.addContact("", email, false)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Profiles] Contacts de %s non enregistrés: %v", userID, err)
+	}
+}
+
+// callBinding - Utilisateur et tenant à l'origine d'un appel vocal
+type callBinding struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// BindCall - Associe un appel vocal à l'utilisateur qui l'a demandé et à son tenant
+func (p *ProfileStore) BindCall(callID, tenantID, userID string) {
+	if p == nil || callID == "" || userID == "" {
+		return
+	}
+	data, err := json.Marshal(callBinding{UserID: userID, TenantID: tenantID})
+	if err == nil {
+		err = p.store.Set(callKeyPrefix+callID, data, callBindingTTL)
+	}
+	if err != nil {
+		log.Printf("[Profiles] Association de l'appel %s impossible: %v", callID, err)
+	}
+}
+
+// CallUser - Utilisateur à l'origine d'un appel vocal ("" si inconnu)
+func (p *ProfileStore) CallUser(callID string) string {
+	return p.call(callID).UserID
+}
+
+// CallTenant - Tenant de l'utilisateur à l'origine d'un appel vocal ("" si inconnu)
+func (p *ProfileStore) CallTenant(callID string) string {
+	return p.call(callID).TenantID
+}
+
+func (p *ProfileStore) call(callID string) callBinding {
+	if p == nil {
+		return callBinding{}
+	}
+	data, err := p.store.Get(callKeyPrefix + callID)
+	if err != nil {
+		return callBinding{}
+	}
+	return decodeCallBinding(data)
+}
+
+// decodeCallBinding - Les associations antérieures ne contiennent que l'ID utilisateur
+func decodeCallBinding(data []byte) callBinding {
+	var binding callBinding
+	if err := json.Unmarshal(data, &binding); err != nil {
+		return callBinding{UserID: string(data)}
+	}
+	return binding
+}
+
+// EraseCalls - Supprime les associations d'appels de l'utilisateur et retourne leurs IDs
+func (p *ProfileStore) EraseCalls(userID string) ([]string, error) {
+	keys, err := p.store.Keys(callKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var calls []string
+	for _, key := range keys {
+		if data, err := p.store.Get(key); err == nil && decodeCallBinding(data).UserID == userID {
+			if err := p.store.Delete(key); err != nil {
+				return calls, err
+			}
+			calls = append(calls, key[len(callKeyPrefix):])
+		}
+	}
+	return calls, nil
+}
+
+// Context - Préférences et souvenirs pertinents pour le message courant, à
+// injecter dans le contexte système ("" si le profil est vide)
+func (p *ProfileStore) Context(userID, message string) string {
+	if p == nil || userID == "" {
+		return ""
+	}
+	profile := p.Get(userID)
+
+	var lines []string
+	if profile.Language != "" {
+		lines = append(lines, "- Langue préférée : "+profile.Language+" (réponds dans cette langue)")
+	}
+	if profile.TimeZone != "" {
+		lines = append(lines, "- Fuseau horaire : "+profile.TimeZone+" (utilise-le pour les dates et heures)")
+	}
+	if profile.MeetingMinutes > 0 {
+		lines = append(lines, fmt.Sprintf("- Durée habituelle des réunions : %d minutes", profile.MeetingMinutes))
+	}
+	for _, contact := range profile.topContacts(profileContextContacts) {
+		lines = append(lines, "- Contact fréquent : "+contact.label())
+	}
+	for _, fact := range profile.relevantFacts(message, profileContextFacts) {
+		lines = append(lines, fmt.Sprintf("- Souvenir [%s] : %s", fact.ID, fact.Text))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "MÉMOIRE DE L'UTILISATEUR (outils remember, recall et forget pour la modifier) :\n" + strings.Join(lines, "\n")
+}
+
+// WithProfile - Ajoute la mémoire de l'utilisateur au contexte système
+func (p *ProfileStore) WithProfile(systemContext, userID, message string) string {
+	memory := p.Context(userID, message)
+	if memory == "" {
+		return systemContext
+	}
+	return systemContext + "\n\n" + memory
+}
+
+func (p *ProfileStore) load(userID string) *UserProfile {
+	profile := &UserProfile{UserID: userID}
+	data, err := p.store.Get(profileKeyPrefix + userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return profile
+	}
+	if err != nil {
+		log.Printf("[Profiles] Lecture du profil %s impossible: %v", userID, err)
+		return profile
+	}
+	if err := json.Unmarshal(data, profile); err != nil {
+		log.Printf("[Profiles] Profil %s illisible, ignoré: %v", userID, err)
+		return &UserProfile{UserID: userID}
+	}
+	return profile
+}
+
+func (p *ProfileStore) save(profile *UserProfile) error {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile: %w", err)
+	}
+	return p.store.Set(profileKeyPrefix+profile.UserID, data, 0)
+}
+
+// SetPreference - language, time_zone ou meeting_length
+func (u *UserProfile) SetPreference(kind, value string) error {
+	value = strings.TrimSpace(value)
+	switch kind {
+	case "language":
+		u.Language = value
+	case "time_zone":
+		if _, err := time.LoadLocation(value); err != nil {
+			return fmt.Errorf("fuseau horaire inconnu %q (format IANA attendu, ex. Europe/Paris)", value)
+		}
+		u.TimeZone = value
+	case "meeting_length":
+		minutes, err := parseMinutes(value)
+		if err != nil {
+			return err
+		}
+		u.MeetingMinutes = minutes
+	default:
+		return fmt.Errorf("préférence inconnue %q", kind)
+	}
+	return nil
+}
+
+// AddFact - Retient une information ; les plus anciennes sont oubliées au-delà de la limite
+func (u *UserProfile) AddFact(text string) ProfileFact {
+	fact := ProfileFact{ID: newFactID(), Text: strings.TrimSpace(text), CreatedAt: time.Now()}
+	u.Facts = append(u.Facts, fact)
+	if len(u.Facts) > profileMaxFacts {
+		u.Facts = u.Facts[len(u.Facts)-profileMaxFacts:]
+	}
+	return fact
+}
+
+// ForgetFact - Par ID, ou par texte si aucun ID ne correspond
+func (u *UserProfile) ForgetFact(ref string) bool {
+	before := len(u.Facts)
+	u.Facts = slices.DeleteFunc(u.Facts, func(fact ProfileFact) bool {
+		return fact.ID == ref || strings.EqualFold(fact.Text, ref)
+	})
+	return len(u.Facts) != before
+}
+
+// ForgetContact - Par email ou par nom
+func (u *UserProfile) ForgetContact(ref string) bool {
+	before := len(u.Contacts)
+	u.Contacts = slices.DeleteFunc(u.Contacts, func(contact ProfileContact) bool {
+		return strings.EqualFold(contact.Email, ref) || (contact.Name != "" && strings.EqualFold(contact.Name, ref))
+	})
+	return len(u.Contacts) != before
+}
+
+func (u *UserProfile) addContact(name, email string, pinned bool) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return
+	}
+
+	now := time.Now()
+	for i := range u.Contacts {
+		if u.Contacts[i].Email == email {
+			u.Contacts[i].Uses++
+			u.Contacts[i].LastUsed = now
+			u.Contacts[i].Pinned = u.Contacts[i].Pinned || pinned
+			if name != "" {
+				u.Contacts[i].Name = name
+			}
+			return
+		}
+	}
+	u.Contacts = append(u.Contacts, ProfileContact{Name: name, Email: email, Uses: 1, LastUsed: now, Pinned: pinned})
+
+	// Au-delà de la limite, on oublie le contact non épinglé le moins utilisé
+	if len(u.Contacts) > profileMaxContacts {
+		victim := -1
+		for i, contact := range u.Contacts {
+			if contact.Pinned {
+				continue
+			}
+			if victim < 0 || contact.Uses < u.Contacts[victim].Uses ||
+				(contact.Uses == u.Contacts[victim].Uses && contact.LastUsed.Before(u.Contacts[victim].LastUsed)) {
+				victim = i
+			}
+		}
+		if victim >= 0 {
+			u.Contacts = slices.Delete(u.Contacts, victim, victim+1)
+		}
+	}
+}
+
+// AddContact - Contact épinglé par l'utilisateur
+func (u *UserProfile) AddContact(name, email string) {
+	u.addContact(strings.TrimSpace(name), email, true)
+}
+
+func (u *UserProfile) topContacts(limit int) []ProfileContact {
+	contacts := slices.Clone(u.Contacts)
+	slices.SortFunc(contacts, func(a, b ProfileContact) int {
+		if a.Pinned != b.Pinned {
+			if a.Pinned {
+				return -1
+			}
+			return 1
+		}
+		if a.Uses != b.Uses {
+			return b.Uses - a.Uses
+		}
+		return b.LastUsed.Compare(a.LastUsed)
+	})
+	return contacts[:min(limit, len(contacts))]
+}
+
+// relevantFacts - Souvenirs partageant des mots avec le message, puis les plus récents
+func (u *UserProfile) relevantFacts(message string, limit int) []ProfileFact {
+	words := significantWords(message)
+	type scored struct {
+		fact  ProfileFact
+		score int
+	}
+	ranked := make([]scored, 0, len(u.Facts))
+	for _, fact := range u.Facts {
+		score := 0
+		for word := range significantWords(fact.Text) {
+			if words[word] {
+				score++
+			}
+		}
+		ranked = append(ranked, scored{fact, score})
+	}
+	slices.SortStableFunc(ranked, func(a, b scored) int {
+		if a.score != b.score {
+			return b.score - a.score
+		}
+		return b.fact.CreatedAt.Compare(a.fact.CreatedAt)
+	})
+
+	facts := make([]ProfileFact, 0, min(limit, len(ranked)))
+	for _, entry := range ranked[:min(limit, len(ranked))] {
+		facts = append(facts, entry.fact)
+	}
+	return facts
+}
+
+func (c ProfileContact) label() string {
+	if c.Name == "" {
+		return c.Email
+	}
+	return fmt.Sprintf("%s <%s>", c.Name, c.Email)
+}
+
+// significantWords - Mots de plus de 3 lettres, en minuscules
+func significantWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) > 3 {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// parseMinutes - "30", "45 min" ou durée Go ("1h30m")
+func parseMinutes(value string) (int, error) {
+	trimmed := strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(value, "minutes"), "min"))
+	if minutes, err := strconv.Atoi(trimmed); err == nil && minutes > 0 {
+		return minutes, nil
+	}
+	if d, err := time.ParseDuration(strings.ReplaceAll(value, " ", "")); err == nil && d >= time.Minute {
+		return int(d.Minutes()), nil
+	}
+	return 0, fmt.Errorf("durée invalide %q (en minutes, ex. 30)", value)
+}
+
+// newFactID - Identifiant court, stable pour forget
+func newFactID() string {
+	return newClientRequestID()[:8]
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"testing"
+
+	"microsoft_connector/internal/storage"
+)
+
+func TestCallBinding(t *testing.T) {
+	store := storage.NewMemoryStore()
+	profiles := NewProfileStore(store)
+
+	profiles.BindCall("call-1", "tenant-2", "user-1")
+	if user, tenant := profiles.CallUser("call-1"), profiles.CallTenant("call-1"); user != "user-1" || tenant != "tenant-2" {
+		t.Fatalf("call-1 bound to %q in %q", user, tenant)
+	}
+	if profiles.CallUser("call-unknown") != "" || profiles.CallTenant("call-unknown") != "" {
+		t.Fatal("unknown call resolved")
+	}
+
+	// Association écrite avant l'ajout du tenant : l'ID utilisateur seul
+	store.Set(callKeyPrefix+"call-2", []byte("user-1"), callBindingTTL)
+	if user, tenant := profiles.CallUser("call-2"), profiles.CallTenant("call-2"); user != "user-1" || tenant != "" {
+		t.Fatalf("legacy binding read as %q in %q", user, tenant)
+	}
+
+	profiles.BindCall("call-3", "tenant-1", "user-2")
+	calls, err := profiles.EraseCalls("user-1")
+	if err != nil || len(calls) != 2 {
+		t.Fatalf("EraseCalls = %v, %v", calls, err)
+	}
+	if profiles.CallUser("call-1") != "" || profiles.CallUser("call-3") != "user-2" {
+		t.Fatal("EraseCalls removed the wrong bindings")
+	}
+}
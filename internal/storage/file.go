@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Les systèmes de fichiers limitent un nom à 255 octets : au-delà de ce seuil
+	// (clé échappée), le fichier est nommé par l'empreinte SHA-256 de la clé
+	fileNameMaxLen = 200
+	fileHashedDir  = "hashed"
+)
+
+type fileEntry struct {
+	Key       string    `json:"key,omitempty"` // clé d'origine, illisible dans le nom d'un fichier haché
+	Value     []byte    `json:"value"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// FileStore - Un fichier JSON par clé dans un répertoire (écriture atomique)
+type FileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, fileHashedDir), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path - Nom échappé, ou empreinte dans hashed/ pour une clé trop longue ; "/"
+// étant échappé, aucun nom échappé ne peut désigner ce sous-répertoire
+func (s *FileStore) path(key string) string {
+	name := url.PathEscape(key)
+	if len(name) > fileNameMaxLen {
+		sum := sha256.Sum256([]byte(key))
+		return filepath.Join(s.dir, fileHashedDir, hex.EncodeToString(sum[:])+".json")
+	}
+	return filepath.Join(s.dir, name+".json")
+}
+
+func (s *FileStore) read(path string) (*fileEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry fileEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("corrupted storage entry %s: %w", filepath.Base(path), err)
+	}
+	return &entry, nil
+}
+
+func (s *FileStore) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	entry, err := s.read(s.path(key))
+	s.mu.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if expired(entry.ExpiresAt) {
+		s.deleteExpired(key)
+		return nil, ErrNotFound
+	}
+	return entry.Value, nil
+}
+
+// deleteExpired - Relit l'entrée sous le verrou d'écriture : un Set concurrent
+// a pu la remplacer depuis la lecture
+func (s *FileStore) deleteExpired(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := s.read(s.path(key))
+	if err != nil || !expired(entry.ExpiresAt) {
+		return
+	}
+	os.Remove(s.path(key))
+}
+
+func (s *FileStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(key, value, ttl)
+}
+
+func (s *FileStore) SetIfAbsent(key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := s.read(s.path(key))
+	if err == nil && !expired(entry.ExpiresAt) {
+		return false, nil
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	return true, s.write(key, value, ttl)
+}
+
+func (s *FileStore) CompareAndSwap(key string, old, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ok, err := s.holds(key, old); !ok {
+		return false, err
+	}
+	return true, s.write(key, value, ttl)
+}
+
+func (s *FileStore) CompareAndDelete(key string, old []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ok, err := s.holds(key, old); !ok {
+		return false, err
+	}
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+// holds - La clé existe, n'a pas expiré et vaut old ; verrou tenu
+func (s *FileStore) holds(key string, old []byte) (bool, error) {
+	entry, err := s.read(s.path(key))
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !expired(entry.ExpiresAt) && bytes.Equal(entry.Value, old), nil
+}
+
+// write - Écriture atomique (fichier temporaire puis renommage), verrou tenu
+func (s *FileStore) write(key string, value []byte, ttl time.Duration) error {
+	data, err := json.Marshal(fileEntry{Key: key, Value: value, ExpiresAt: expiry(ttl)})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write storage entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write storage entry: %w", err)
+	}
+	tmp.Close()
+
+	return os.Rename(tmp.Name(), s.path(key))
+}
+
+func (s *FileStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// list - Clés présentes sur disque (expirées comprises)
+func (s *FileStore) list(prefix string) ([]string, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, f := range files {
+		name, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok || f.IsDir() {
+			continue
+		}
+		key, err := url.PathUnescape(name)
+		if err != nil || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	hashed, err := os.ReadDir(filepath.Join(s.dir, fileHashedDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, f := range hashed {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		entry, err := s.read(filepath.Join(s.dir, fileHashedDir, f.Name()))
+		if err != nil || !strings.HasPrefix(entry.Key, prefix) {
+			continue
+		}
+		keys = append(keys, entry.Key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *FileStore) Keys(prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all, err := s.list(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, key := range all {
+		if entry, err := s.read(s.path(key)); err == nil && !expired(entry.ExpiresAt) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *FileStore) DeletePrefix(prefix string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.list(prefix)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
+
+func (s *FileStore) PurgeExpired() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.list("")
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, key := range keys {
+		entry, err := s.read(s.path(key))
+		if err != nil || !expired(entry.ExpiresAt) {
+			continue
+		}
+		if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
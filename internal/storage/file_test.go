@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStoreExpiry(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Set("a", []byte("1"), time.Millisecond)
+	store.Set("b", []byte("2"), 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := store.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired entry: %v", err)
+	}
+	if keys, _ := store.Keys(""); len(keys) != 1 || keys[0] != "b" {
+		t.Fatalf("expired entry not deleted: %v", keys)
+	}
+	if value, err := store.Get("b"); err != nil || string(value) != "2" {
+		t.Fatalf("Get(b) = %q, %v", value, err)
+	}
+}
+
+// Un Get qui constate l'expiration ne doit pas effacer la valeur écrite entre-temps
+func TestFileStoreExpiredGetKeepsConcurrentSet(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Set("lease", []byte("old"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	entry, err := store.read(store.path("lease"))
+	if err != nil || !expired(entry.ExpiresAt) {
+		t.Fatalf("entry not expired: %v", err)
+	}
+
+	// Get a lu l'entrée expirée, puis un Set passe avant la suppression
+	store.Set("lease", []byte("new"), time.Hour)
+	store.deleteExpired("lease")
+
+	if value, err := store.Get("lease"); err != nil || string(value) != "new" {
+		t.Fatalf("concurrent Set lost: %q, %v", value, err)
+	}
+}
+
+// Clés d'idempotence des conversations Teams : le nom échappé dépasse 255 octets
+func TestFileStoreLongKeys(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "idempotency/activities/msteams/" + strings.Repeat("19:meeting_Zjk2YTQ@thread.v2;messageid=", 7)
+	if len(key) < 300 {
+		t.Fatalf("key is only %d characters", len(key))
+	}
+
+	if ok, err := store.SetIfAbsent(key, []byte("processing"), time.Minute); !ok || err != nil {
+		t.Fatalf("SetIfAbsent = %v, %v", ok, err)
+	}
+	if ok, _ := store.SetIfAbsent(key, []byte("processing"), time.Minute); ok {
+		t.Fatal("second claim of a long key succeeded")
+	}
+	if value, err := store.Get(key); err != nil || string(value) != "processing" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+
+	store.Set("idempotency/activities/short", []byte("done"), 0)
+	keys, err := store.Keys("idempotency/activities/")
+	if err != nil || len(keys) != 2 || !slices.Contains(keys, key) {
+		t.Fatalf("Keys = %v, %v", keys, err)
+	}
+	if count, err := store.DeletePrefix("idempotency/"); err != nil || count != 2 {
+		t.Fatalf("DeletePrefix = %d, %v", count, err)
+	}
+	if _, err := store.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("long key kept after DeletePrefix: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryStore - Backend par défaut, perdu au redémarrage
+type MemoryStore struct {
+	entries map[string]memoryEntry
+	mu      sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	entry, ok := s.entries[key]
+	s.mu.RUnlock()
+
+	if !ok || expired(entry.expiresAt) {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), entry.value...), nil
+}
+
+func (s *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = memoryEntry{
+		value:     append([]byte(nil), value...),
+		expiresAt: expiry(ttl),
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetIfAbsent(key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && !expired(entry.expiresAt) {
+		return false, nil
+	}
+	s.entries[key] = memoryEntry{
+		value:     append([]byte(nil), value...),
+		expiresAt: expiry(ttl),
+	}
+	return true, nil
+}
+
+func (s *MemoryStore) CompareAndSwap(key string, old, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; !ok || expired(entry.expiresAt) || !bytes.Equal(entry.value, old) {
+		return false, nil
+	}
+	s.entries[key] = memoryEntry{
+		value:     append([]byte(nil), value...),
+		expiresAt: expiry(ttl),
+	}
+	return true, nil
+}
+
+func (s *MemoryStore) CompareAndDelete(key string, old []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; !ok || expired(entry.expiresAt) || !bytes.Equal(entry.value, old) {
+		return false, nil
+	}
+	delete(s.entries, key)
+	return true, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) Keys(prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []string{}
+	for key, entry := range s.entries {
+		if strings.HasPrefix(key, prefix) && !expired(entry.expiresAt) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *MemoryStore) DeletePrefix(prefix string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for key := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.entries, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) PurgeExpired() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for key, entry := range s.entries {
+		if expired(entry.expiresAt) {
+			delete(s.entries, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import "sync"
+
+// PubSub - Diffusion de messages entre réplicas (appels repris, messages proactifs...)
+type PubSub interface {
+	Publish(channel string, payload []byte) error
+	// Subscribe - Le handler est appelé pour chaque message ; la fonction retournée désabonne
+	Subscribe(channel string, handler func(payload []byte)) (func(), error)
+}
+
+// OpenPubSub - Le backend redis diffuse entre réplicas ; les autres backends
+// n'ont qu'une instance et diffusent en mémoire
+func OpenPubSub(store Store) PubSub {
+	if bus, ok := store.(PubSub); ok {
+		return bus
+	}
+	return NewMemoryPubSub()
+}
+
+// MemoryPubSub - Diffusion dans le processus (instance unique)
+type MemoryPubSub struct {
+	mu       sync.RWMutex
+	handlers map[string]map[int]func([]byte)
+	next     int
+}
+
+func NewMemoryPubSub() *MemoryPubSub {
+	return &MemoryPubSub{handlers: make(map[string]map[int]func([]byte))}
+}
+
+func (b *MemoryPubSub) Publish(channel string, payload []byte) error {
+	b.mu.RLock()
+	handlers := make([]func([]byte), 0, len(b.handlers[channel]))
+	for _, handler := range b.handlers[channel] {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	// Asynchrone, comme la livraison par Redis
+	for _, handler := range handlers {
+		go handler(append([]byte(nil), payload...))
+	}
+	return nil
+}
+
+func (b *MemoryPubSub) Subscribe(channel string, handler func(payload []byte)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.handlers[channel] == nil {
+		b.handlers[channel] = make(map[int]func([]byte))
+	}
+	id := b.next
+	b.next++
+	b.handlers[channel][id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers[channel], id)
+	}, nil
+}
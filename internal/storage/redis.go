@@ -0,0 +1,472 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	redisPoolSize    = 8
+	redisDialTimeout = 5 * time.Second
+	redisIOTimeout   = 10 * time.Second
+	redisScanCount   = 500
+)
+
+// RedisStore - Backend partagé entre plusieurs réplicas, via le protocole Redis
+// (RESP) : Redis, Valkey, KeyDB... Implémente aussi PubSub.
+type RedisStore struct {
+	addr     string
+	username string
+	password string
+	db       int
+	tls      bool
+
+	pool chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// redisError - Réponse d'erreur du serveur (-ERR ...) : la connexion reste utilisable
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// NewRedisStore - rawURL : redis://[user:password@]host:port[/db] ou rediss:// (TLS)
+func NewRedisStore(rawURL string) (*RedisStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid redis url scheme %q", u.Scheme)
+	}
+
+	s := &RedisStore{
+		addr: u.Host,
+		tls:  u.Scheme == "rediss",
+		pool: make(chan *redisConn, redisPoolSize),
+	}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if s.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+
+	// Vérifie la connexion dès le démarrage
+	if _, err := s.do("PING"); err != nil {
+		return nil, fmt.Errorf("failed to reach redis at %s: %w", s.addr, err)
+	}
+	return s, nil
+}
+
+func (s *RedisStore) Get(key string) ([]byte, error) {
+	reply, err := s.do("GET", key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrNotFound
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply %T for GET", reply)
+	}
+	return value, nil
+}
+
+func (s *RedisStore) Set(key string, value []byte, ttl time.Duration) error {
+	args := []any{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	_, err := s.do(args...)
+	return err
+}
+
+func (s *RedisStore) SetIfAbsent(key string, value []byte, ttl time.Duration) (bool, error) {
+	args := []any{"SET", key, value, "NX"}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	reply, err := s.do(args...)
+	if err != nil {
+		return false, err
+	}
+	// "OK" si écrit, nil si la clé existait déjà
+	return reply != nil, nil
+}
+
+// Scripts Lua : la comparaison et l'écriture sont atomiques côté serveur
+const (
+	redisCompareAndSwap = `if redis.call("GET", KEYS[1]) ~= ARGV[1] then return 0 end
+if tonumber(ARGV[3]) > 0 then redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3]) else redis.call("SET", KEYS[1], ARGV[2]) end
+return 1`
+	redisCompareAndDelete = `if redis.call("GET", KEYS[1]) ~= ARGV[1] then return 0 end
+return redis.call("DEL", KEYS[1])`
+)
+
+func (s *RedisStore) CompareAndSwap(key string, old, value []byte, ttl time.Duration) (bool, error) {
+	reply, err := s.do("EVAL", redisCompareAndSwap, 1, key, old, value, max(ttl.Milliseconds(), 0))
+	if err != nil {
+		return false, err
+	}
+	return reply == int64(1), nil
+}
+
+func (s *RedisStore) CompareAndDelete(key string, old []byte) (bool, error) {
+	reply, err := s.do("EVAL", redisCompareAndDelete, 1, key, old)
+	if err != nil {
+		return false, err
+	}
+	return reply == int64(1), nil
+}
+
+func (s *RedisStore) Delete(key string) error {
+	_, err := s.do("DEL", key)
+	return err
+}
+
+func (s *RedisStore) Keys(prefix string) ([]string, error) {
+	keys := []string{}
+	cursor := "0"
+	for {
+		reply, err := s.do("SCAN", cursor, "MATCH", escapeRedisPattern(prefix)+"*", "COUNT", redisScanCount)
+		if err != nil {
+			return nil, err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 2 {
+			return nil, fmt.Errorf("redis: unexpected reply for SCAN")
+		}
+		next, _ := parts[0].([]byte)
+		batch, _ := parts[1].([]any)
+		for _, key := range batch {
+			if b, ok := key.([]byte); ok {
+				keys = append(keys, string(b))
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			break
+		}
+	}
+
+	// SCAN peut renvoyer une clé plusieurs fois
+	slices.Sort(keys)
+	return slices.Compact(keys), nil
+}
+
+func (s *RedisStore) DeletePrefix(prefix string) (int, error) {
+	keys, err := s.Keys(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for start := 0; start < len(keys); start += redisScanCount {
+		batch := keys[start:min(start+redisScanCount, len(keys))]
+		args := make([]any, 0, len(batch)+1)
+		args = append(args, "DEL")
+		for _, key := range batch {
+			args = append(args, key)
+		}
+		reply, err := s.do(args...)
+		if err != nil {
+			return count, err
+		}
+		deleted, _ := reply.(int64)
+		count += int(deleted)
+	}
+	return count, nil
+}
+
+// PurgeExpired - Redis supprime lui-même les clés expirées
+func (s *RedisStore) PurgeExpired() (int, error) {
+	return 0, nil
+}
+
+func (s *RedisStore) Close() error {
+	for {
+		select {
+		case c := <-s.pool:
+			c.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// Publish - Diffuse un message à tous les réplicas abonnés au canal
+func (s *RedisStore) Publish(channel string, payload []byte) error {
+	_, err := s.do("PUBLISH", channel, payload)
+	return err
+}
+
+// Subscribe - Connexion dédiée, rétablie automatiquement jusqu'à l'annulation
+func (s *RedisStore) Subscribe(channel string, handler func(payload []byte)) (func(), error) {
+	c, err := s.subscribe(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu        sync.Mutex
+		current   = c
+		cancelled bool
+	)
+	cancel := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		cancelled = true
+		current.conn.Close()
+	}
+
+	go func() {
+		for {
+			err := s.receive(current, handler)
+
+			mu.Lock()
+			if cancelled {
+				mu.Unlock()
+				return
+			}
+			mu.Unlock()
+			log.Printf("⚠️ [Redis] Abonnement %s interrompu, reconnexion: %v", channel, err)
+
+			for delay := time.Second; ; delay = min(delay*2, 30*time.Second) {
+				time.Sleep(delay)
+				c, err := s.subscribe(channel)
+				mu.Lock()
+				if cancelled {
+					mu.Unlock()
+					if c != nil {
+						c.conn.Close()
+					}
+					return
+				}
+				if err == nil {
+					current = c
+					mu.Unlock()
+					break
+				}
+				mu.Unlock()
+				log.Printf("⚠️ [Redis] Réabonnement à %s impossible: %v", channel, err)
+			}
+		}
+	}()
+	return cancel, nil
+}
+
+func (s *RedisStore) subscribe(channel string) (*redisConn, error) {
+	c, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	c.conn.SetDeadline(time.Now().Add(redisIOTimeout))
+	if _, err := c.command("SUBSCRIBE", channel); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	// Les messages arrivent sans délai garanti
+	c.conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func (s *RedisStore) receive(c *redisConn, handler func(payload []byte)) error {
+	defer c.conn.Close()
+	for {
+		reply, err := readRedisReply(c.r)
+		if err != nil {
+			return err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 3 {
+			continue
+		}
+		if kind, _ := parts[0].([]byte); string(kind) != "message" {
+			continue
+		}
+		if payload, ok := parts[2].([]byte); ok {
+			handler(payload)
+		}
+	}
+}
+
+// do - Commande sur une connexion du pool ; une connexion en erreur réseau est jetée
+func (s *RedisStore) do(args ...any) (any, error) {
+	var c *redisConn
+	select {
+	case c = <-s.pool:
+	default:
+		var err error
+		if c, err = s.dial(); err != nil {
+			return nil, err
+		}
+	}
+
+	c.conn.SetDeadline(time.Now().Add(redisIOTimeout))
+	reply, err := c.command(args...)
+	var serverErr redisError
+	if err != nil && !errors.As(err, &serverErr) {
+		c.conn.Close()
+		return nil, err
+	}
+
+	select {
+	case s.pool <- c:
+	default:
+		c.conn.Close()
+	}
+	return reply, err
+}
+
+func (s *RedisStore) dial() (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.tls {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, &tls.Config{MinVersion: tls.VersionTLS12})
+	} else {
+		conn, err = dialer.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(redisIOTimeout))
+	if s.password != "" {
+		args := []any{"AUTH", s.password}
+		if s.username != "" {
+			args = []any{"AUTH", s.username, s.password}
+		}
+		if _, err := c.command(args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		if _, err := c.command("SELECT", s.db); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// command - Envoie une commande RESP (tableau de chaînes) et lit la réponse
+func (c *redisConn) command(args ...any) (any, error) {
+	var buf []byte
+	buf = fmt.Appendf(buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		case int:
+			b = strconv.AppendInt(nil, int64(v), 10)
+		case int64:
+			b = strconv.AppendInt(nil, v, 10)
+		default:
+			return nil, fmt.Errorf("redis: unsupported argument type %T", arg)
+		}
+		buf = fmt.Appendf(buf, "$%d\r\n", len(b))
+		buf = append(buf, b...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return readRedisReply(c.r)
+}
+
+func readRedisReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]any, count)
+		for i := range items {
+			// Une erreur dans un élément n'invalide pas le tableau
+			item, err := readRedisReply(r)
+			var serverErr redisError
+			if err != nil && !errors.As(err, &serverErr) {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+// escapeRedisPattern - Le préfixe est littéral dans le motif de SCAN
+func escapeRedisPattern(prefix string) string {
+	var b strings.Builder
+	for _, r := range prefix {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *RedisStore) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	store, err := NewRedisStore("redis://" + server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return server, store
+}
+
+func TestRedisStoreGetSet(t *testing.T) {
+	_, store := newTestRedis(t)
+
+	if _, err := store.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get missing: %v", err)
+	}
+	if err := store.Set("a", []byte("bin\r\n\x00ary"), 0); err != nil {
+		t.Fatal(err)
+	}
+	value, err := store.Get("a")
+	if err != nil || string(value) != "bin\r\n\x00ary" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+	if err := store.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete: %v", err)
+	}
+}
+
+func TestRedisStoreTTL(t *testing.T) {
+	server, store := newTestRedis(t)
+
+	store.Set("short", []byte("1"), 2*time.Second)
+	store.Set("forever", []byte("1"), 0)
+	if ttl := server.TTL("short"); ttl != 2*time.Second {
+		t.Fatalf("TTL = %s", ttl)
+	}
+
+	server.FastForward(3 * time.Second)
+	if _, err := store.Get("short"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired key still readable: %v", err)
+	}
+	if _, err := store.Get("forever"); err != nil {
+		t.Fatalf("key without TTL expired: %v", err)
+	}
+}
+
+func TestRedisStoreSetIfAbsent(t *testing.T) {
+	server, store := newTestRedis(t)
+
+	ok, err := store.SetIfAbsent("lock", []byte("a"), time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("first SetIfAbsent = %v, %v", ok, err)
+	}
+	ok, err = store.SetIfAbsent("lock", []byte("b"), time.Minute)
+	if err != nil || ok {
+		t.Fatalf("second SetIfAbsent = %v, %v", ok, err)
+	}
+	if value, _ := store.Get("lock"); string(value) != "a" {
+		t.Fatalf("lock overwritten: %q", value)
+	}
+
+	// Le verrou expiré peut être repris
+	server.FastForward(2 * time.Minute)
+	if ok, err := store.SetIfAbsent("lock", []byte("c"), time.Minute); err != nil || !ok {
+		t.Fatalf("SetIfAbsent after expiry = %v, %v", ok, err)
+	}
+}
+
+func TestRedisStoreKeys(t *testing.T) {
+	_, store := newTestRedis(t)
+
+	for _, key := range []string{"users/1", "users/2", "users*/x", "other/1"} {
+		store.Set(key, []byte("v"), 0)
+	}
+	// Plus d'un lot de SCAN
+	for i := range redisScanCount + 10 {
+		store.Set("bulk/"+strconv.Itoa(i), []byte("v"), 0)
+	}
+
+	keys, err := store.Keys("users/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(keys, []string{"users/1", "users/2"}) {
+		t.Fatalf("Keys(users/) = %v", keys)
+	}
+	// Les jokers du préfixe sont littéraux
+	if keys, _ := store.Keys("users*"); !slices.Equal(keys, []string{"users*/x"}) {
+		t.Fatalf("Keys(users*) = %v", keys)
+	}
+
+	count, err := store.DeletePrefix("bulk/")
+	if err != nil || count != redisScanCount+10 {
+		t.Fatalf("DeletePrefix = %d, %v", count, err)
+	}
+	if keys, _ := store.Keys("bulk/"); len(keys) != 0 {
+		t.Fatalf("%d keys left after DeletePrefix", len(keys))
+	}
+}
+
+func TestRedisStoreAuthAndDatabase(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+
+	if _, err := NewRedisStore("redis://" + server.Addr()); err == nil {
+		t.Fatal("expected an error without password")
+	}
+	store, err := NewRedisStore("redis://:secret@" + server.Addr() + "/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Set("k", []byte("v"), 0)
+	server.Select(3)
+	if value, err := server.Get("k"); err != nil || value != "v" {
+		t.Fatalf("key not written in database 3: %q, %v", value, err)
+	}
+}
+
+func TestRedisStoreSubscribe(t *testing.T) {
+	server, store := newTestRedis(t)
+
+	received := make(chan string, 16)
+	cancel, err := store.Subscribe("events", func(payload []byte) {
+		received <- string(payload)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	if err := store.Publish("events", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	expectMessage(t, received, "hello")
+
+	// Redémarrage du serveur : l'abonnement est rétabli
+	addr := server.Addr()
+	server.Close()
+	if err := server.StartAddr(addr); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		// Les connexions du pool tombées sont jetées à la première erreur
+		store.Publish("events", []byte("after-restart"))
+		select {
+		case msg := <-received:
+			if msg != "after-restart" {
+				t.Fatalf("unexpected message %q", msg)
+			}
+			return
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	t.Fatal("subscription not restored after server restart")
+}
+
+func TestRedisStoreUnsubscribe(t *testing.T) {
+	_, store := newTestRedis(t)
+
+	received := make(chan string, 1)
+	cancel, err := store.Subscribe("events", func(payload []byte) {
+		received <- string(payload)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	store.Publish("events", []byte("late"))
+	select {
+	case msg := <-received:
+		t.Fatalf("message %q received after cancel", msg)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func expectMessage(t *testing.T, received <-chan string, want string) {
+	t.Helper()
+	select {
+	case msg := <-received:
+		if msg != want {
+			t.Fatalf("received %q, want %q", msg, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message %q not received", want)
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore - Table clé/valeur unique dans une base SQLite locale
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite ne gère qu'un écrivain à la fois
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS kv (
+		key        TEXT PRIMARY KEY,
+		value      BLOB NOT NULL,
+		expires_at INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sqlite schema: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Get(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRow(
+		`SELECT value FROM kv WHERE key = ? AND (expires_at = 0 OR expires_at > ?)`,
+		key, time.Now().UnixMilli(),
+	).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *SQLiteStore) Set(key string, value []byte, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = expiry(ttl).UnixMilli()
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO kv (key, value, expires_at) VALUES (?, ?, ?)
+		 ON CONFLICT(key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		key, value, expiresAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) SetIfAbsent(key string, value []byte, ttl time.Duration) (bool, error) {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = expiry(ttl).UnixMilli()
+	}
+	// Une entrée expirée est remplacée ; une entrée valide n'est pas modifiée
+	res, err := s.db.Exec(
+		`INSERT INTO kv (key, value, expires_at) VALUES (?, ?, ?)
+		 ON CONFLICT(key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at
+		 WHERE kv.expires_at > 0 AND kv.expires_at <= ?`,
+		key, value, expiresAt, time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return false, err
+	}
+	count, _ := res.RowsAffected()
+	return count > 0, nil
+}
+
+func (s *SQLiteStore) CompareAndSwap(key string, old, value []byte, ttl time.Duration) (bool, error) {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = expiry(ttl).UnixMilli()
+	}
+	res, err := s.db.Exec(
+		`UPDATE kv SET value = ?, expires_at = ?
+		 WHERE key = ? AND value = ? AND (expires_at = 0 OR expires_at > ?)`,
+		value, expiresAt, key, old, time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return false, err
+	}
+	count, _ := res.RowsAffected()
+	return count > 0, nil
+}
+
+func (s *SQLiteStore) CompareAndDelete(key string, old []byte) (bool, error) {
+	res, err := s.db.Exec(
+		`DELETE FROM kv WHERE key = ? AND value = ? AND (expires_at = 0 OR expires_at > ?)`,
+		key, old, time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return false, err
+	}
+	count, _ := res.RowsAffected()
+	return count > 0, nil
+}
+
+func (s *SQLiteStore) Delete(key string) error {
+	_, err := s.db.Exec(`DELETE FROM kv WHERE key = ?`, key)
+	return err
+}
+
+func (s *SQLiteStore) Keys(prefix string) ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT key FROM kv WHERE substr(key, 1, length(?)) = ? AND (expires_at = 0 OR expires_at > ?) ORDER BY key`,
+		prefix, prefix, time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLiteStore) DeletePrefix(prefix string) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM kv WHERE substr(key, 1, length(?)) = ?`, prefix, prefix)
+	if err != nil {
+		return 0, err
+	}
+	count, _ := res.RowsAffected()
+	return int(count), nil
+}
+
+func (s *SQLiteStore) PurgeExpired() (int, error) {
+	res, err := s.db.Exec(`DELETE FROM kv WHERE expires_at > 0 AND expires_at <= ?`, time.Now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	count, _ := res.RowsAffected()
+	return int(count), nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
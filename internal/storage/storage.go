@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// ErrNotFound - Clé absente ou expirée
+var ErrNotFound = errors.New("storage: key not found")
+
+// Store - Stockage clé/valeur partagé par les services (jetons, conversations...)
+//
+// Les clés sont hiérarchiques ("tokens/<tenant>/<user>") et les opérations par
+// préfixe permettent à chaque service de travailler dans son propre espace.
+type Store interface {
+	Get(key string) ([]byte, error)
+	// Set - ttl à 0 : pas d'expiration
+	Set(key string, value []byte, ttl time.Duration) error
+	// SetIfAbsent - Écrit seulement si la clé est absente ou expirée (verrou, idempotence)
+	SetIfAbsent(key string, value []byte, ttl time.Duration) (bool, error)
+	// CompareAndSwap - Remplace la valeur seulement si elle vaut encore old (bail)
+	CompareAndSwap(key string, old, value []byte, ttl time.Duration) (bool, error)
+	// CompareAndDelete - Supprime la clé seulement si sa valeur vaut encore old
+	CompareAndDelete(key string, old []byte) (bool, error)
+	Delete(key string) error
+	Keys(prefix string) ([]string, error)
+	DeletePrefix(prefix string) (int, error)
+	// PurgeExpired - Supprime physiquement les entrées expirées (nettoyage périodique)
+	PurgeExpired() (int, error)
+	Close() error
+}
+
+// Open - Ouvre le backend configuré (memory, file, sqlite ou redis) ; path est
+// un répertoire, ou l'URL du serveur pour redis
+func Open(backend, path string) (Store, error) {
+	switch backend {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "file":
+		return NewFileStore(path)
+	case "sqlite":
+		return NewSQLiteStore(filepath.Join(path, "neo.db"))
+	case "redis":
+		return NewRedisStore(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func expired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && time.Now().After(expiresAt)
+}
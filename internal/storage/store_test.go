@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testStores - Chaque backend, Redis compris (miniredis)
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	file, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := NewSQLiteStore(filepath.Join(t.TempDir(), "neo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	_, redis := newTestRedis(t)
+	return map[string]Store{"memory": NewMemoryStore(), "file": file, "sqlite": sqlite, "redis": redis}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if ok, err := store.CompareAndSwap("lease", []byte("a"), []byte("b"), 0); ok || err != nil {
+				t.Fatalf("swap of a missing key: %v, %v", ok, err)
+			}
+			store.Set("lease", []byte("a"), time.Minute)
+			if ok, _ := store.CompareAndSwap("lease", []byte("x"), []byte("b"), time.Minute); ok {
+				t.Fatal("swap with a stale value")
+			}
+			if ok, err := store.CompareAndSwap("lease", []byte("a"), []byte("b"), time.Minute); !ok || err != nil {
+				t.Fatalf("swap = %v, %v", ok, err)
+			}
+			if value, _ := store.Get("lease"); string(value) != "b" {
+				t.Fatalf("value = %q", value)
+			}
+
+			if ok, _ := store.CompareAndDelete("lease", []byte("a")); ok {
+				t.Fatal("delete with a stale value")
+			}
+			if ok, err := store.CompareAndDelete("lease", []byte("b")); !ok || err != nil {
+				t.Fatalf("delete = %v, %v", ok, err)
+			}
+			if _, err := store.Get("lease"); err != ErrNotFound {
+				t.Fatalf("key kept after delete: %v", err)
+			}
+
+			// Une valeur expirée ne peut plus être remplacée ; miniredis n'expire
+			// les clés qu'avec FastForward (TestRedisStoreTTL)
+			if name == "redis" {
+				return
+			}
+			store.Set("expired", []byte("a"), time.Millisecond)
+			time.Sleep(5 * time.Millisecond)
+			if ok, _ := store.CompareAndSwap("expired", []byte("a"), []byte("b"), 0); ok {
+				t.Fatal("swap of an expired value")
+			}
+		})
+	}
+}
+
+// Plusieurs réplicas tentent de reprendre le même bail : un seul y parvient
+func TestCompareAndSwapContention(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for round := 0; round < 10; round++ {
+				store.Set("call-owners/call-1", []byte("replica-0"), time.Minute)
+
+				var wins atomic.Int32
+				var winner atomic.Value
+				var wg sync.WaitGroup
+				for i := 1; i <= 8; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						owner := fmt.Sprintf("replica-%d", i)
+						ok, err := store.CompareAndSwap("call-owners/call-1", []byte("replica-0"), []byte(owner), time.Minute)
+						if err != nil {
+							t.Error(err)
+						}
+						if ok {
+							wins.Add(1)
+							winner.Store(owner)
+						}
+					}()
+				}
+				wg.Wait()
+
+				if wins.Load() != 1 {
+					t.Fatalf("round %d: %d replicas took the lease", round, wins.Load())
+				}
+				if value, _ := store.Get("call-owners/call-1"); string(value) != winner.Load() {
+					t.Fatalf("round %d: lease %q, winner %v", round, value, winner.Load())
+				}
+			}
+		})
+	}
+}